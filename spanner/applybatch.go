@@ -0,0 +1,240 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// maxMutationCellsPerCommit is the maximum number of mutated cells that
+	// Cloud Spanner accepts in a single commit.
+	maxMutationCellsPerCommit = 80000
+	// maxCommitBytes is the maximum size in bytes of the mutations of a
+	// single commit that is used by ApplyInAdaptiveBatches.
+	maxCommitBytes = 100 << 20
+)
+
+// AdaptiveBatchOptions configures the batches of ApplyInAdaptiveBatches.
+type AdaptiveBatchOptions struct {
+	// MinBatchSize is the minimum number of mutations in a batch, and the
+	// number of mutations in the first batch. A batch can contain fewer
+	// mutations if the mutations would otherwise exceed the limits of a
+	// commit. Defaults to 1.
+	MinBatchSize int
+
+	// MaxBatchSize is the maximum number of mutations in a batch. Defaults
+	// to 0, which means that the size of a batch is only limited by the
+	// limits of a commit.
+	MaxBatchSize int
+
+	// TargetLatency is the commit latency that the batches should stay
+	// below. The size of the next batch is doubled after a batch that was
+	// applied faster than TargetLatency, and halved after a batch that took
+	// longer. Defaults to 1 second.
+	TargetLatency time.Duration
+
+	// MaxMutationCells is the maximum number of mutated cells in a batch. A
+	// cell is a column of a row that is inserted or updated, or a row that
+	// is deleted. Defaults to 80,000, which is the maximum number of
+	// mutations that Cloud Spanner accepts in a single commit.
+	MaxMutationCells int
+
+	// MaxBytes is the maximum approximate size in bytes of the mutations in
+	// a batch. Defaults to 100 MiB, which is the maximum size of a commit.
+	MaxBytes int
+}
+
+// errInvalidAdaptiveBatchOptions returns error for AdaptiveBatchOptions with
+// a minimum batch size that is larger than the maximum batch size.
+func errInvalidAdaptiveBatchOptions(opts AdaptiveBatchOptions) error {
+	return spannerErrorf(codes.InvalidArgument, "MinBatchSize must not be larger than MaxBatchSize, got %d and %d", opts.MinBatchSize, opts.MaxBatchSize)
+}
+
+// withDefaults returns the options with default values applied.
+func (opts AdaptiveBatchOptions) withDefaults() (AdaptiveBatchOptions, error) {
+	if opts.MinBatchSize <= 0 {
+		opts.MinBatchSize = 1
+	}
+	if opts.MaxBatchSize > 0 && opts.MinBatchSize > opts.MaxBatchSize {
+		return opts, errInvalidAdaptiveBatchOptions(opts)
+	}
+	if opts.TargetLatency <= 0 {
+		opts.TargetLatency = time.Second
+	}
+	if opts.MaxMutationCells <= 0 || opts.MaxMutationCells > maxMutationCellsPerCommit {
+		opts.MaxMutationCells = maxMutationCellsPerCommit
+	}
+	if opts.MaxBytes <= 0 || opts.MaxBytes > maxCommitBytes {
+		opts.MaxBytes = maxCommitBytes
+	}
+	return opts, nil
+}
+
+// mutationCells returns the number of cells that are mutated by m.
+func mutationCells(m *Mutation) int {
+	if len(m.columns) == 0 {
+		return 1
+	}
+	return len(m.columns)
+}
+
+// nextBatch returns the number of mutations at the start of ms that form the
+// next batch. The batch contains at most size mutations, and stays within the
+// cell and byte limits of opts. A batch always contains at least one mutation.
+func (opts AdaptiveBatchOptions) nextBatch(ms []*Mutation, size int) int {
+	var cells, bytes, n int
+	for n < len(ms) && n < size {
+		c := mutationCells(ms[n])
+		var b int
+		// Mutations that cannot be encoded will fail the commit, and do not
+		// count towards the size.
+		if pb, err := ms[n].proto(); err == nil {
+			b = proto.Size(pb)
+		}
+		if n > 0 && (cells+c > opts.MaxMutationCells || bytes+b > opts.MaxBytes) {
+			break
+		}
+		cells += c
+		bytes += b
+		n++
+	}
+	return n
+}
+
+// nextSize returns the size of the batch after a batch of the given size
+// that held n mutations and was applied with the given latency. A batch can
+// hold fewer mutations than its size because of the cell and byte limits of
+// a commit, so the size never grows to more than twice the number of
+// mutations that the batch held.
+func (opts AdaptiveBatchOptions) nextSize(size, n int, latency time.Duration) int {
+	if latency > opts.TargetLatency {
+		size /= 2
+	} else if size = 2 * size; size > 2*n {
+		size = 2 * n
+	}
+	if size < opts.MinBatchSize {
+		size = opts.MinBatchSize
+	}
+	if opts.MaxBatchSize > 0 && size > opts.MaxBatchSize {
+		size = opts.MaxBatchSize
+	}
+	return size
+}
+
+// ApplyInAdaptiveBatches applies a list of mutations to the database in
+// batches with a size that adapts to the observed commit latency. The first
+// batch contains opts.MinBatchSize mutations. The size of each following
+// batch is doubled if the previous batch was applied within
+// opts.TargetLatency and halved otherwise, bounded by opts.MinBatchSize and
+// opts.MaxBatchSize, and by twice the number of mutations of the previous
+// batch. A batch never exceeds the number of mutated cells and
+// the size in bytes that Cloud Spanner accepts in a single commit.
+//
+// Each batch is applied atomically in a separate transaction by calling Apply
+// with the given options, which means that the list of mutations as a whole is
+// not applied atomically. ApplyInAdaptiveBatches returns the commit timestamp
+// of each batch that was applied, in the order of the batches. If a batch
+// fails, ApplyInAdaptiveBatches stops and returns the commit timestamps of the
+// batches that were applied before the failed batch together with the error.
+func (c *Client) ApplyInAdaptiveBatches(ctx context.Context, ms []*Mutation, opts AdaptiveBatchOptions, applyOpts ...ApplyOption) (commitTimestamps []time.Time, err error) {
+	opts, err = opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.ApplyInAdaptiveBatches")
+	defer func() { trace.EndSpan(ctx, err) }()
+	size := opts.MinBatchSize
+	for len(ms) > 0 {
+		n := opts.nextBatch(ms, size)
+		start := time.Now()
+		ts, err := c.Apply(ctx, ms[:n], applyOpts...)
+		if err != nil {
+			return commitTimestamps, err
+		}
+		commitTimestamps = append(commitTimestamps, ts)
+		ms = ms[n:]
+		size = opts.nextSize(size, n, time.Since(start))
+		trace.TracePrintf(ctx, map[string]interface{}{"batchSize": n}, "Applied batch, next batch size is %d", size)
+	}
+	return commitTimestamps, nil
+}
+
+// ApplyGroupResult is the result of applying a group of mutations with
+// ApplyGroups.
+type ApplyGroupResult struct {
+	// CommitTimestamp is the commit timestamp of the transaction that applied
+	// the group. It is zero if the group could not be applied.
+	CommitTimestamp time.Time
+	// Err is the error that was returned when the group was applied, or nil
+	// if the group was applied successfully.
+	Err error
+}
+
+// ApplyGroups applies multiple groups of mutations to the database. Each
+// group is applied atomically in a separate transaction by calling Apply with
+// the given options, which means that the groups as a whole are not applied
+// atomically. The groups are applied in parallel with sessions from the
+// session pool of the client. The number of groups that are applied in
+// parallel is limited by the MaxConcurrency option, and defaults to the
+// maximum number of sessions in the session pool.
+//
+// ApplyGroups returns the result of each group in the same order as the
+// groups. A failed group does not stop ApplyGroups from applying the other
+// groups, but groups that have not been started when ctx is done fail with
+// the error of the context.
+func (c *Client) ApplyGroups(ctx context.Context, groups [][]*Mutation, opts ...ApplyOption) []ApplyGroupResult {
+	ao := &applyOption{}
+	for _, opt := range opts {
+		opt(ao)
+	}
+	workers := ao.maxConcurrency
+	if maxOpened := int(c.idleSessions.MaxOpened); maxOpened > 0 && (workers <= 0 || workers > maxOpened) {
+		workers = maxOpened
+	}
+	if workers <= 0 || workers > len(groups) {
+		workers = len(groups)
+	}
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.ApplyGroups")
+	defer trace.EndSpan(ctx, nil)
+
+	results := make([]ApplyGroupResult, len(groups))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ts, err := c.Apply(ctx, groups[i], opts...)
+				results[i] = ApplyGroupResult{CommitTimestamp: ts, Err: err}
+			}
+		}()
+	}
+	for i := range groups {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClient_ApplyInAdaptiveBatches(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	var ms []*Mutation
+	for i := 0; i < 60; i++ {
+		ms = append(ms, Insert("Accounts", []string{"AccountId"}, []interface{}{int64(i)}))
+	}
+	timestamps, err := client.ApplyInAdaptiveBatches(context.Background(), ms, AdaptiveBatchOptions{
+		MinBatchSize:  2,
+		MaxBatchSize:  16,
+		TargetLatency: time.Minute,
+	}, ApplyAtLeastOnce())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mutationCounts []int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if commit, ok := req.(*sppb.CommitRequest); ok {
+			mutationCounts = append(mutationCounts, len(commit.Mutations))
+		}
+	}
+	// All commits are faster than the target latency, so the batch size
+	// grows until it reaches the maximum.
+	if g, w := mutationCounts, []int{2, 4, 8, 16, 16, 14}; !testEqual(g, w) {
+		t.Fatalf("mutations per commit mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(timestamps), len(mutationCounts); g != w {
+		t.Fatalf("commit timestamp count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	_, err = client.ApplyInAdaptiveBatches(context.Background(), ms, AdaptiveBatchOptions{MinBatchSize: 10, MaxBatchSize: 5})
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ApplyGroups(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	var groups [][]*Mutation
+	for i := 0; i < 10; i++ {
+		groups = append(groups, []*Mutation{
+			Insert("Accounts", []string{"AccountId"}, []interface{}{int64(i)}),
+			Insert("Accounts", []string{"AccountId"}, []interface{}{int64(i + 100)}),
+		})
+	}
+	// The value of this group cannot be encoded.
+	groups[3] = []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{make(chan int)})}
+
+	results := client.ApplyGroups(context.Background(), groups, MaxConcurrency(2))
+	if g, w := len(results), len(groups); g != w {
+		t.Fatalf("result count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	for i, res := range results {
+		if i == 3 {
+			if g, w := ErrCode(res.Err), codes.InvalidArgument; g != w {
+				t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Fatalf("group %d failed: %v", i, res.Err)
+		}
+		if res.CommitTimestamp.IsZero() {
+			t.Fatalf("group %d has no commit timestamp", i)
+		}
+	}
+	var commits, sessions int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		switch req.(type) {
+		case *sppb.CommitRequest:
+			commits++
+		case *sppb.CreateSessionRequest:
+			sessions++
+		}
+	}
+	if g, w := commits, len(groups)-1; g != w {
+		t.Fatalf("commit count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// At most two groups are applied in parallel, so the session pool does
+	// not need more than two sessions.
+	if sessions > 2 {
+		t.Fatalf("too many sessions created\nGot: %v\nWant at most: %v", sessions, 2)
+	}
+}
+
+func TestAdaptiveBatchOptionsNextSize(t *testing.T) {
+	opts, err := AdaptiveBatchOptions{MinBatchSize: 4, MaxBatchSize: 32, TargetLatency: time.Second}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		size    int
+		n       int
+		latency time.Duration
+		want    int
+	}{
+		{4, 4, time.Millisecond, 8},
+		{16, 16, time.Second, 32},
+		{32, 32, time.Millisecond, 32},
+		{32, 32, 2 * time.Second, 16},
+		{6, 6, 2 * time.Second, 4},
+		// The batch held fewer mutations than its size because of the cell
+		// and byte limits of a commit.
+		{16, 3, time.Millisecond, 6},
+	} {
+		if g, w := opts.nextSize(test.size, test.n, test.latency), test.want; g != w {
+			t.Errorf("nextSize(%v, %v, %v) mismatch\nGot: %v\nWant: %v", test.size, test.n, test.latency, g, w)
+		}
+	}
+
+	// Without a maximum batch size, the size does not grow beyond the
+	// limits of a commit when many batches are applied.
+	opts, err = AdaptiveBatchOptions{}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := opts.MinBatchSize
+	for i := 0; i < 100; i++ {
+		size = opts.nextSize(size, 10, time.Millisecond)
+	}
+	if g, w := size, 20; g != w {
+		t.Fatalf("batch size mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestAdaptiveBatchOptionsNextBatch(t *testing.T) {
+	opts, err := AdaptiveBatchOptions{MaxMutationCells: 10}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each mutation mutates 3 cells.
+	var ms []*Mutation
+	for i := 0; i < 10; i++ {
+		ms = append(ms, Insert("Accounts", []string{"AccountId", "Name", "Balance"}, []interface{}{int64(i), "name", int64(0)}))
+	}
+	if g, w := opts.nextBatch(ms, 100), 3; g != w {
+		t.Fatalf("batch size mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := opts.nextBatch(ms, 2), 2; g != w {
+		t.Fatalf("batch size mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// A batch always contains at least one mutation.
+	opts.MaxMutationCells = 1
+	if g, w := opts.nextBatch(ms, 100), 1; g != w {
+		t.Fatalf("batch size mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	opts.MaxMutationCells = maxMutationCellsPerCommit
+	opts.MaxBytes = 1
+	if g, w := opts.nextBatch(ms, 100), 1; g != w {
+		t.Fatalf("batch size mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
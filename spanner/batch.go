@@ -19,12 +19,15 @@ package spanner
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/gob"
 	"log"
+	"math"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 // BatchReadOnlyTransaction is a ReadOnlyTransaction that allows for exporting
@@ -76,6 +79,39 @@ type PartitionOptions struct {
 	PartitionBytes int64
 	// The desired maximum number of partitions to return.
 	MaxPartitions int64
+	// The desired number of rows for each partition generated. If set and
+	// PartitionBytes is not set, PartitionQuery computes PartitionBytes from
+	// the estimated size of a row of the result of the query. The size can
+	// only be estimated if all columns of the result have a fixed size, such
+	// as INT64, FLOAT64, BOOL, DATE and TIMESTAMP columns. If the size cannot
+	// be estimated, the option is ignored. The option is also ignored by
+	// PartitionRead and PartitionReadUsingIndex.
+	DesiredRowsPerPartition int64
+}
+
+// estimatedRowBytes returns the estimated size in bytes of a row with the
+// given fields. It returns false if the size cannot be estimated, because the
+// row contains a column without a fixed size.
+func estimatedRowBytes(fields []*sppb.StructType_Field) (int64, bool) {
+	if len(fields) == 0 {
+		return 0, false
+	}
+	var size int64
+	for _, f := range fields {
+		switch f.GetType().GetCode() {
+		case sppb.TypeCode_BOOL:
+			size++
+		case sppb.TypeCode_DATE:
+			size += 4
+		case sppb.TypeCode_INT64, sppb.TypeCode_FLOAT64:
+			size += 8
+		case sppb.TypeCode_TIMESTAMP:
+			size += 12
+		default:
+			return 0, false
+		}
+	}
+	return size, true
 }
 
 // toProto converts a spanner.PartitionOptions into a sppb.PartitionOptions
@@ -152,6 +188,24 @@ func (t *BatchReadOnlyTransaction) PartitionQuery(ctx context.Context, statement
 	if err != nil {
 		return nil, err
 	}
+	if opt.DesiredRowsPerPartition > 0 && opt.PartitionBytes == 0 {
+		// Get the row type of the query without executing it. Fall back to
+		// the default partition size if the row type cannot be determined.
+		plan, err := client.ExecuteSql(ctx, &sppb.ExecuteSqlRequest{
+			Session:     sid,
+			Transaction: ts,
+			Sql:         statement.SQL,
+			Params:      params,
+			ParamTypes:  paramTypes,
+			QueryMode:   sppb.ExecuteSqlRequest_PLAN,
+		})
+		if err == nil {
+			rowBytes, ok := estimatedRowBytes(plan.GetMetadata().GetRowType().GetFields())
+			if ok && rowBytes <= math.MaxInt64/opt.DesiredRowsPerPartition {
+				opt.PartitionBytes = rowBytes * opt.DesiredRowsPerPartition
+			}
+		}
+	}
 
 	// request Partitions
 	req := &sppb.PartitionQueryRequest{
@@ -261,12 +315,15 @@ func (t *BatchReadOnlyTransaction) Execute(ctx context.Context, p *Partition) *R
 			return client.ExecuteStreamingSql(ctx, p.qreq)
 		}
 	}
-	return stream(
+	iter := stream(
 		contextWithOutgoingMetadata(ctx, sh.getMetadata()),
 		sh.session.logger,
 		rpc,
 		t.setTimestamp,
 		t.release)
+	iter.streamd.isRetryable = sh.session.isRetryableStreamError
+	iter.streamd.retryGate = sh.session.retryGate
+	return iter
 }
 
 // MarshalBinary implements BinaryMarshaler.
@@ -325,8 +382,197 @@ func (p Partition) MarshalBinary() (data []byte, err error) {
 	return buf.Bytes(), nil
 }
 
-// UnmarshalBinary implements BinaryUnmarshaler.
+// PartitionFormat is a serialization format for a Partition.
+type PartitionFormat int
+
+const (
+	// PartitionFormatGob is the format that is produced by
+	// Partition.MarshalBinary. It is not versioned, and partitions that are
+	// serialized in this format can only be read by client versions that use
+	// the same format.
+	PartitionFormatGob PartitionFormat = iota
+	// PartitionFormatVersioned is a versioned format that is based on the
+	// protobuf wire format. Fields that are added in later versions of the
+	// format are ignored by client versions that do not know them, and a
+	// partition that was serialized in a newer, incompatible version of the
+	// format is rejected with a clear error by Partition.UnmarshalBinary.
+	PartitionFormatVersioned
+)
+
+const (
+	// partitionVersionMarker is the first byte of a partition that is
+	// serialized in PartitionFormatVersioned. A gob stream never starts with a
+	// zero byte, as the first byte is the length of a non-empty message.
+	partitionVersionMarker = 0
+	// partitionFormatVersion is the current version of
+	// PartitionFormatVersioned.
+	partitionFormatVersion = 1
+
+	// Field numbers of the fields of PartitionFormatVersioned.
+	partitionFieldToken        = 1
+	partitionFieldReadRequest  = 2
+	partitionFieldQueryRequest = 3
+)
+
+// errUnknownPartitionFormat returns error for an invalid value for
+// PartitionFormat.
+func errUnknownPartitionFormat(f PartitionFormat) error {
+	return spannerErrorf(codes.InvalidArgument, "unknown partition format %d", f)
+}
+
+// errUnsupportedPartitionVersion returns error for a partition that was
+// serialized in a version of PartitionFormatVersioned that is not supported by
+// this client.
+func errUnsupportedPartitionVersion(v uint64) error {
+	return spannerErrorf(codes.InvalidArgument, "partition was serialized with format version %d, but this client only supports versions up to %d", v, partitionFormatVersion)
+}
+
+// errInvalidPartitionData returns error for a partition that cannot be
+// decoded.
+func errInvalidPartitionData(msg string) error {
+	return spannerErrorf(codes.InvalidArgument, "invalid serialized partition: %s", msg)
+}
+
+// MarshalBinaryWithFormat serializes the partition in the given format. The
+// result can be deserialized with UnmarshalBinary, which detects the format.
+// Use PartitionFormatVersioned for partitions that are stored or exchanged
+// between processes that may run different versions of the client library.
+func (p Partition) MarshalBinaryWithFormat(f PartitionFormat) ([]byte, error) {
+	switch f {
+	case PartitionFormatGob:
+		return p.MarshalBinary()
+	case PartitionFormatVersioned:
+		return p.marshalVersioned()
+	}
+	return nil, errUnknownPartitionFormat(f)
+}
+
+// marshalVersioned serializes the partition in PartitionFormatVersioned.
+func (p Partition) marshalVersioned() ([]byte, error) {
+	data := []byte{partitionVersionMarker}
+	data = appendUvarint(data, partitionFormatVersion)
+	data = appendBytesField(data, partitionFieldToken, p.pt)
+	field, req := partitionFieldQueryRequest, proto.Message(p.qreq)
+	if p.rreq != nil {
+		field, req = partitionFieldReadRequest, p.rreq
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return appendBytesField(data, field, b), nil
+}
+
+// unmarshalVersioned deserializes a partition that was serialized in
+// PartitionFormatVersioned. data must not include the version marker.
+func (p *Partition) unmarshalVersioned(data []byte) error {
+	version, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+	if version == 0 || version > partitionFormatVersion {
+		return errUnsupportedPartitionVersion(version)
+	}
+	*p = Partition{}
+	for len(data) > 0 {
+		var key uint64
+		if key, data, err = readUvarint(data); err != nil {
+			return err
+		}
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case proto.WireVarint:
+			// No fields of this type are known in this version.
+			if _, data, err = readUvarint(data); err != nil {
+				return err
+			}
+			continue
+		case proto.WireFixed64:
+			// No fields of this type are known in this version.
+			if data, err = skipFixed(data, 8); err != nil {
+				return err
+			}
+			continue
+		case proto.WireFixed32:
+			// No fields of this type are known in this version.
+			if data, err = skipFixed(data, 4); err != nil {
+				return err
+			}
+			continue
+		case proto.WireBytes:
+		default:
+			return errInvalidPartitionData("unsupported wire type")
+		}
+		var b []byte
+		if b, data, err = readBytes(data); err != nil {
+			return err
+		}
+		switch field {
+		case partitionFieldToken:
+			p.pt = append([]byte(nil), b...)
+		case partitionFieldReadRequest:
+			p.rreq = &sppb.ReadRequest{}
+			if err := proto.Unmarshal(b, p.rreq); err != nil {
+				return err
+			}
+		case partitionFieldQueryRequest:
+			p.qreq = &sppb.ExecuteSqlRequest{}
+			if err := proto.Unmarshal(b, p.qreq); err != nil {
+				return err
+			}
+		}
+		// Unknown fields are skipped for forward compatibility.
+	}
+	if p.rreq == nil && p.qreq == nil {
+		return errInvalidPartitionData("missing read or query request")
+	}
+	return nil
+}
+
+func appendUvarint(data []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(data, buf[:n]...)
+}
+
+func appendBytesField(data []byte, field int, b []byte) []byte {
+	data = appendUvarint(data, uint64(field)<<3|proto.WireBytes)
+	data = appendUvarint(data, uint64(len(b)))
+	return append(data, b...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errInvalidPartitionData("invalid varint")
+	}
+	return v, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	l, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l > uint64(len(data)) {
+		return nil, nil, errInvalidPartitionData("truncated field")
+	}
+	return data[:l], data[l:], nil
+}
+
+func skipFixed(data []byte, n int) ([]byte, error) {
+	if n > len(data) {
+		return nil, errInvalidPartitionData("truncated field")
+	}
+	return data[n:], nil
+}
+
+// UnmarshalBinary implements BinaryUnmarshaler. It accepts partitions that
+// were serialized in any of the formats of PartitionFormat.
 func (p *Partition) UnmarshalBinary(data []byte) error {
+	if len(data) > 0 && data[0] == partitionVersionMarker {
+		return p.unmarshalVersioned(data[1:])
+	}
 	var (
 		isReadPartition bool
 		d               []byte
@@ -17,10 +17,14 @@ limitations under the License.
 package spanner
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"github.com/golang/protobuf/proto"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 func TestPartitionRoundTrip(t *testing.T) {
@@ -36,6 +40,79 @@ func TestPartitionRoundTrip(t *testing.T) {
 	}
 }
 
+func TestPartitionRoundTripWithFormat(t *testing.T) {
+	t.Parallel()
+	for _, format := range []PartitionFormat{PartitionFormatGob, PartitionFormatVersioned} {
+		for i, want := range []Partition{
+			{pt: []byte("token"), rreq: &sppb.ReadRequest{Table: "t", PartitionToken: []byte("token")}},
+			{pt: []byte("token"), qreq: &sppb.ExecuteSqlRequest{Sql: "sql", PartitionToken: []byte("token")}},
+		} {
+			data, err := want.MarshalBinaryWithFormat(format)
+			if err != nil {
+				t.Fatalf("format %v, #%d: encoding failed %v", format, i, err)
+			}
+			var got Partition
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("format %v, #%d: decoding failed %v", format, i, err)
+			}
+			if !testEqual(got, want) {
+				t.Errorf("format %v, #%d: partition mismatch\nGot: %#v\nWant: %#v", format, i, got, want)
+			}
+		}
+	}
+}
+
+func TestPartitionVersionedFormat(t *testing.T) {
+	t.Parallel()
+	want := Partition{pt: []byte("token"), qreq: &sppb.ExecuteSqlRequest{Sql: "sql"}}
+	data, err := want.MarshalBinaryWithFormat(PartitionFormatVersioned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields that are added in a later version of the format are ignored.
+	withUnknownFields := appendBytesField(append([]byte(nil), data...), 15, []byte("unknown"))
+	withUnknownFields = appendUvarint(withUnknownFields, 16<<3|proto.WireVarint)
+	withUnknownFields = appendUvarint(withUnknownFields, 42)
+	withUnknownFields = appendUvarint(withUnknownFields, 17<<3|proto.WireFixed64)
+	withUnknownFields = append(withUnknownFields, 1, 2, 3, 4, 5, 6, 7, 8)
+	withUnknownFields = appendUvarint(withUnknownFields, 18<<3|proto.WireFixed32)
+	withUnknownFields = append(withUnknownFields, 1, 2, 3, 4)
+	var got Partition
+	if err := got.UnmarshalBinary(withUnknownFields); err != nil {
+		t.Fatal(err)
+	}
+	if !testEqual(got, want) {
+		t.Fatalf("partition mismatch\nGot: %#v\nWant: %#v", got, want)
+	}
+
+	// A truncated unknown field is rejected.
+	truncated := appendUvarint(append([]byte(nil), data...), 17<<3|proto.WireFixed64)
+	truncated = append(truncated, 1, 2, 3)
+	if err := got.UnmarshalBinary(truncated); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("missing expected error for truncated unknown field, got %v", err)
+	}
+
+	// A partition with an unknown version is rejected.
+	bogus := append([]byte{partitionVersionMarker}, appendUvarint(nil, partitionFormatVersion+1)...)
+	bogus = append(bogus, data[2:]...)
+	err = got.UnmarshalBinary(bogus)
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := err, errUnsupportedPartitionVersion(partitionFormatVersion+1); !testEqual(g, w) {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Truncated data is rejected.
+	if err := got.UnmarshalBinary(data[:len(data)-1]); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("missing expected error for truncated data, got %v", err)
+	}
+	if _, err := want.MarshalBinaryWithFormat(PartitionFormat(-1)); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("missing expected error for unknown format, got %v", err)
+	}
+}
+
 func TestBROTIDRoundTrip(t *testing.T) {
 	t.Parallel()
 	tm := time.Now()
@@ -71,3 +148,75 @@ func serdesPartition(t *testing.T, i int, p1 *Partition) (p2 Partition) {
 	}
 	return p2
 }
+
+func TestPartitionQueryDesiredRowsPerPartition(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	const fixedWidthSQL = "SELECT Id, Score, Active FROM Scores"
+	server.TestSpanner.PutStatementResult(fixedWidthSQL, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("Id", intType()),
+						mkField("Score", floatType()),
+						mkField("Active", boolType()),
+					},
+				},
+			},
+		},
+	})
+	txn, err := client.BatchReadOnlyTransaction(ctx, StrongRead())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Close()
+
+	for _, test := range []struct {
+		sql      string
+		opt      PartitionOptions
+		wantPlan bool
+		want     int64
+	}{
+		// 8 bytes for INT64 and FLOAT64 and 1 byte for BOOL.
+		{fixedWidthSQL, PartitionOptions{DesiredRowsPerPartition: 100}, true, 1700},
+		// The size of STRING columns cannot be estimated.
+		{SelectSingerIDAlbumIDAlbumTitleFromAlbums, PartitionOptions{DesiredRowsPerPartition: 100}, true, 0},
+		// An explicit partition size takes precedence.
+		{fixedWidthSQL, PartitionOptions{PartitionBytes: 1000, DesiredRowsPerPartition: 100}, false, 1000},
+		{fixedWidthSQL, PartitionOptions{}, false, 0},
+	} {
+		drainRequestsFromServer(server.TestSpanner)
+		// The mock server does not implement PartitionQuery, but records the
+		// request.
+		txn.PartitionQuery(ctx, NewStatement(test.sql), test.opt)
+		var (
+			gotPlan bool
+			got     *sppb.PartitionQueryRequest
+		)
+		for _, req := range drainRequestsFromServer(server.TestSpanner) {
+			switch req := req.(type) {
+			case *sppb.ExecuteSqlRequest:
+				if req.QueryMode != sppb.ExecuteSqlRequest_PLAN {
+					t.Fatalf("%v: query mode mismatch\nGot: %v\nWant: %v", test.opt, req.QueryMode, sppb.ExecuteSqlRequest_PLAN)
+				}
+				gotPlan = true
+			case *sppb.PartitionQueryRequest:
+				got = req
+			}
+		}
+		if g, w := gotPlan, test.wantPlan; g != w {
+			t.Fatalf("%v: plan request mismatch\nGot: %v\nWant: %v", test.opt, g, w)
+		}
+		if got == nil {
+			t.Fatalf("%v: missing PartitionQueryRequest", test.opt)
+		}
+		if g, w := got.PartitionOptions.PartitionSizeBytes, test.want; g != w {
+			t.Fatalf("%v: partition size mismatch\nGot: %v\nWant: %v", test.opt, g, w)
+		}
+	}
+}
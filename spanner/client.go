@@ -22,17 +22,25 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/internal/trace"
 	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
 	vkit "cloud.google.com/go/spanner/apiv1"
+	"github.com/golang/protobuf/proto"
+	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/option"
 	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	field_mask "google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	// Register the gzip compressor for ClientConfig.Compression.
+	_ "google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -81,6 +89,34 @@ type Client struct {
 	sc           *sessionClient
 	idleSessions *sessionPool
 	logger       *log.Logger
+	// rpcLog contains the most recent RPCs of the client. It is nil if
+	// ClientConfig.RecentRPCBufferSize is 0.
+	rpcLog *rpcLog
+	// commitRetry determines which Internal errors of Commit RPCs are retried.
+	commitRetry CommitRetryConfig
+	// maxCommitAttempts is the default maximum number of attempts of a
+	// read-write transaction.
+	maxCommitAttempts int
+	// defaultSingleBound is the TimestampBound of the transactions that are
+	// returned by Single.
+	defaultSingleBound TimestampBound
+
+	// mu protects activeTxns, abortedRetries, minCommitTimestamp,
+	// maxCommitTimestamp and opGroups.
+	mu sync.Mutex
+	// activeTxns contains the read-write transactions that are currently
+	// running on this client.
+	activeTxns map[*activeTransaction]struct{}
+	// abortedRetries is the number of times that a read-write transaction of
+	// this client has been retried because it was aborted.
+	abortedRetries uint64
+	// minCommitTimestamp and maxCommitTimestamp are the smallest and largest
+	// commit timestamps of the transactions of this client.
+	minCommitTimestamp time.Time
+	maxCommitTimestamp time.Time
+	// opGroups contains the contexts that have been registered with an
+	// operation group of this client, keyed by group.
+	opGroups map[string]map[*operationContext]struct{}
 }
 
 // ClientConfig has configurations for the client.
@@ -97,6 +133,123 @@ type ClientConfig struct {
 	// for more info.
 	SessionLabels map[string]string
 
+	// Compression is the name of the gRPC compressor that is used for all
+	// RPCs of the client, for example "gzip". Compression reduces the number
+	// of bytes that are sent for large mutations and read by large queries,
+	// at the cost of CPU time for compressing and decompressing the messages
+	// on both the client and Cloud Spanner. It is therefore mostly useful for
+	// clients with a slow or metered network connection to Cloud Spanner.
+	// The compressor must be registered with gRPC; the gzip compressor is
+	// always registered.
+	//
+	// Defaults to "", which means that messages are not compressed.
+	Compression string
+
+	// CompressionThreshold is the minimum size in bytes of a request message
+	// for it to be compressed. Compressing small messages costs more CPU than
+	// it saves in bandwidth, so requests that are smaller than the threshold
+	// are sent uncompressed. The threshold only has an effect if compression
+	// has been enabled for the client, for example with Compression or
+	// option.WithGRPCDialOption(grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name))),
+	// and only applies to unary RPCs.
+	//
+	// Defaults to 0, which means that all requests are compressed.
+	CompressionThreshold int
+
+	// DialTimeout is the maximum amount of time that NewClientWithConfig
+	// waits for the gRPC channels to Cloud Spanner to be connected. If it is
+	// set, NewClientWithConfig blocks until all channels are ready, and
+	// connection attempts that fail with transient errors are retried within
+	// the timeout. If the channels cannot be connected within the timeout,
+	// NewClientWithConfig returns an error with code DeadlineExceeded.
+	//
+	// Defaults to 0, which means that NewClientWithConfig does not wait for
+	// the channels to be connected, and connection errors are only returned
+	// by the first RPC that is executed.
+	DialTimeout time.Duration
+
+	// RecentRPCBufferSize is the number of recent RPCs that the client keeps
+	// in memory for troubleshooting. The RPCs can be retrieved with
+	// Client.RecentRPCs. Each entry contains the method name, start time,
+	// latency and status code of an RPC.
+	//
+	// Defaults to 0, which means that no RPCs are recorded.
+	RecentRPCBufferSize int
+
+	// OnEndpointFallback is called when resource-based routing is enabled and
+	// the client falls back to the default or user-specified endpoint,
+	// because it was not allowed to fetch the instance-specific endpoint. The
+	// reason is the error that was returned when fetching the endpoint. The
+	// callback can for example be used to record a metric for the fallback.
+	//
+	// Only a PermissionDenied error causes a fallback. Any other error that is
+	// returned when fetching the endpoint is returned by NewClientWithConfig,
+	// and Unavailable errors are retried until the context that is passed to
+	// NewClientWithConfig is done.
+	//
+	// Defaults to nil, which means that the fallback is only logged.
+	OnEndpointFallback func(reason error)
+
+	// EndpointResolver returns the endpoint of Cloud Spanner that the client
+	// should use for the given database, for example the address of an
+	// on-premises proxy. If it is set, it overrides both the default endpoint
+	// and resource-based routing, and takes precedence over an endpoint that
+	// is set with option.WithEndpoint. If the resolver returns an empty
+	// endpoint, the default or user-specified endpoint is used. If it returns
+	// an error, NewClientWithConfig fails with that error. The resolver is not
+	// used if SPANNER_EMULATOR_HOST has been set.
+	//
+	// If SessionPoolConfig.StaleSessionThreshold is set, the resolver is
+	// called again each time the session pool replaces its stale sessions,
+	// and the client connects to the returned endpoint if it has changed.
+	// An empty endpoint then keeps the current endpoint.
+	//
+	// Defaults to nil, which means that the endpoint is determined by the
+	// client options and resource-based routing.
+	EndpointResolver func(database string) (endpoint string, err error)
+
+	// CommitRetry configures the retrying of Commit RPCs that fail with an
+	// Internal error. By default, Commit RPCs are not retried for Internal
+	// errors.
+	CommitRetry CommitRetryConfig
+
+	// IsRetryableStreamError returns true if the given error that was
+	// returned by a streaming read or query should be retried. It is called
+	// for errors that are not retried by default, which means that it can
+	// only add retryable errors, for example:
+	//
+	// 	func(err error) bool {
+	// 		return spanner.ErrCode(err) == codes.Internal && strings.Contains(err.Error(), "RST_STREAM")
+	// 	}
+	//
+	// A stream is retried from the last resume token it received, or from
+	// scratch if it did not receive any resume token yet. A stream cannot be
+	// retried if it has returned rows to the caller that were not followed
+	// by a resume token. IsRetryableStreamError is not called for Aborted
+	// errors, which are retried by retrying the whole read-write transaction.
+	//
+	// Defaults to nil, which means that only the default retryable errors
+	// are retried.
+	IsRetryableStreamError func(err error) bool
+
+	// MaxCommitAttempts is the maximum number of times that a read-write
+	// transaction is attempted if it is aborted by Cloud Spanner. The
+	// transaction returns the Aborted error of the last attempt if it is
+	// still aborted after MaxCommitAttempts attempts. This is the default for
+	// all read-write transactions of the client, and can be overridden for a
+	// single transaction with ReadWriteTransactionOptions.MaxAttempts.
+	//
+	// Defaults to 0, which means that aborted transactions are retried until
+	// they succeed, fail with a different error or the context is done.
+	MaxCommitAttempts int
+
+	// DefaultSingleBound is the TimestampBound that is used by the
+	// transactions that are returned by Client.Single. It can be overridden
+	// for a single transaction with ReadOnlyTransaction.WithTimestampBound.
+	//
+	// Defaults to the zero value, which is a strong bound.
+	DefaultSingleBound TimestampBound
+
 	// logger is the logger to use for this client. If it is nil, all logging
 	// will be directed to the standard logger.
 	logger *log.Logger
@@ -109,6 +262,26 @@ func errDial(ci int, err error) error {
 	return e
 }
 
+// errResolveEndpoint returns error for a ClientConfig.EndpointResolver that
+// failed to resolve the endpoint of a database.
+func errResolveEndpoint(database string, err error) error {
+	e := toSpannerError(err).(*Error)
+	e.decorate(fmt.Sprintf("failed to resolve endpoint for database %q", database))
+	return e
+}
+
+// errUnknownCompressor returns error for a ClientConfig.Compression that is
+// not the name of a registered gRPC compressor.
+func errUnknownCompressor(name string) error {
+	return spannerErrorf(codes.InvalidArgument, "compressor %q is not registered with gRPC", name)
+}
+
+// errDialTimeout returns error for not being able to connect to Cloud Spanner
+// within ClientConfig.DialTimeout.
+func errDialTimeout(ci int, timeout time.Duration) error {
+	return spannerErrorf(codes.DeadlineExceeded, "dialing fails for channel[%v]: could not connect to Cloud Spanner within %v", ci, timeout)
+}
+
 func contextWithOutgoingMetadata(ctx context.Context, md metadata.MD) context.Context {
 	existing, ok := metadata.FromOutgoingContext(ctx)
 	if ok {
@@ -153,6 +326,20 @@ func getInstanceEndpoint(ctx context.Context, database string, opts ...option.Cl
 	return "", nil
 }
 
+// compressionThresholdInterceptor returns a unary client interceptor that
+// disables compression for request messages that are smaller than threshold
+// bytes.
+func compressionThresholdInterceptor(threshold int) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if m, ok := req.(proto.Message); ok && proto.Size(m) < threshold {
+			// The identity compressor overrides any compressor that has been
+			// set as a default call option.
+			opts = append(opts, grpc.UseCompressor(encoding.Identity))
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
 // NewClient creates a client to a database. A valid database name has the
 // form projects/PROJECT_ID/instances/INSTANCE_ID/databases/DATABASE_ID. It uses
 // a default configuration.
@@ -180,11 +367,15 @@ func NewClientWithConfig(ctx context.Context, database string, config ClientConf
 	if err := validDatabaseName(database); err != nil {
 		return nil, err
 	}
+	if config.Compression != "" && encoding.GetCompressor(config.Compression) == nil {
+		return nil, errUnknownCompressor(config.Compression)
+	}
 
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.NewClient")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.NewClient")
 	defer func() { trace.EndSpan(ctx, err) }()
 
 	// Append emulator options if SPANNER_EMULATOR_HOST has been set.
+	var resolvedEndpoint string
 	if emulatorAddr := os.Getenv("SPANNER_EMULATOR_HOST"); emulatorAddr != "" {
 		emulatorOpts := []option.ClientOption{
 			option.WithEndpoint(emulatorAddr),
@@ -192,6 +383,14 @@ func NewClientWithConfig(ctx context.Context, database string, config ClientConf
 			option.WithoutAuthentication(),
 		}
 		opts = append(opts, emulatorOpts...)
+	} else if config.EndpointResolver != nil {
+		resolvedEndpoint, err = config.EndpointResolver(database)
+		if err != nil {
+			return nil, errResolveEndpoint(database, err)
+		}
+		if resolvedEndpoint != "" {
+			opts = append(opts, option.WithEndpoint(resolvedEndpoint))
+		}
 	} else if os.Getenv("GOOGLE_CLOUD_SPANNER_ENABLE_RESOURCE_BASED_ROUTING") == "true" {
 		// Fetch the instance-specific endpoint.
 		reqOpts := []option.ClientOption{option.WithEndpoint(endpoint)}
@@ -200,7 +399,8 @@ func NewClientWithConfig(ctx context.Context, database string, config ClientConf
 
 		if err != nil {
 			// If there is a PermissionDenied error, fall back to use the global endpoint
-			// or the user-specified endpoint.
+			// or the user-specified endpoint. Other errors are not a reason to
+			// fall back, see ClientConfig.OnEndpointFallback.
 			if status.Code(err) == codes.PermissionDenied {
 				logf(config.logger, `
 Warning: The client library attempted to connect to an endpoint closer to your
@@ -210,6 +410,9 @@ which may result in increased latency. We recommend including the scope
 https://www.googleapis.com/auth/spanner.admin so that the client library can
 get an instance-specific endpoint and efficiently route requests.
 `)
+				if config.OnEndpointFallback != nil {
+					config.OnEndpointFallback(err)
+				}
 			} else {
 				return nil, err
 			}
@@ -231,18 +434,45 @@ get an instance-specific endpoint and efficiently route requests.
 			),
 		),
 	}
+	if config.Compression != "" {
+		allOpts = append(allOpts, option.WithGRPCDialOption(
+			grpc.WithDefaultCallOptions(grpc.UseCompressor(config.Compression)),
+		))
+	}
+	if config.CompressionThreshold > 0 {
+		allOpts = append(allOpts, option.WithGRPCDialOption(
+			grpc.WithChainUnaryInterceptor(compressionThresholdInterceptor(config.CompressionThreshold)),
+		))
+	}
+	if config.DialTimeout > 0 {
+		// gRPC keeps retrying to connect with backoff until the channel is
+		// ready or the timeout has been reached.
+		allOpts = append(allOpts,
+			option.WithGRPCDialOption(grpc.WithBlock()),
+			option.WithGRPCDialOption(grpc.WithTimeout(config.DialTimeout)),
+		)
+	}
+	var rl *rpcLog
+	if config.RecentRPCBufferSize > 0 {
+		rl = newRPCLog(config.RecentRPCBufferSize)
+		allOpts = append(allOpts,
+			option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(rl.unaryInterceptor())),
+			option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(rl.streamInterceptor())),
+		)
+	}
+	var detector *staleSessionDetector
+	if config.StaleSessionThreshold > 0 {
+		detector = &staleSessionDetector{}
+		allOpts = append(allOpts,
+			option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(detector.unaryInterceptor())),
+			option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(detector.streamInterceptor())),
+		)
+	}
 	allOpts = append(allOpts, opts...)
 
-	// TODO(deklerk): This should be replaced with a balancer with
-	// config.NumChannels connections, instead of config.NumChannels
-	// clients.
-	var clients []*vkit.Client
-	for i := 0; i < config.NumChannels; i++ {
-		client, err := vkit.NewClient(ctx, allOpts...)
-		if err != nil {
-			return nil, errDial(i, err)
-		}
-		clients = append(clients, client)
+	clients, err := dialClients(ctx, config, allOpts)
+	if err != nil {
+		return nil, err
 	}
 
 	// TODO(loite): Remove as the original map cannot be changed by the user
@@ -254,6 +484,10 @@ get an instance-specific endpoint and efficiently route requests.
 	}
 	// Create a session client.
 	sc := newSessionClient(clients, database, sessionLabels, metadata.Pairs(resourcePrefixHeader, database), config.logger)
+	sc.isRetryableStreamError = config.IsRetryableStreamError
+	if config.EndpointResolver != nil && config.StaleSessionThreshold > 0 {
+		sc.redial = newRedialer(database, config, allOpts, resolvedEndpoint)
+	}
 	// Create a session pool.
 	config.SessionPoolConfig.sessionLabels = sessionLabels
 	sp, err := newSessionPool(sc, config.SessionPoolConfig)
@@ -261,14 +495,146 @@ get an instance-specific endpoint and efficiently route requests.
 		sc.close()
 		return nil, err
 	}
+	if detector != nil {
+		detector.setPool(sp)
+	}
 	c = &Client{
-		sc:           sc,
-		idleSessions: sp,
-		logger:       config.logger,
+		sc:                 sc,
+		idleSessions:       sp,
+		logger:             config.logger,
+		rpcLog:             rl,
+		commitRetry:        config.CommitRetry,
+		maxCommitAttempts:  config.MaxCommitAttempts,
+		defaultSingleBound: config.DefaultSingleBound,
 	}
 	return c, nil
 }
 
+// dialClients creates config.NumChannels gapic clients with the given options.
+func dialClients(ctx context.Context, config ClientConfig, opts []option.ClientOption) ([]*vkit.Client, error) {
+	// TODO(deklerk): This should be replaced with a balancer with
+	// config.NumChannels connections, instead of config.NumChannels
+	// clients.
+	var clients []*vkit.Client
+	for i := 0; i < config.NumChannels; i++ {
+		client, err := vkit.NewClient(ctx, opts...)
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			if config.DialTimeout > 0 && err == context.DeadlineExceeded {
+				return nil, errDialTimeout(i, config.DialTimeout)
+			}
+			return nil, errDial(i, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// newRedialer returns a function that resolves the endpoint of the database
+// again with config.EndpointResolver, and that dials new gapic clients if the
+// endpoint is different from the endpoint that is currently used. An empty
+// endpoint keeps the current endpoint, as the options cannot be reverted to
+// the default endpoint.
+func newRedialer(database string, config ClientConfig, opts []option.ClientOption, endpoint string) func(context.Context) ([]*vkit.Client, error) {
+	var mu sync.Mutex
+	return func(ctx context.Context) ([]*vkit.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolved, err := config.EndpointResolver(database)
+		if err != nil {
+			return nil, errResolveEndpoint(database, err)
+		}
+		if resolved == "" || resolved == endpoint {
+			return nil, nil
+		}
+		clients, err := dialClients(ctx, config, append(opts[:len(opts):len(opts)], option.WithEndpoint(resolved)))
+		if err != nil {
+			return nil, err
+		}
+		endpoint = resolved
+		return clients, nil
+	}
+}
+
+// SessionAcquisitionLatency returns a snapshot of the histogram of the time
+// that was needed to take a session from the session pool of the client,
+// including the time that was spent waiting for a session to become available
+// when the pool was exhausted. A large number of slow acquisitions indicates
+// that SessionPoolConfig.MaxOpened or MinOpened should be increased.
+func (c *Client) SessionAcquisitionLatency() LatencyHistogram {
+	return c.idleSessions.acquisitionLatency.snapshot()
+}
+
+// APIClient returns one of the underlying generated clients of the Cloud
+// Spanner API that are used by the client. It can be used to call RPCs that
+// are not wrapped by this package.
+//
+// The generated client bypasses the session pool and all other features of
+// this package, such as retries of aborted transactions and session
+// management. Sessions that are created with the generated client are not
+// known to the session pool and must be deleted by the caller. Requests that
+// are sent with the generated client do not include the
+// google-cloud-resource-prefix header for the database of the client.
+//
+// The generated client is owned by the client, and must not be closed by the
+// caller. It cannot be used after the client has been closed. The client
+// uses multiple gRPC channels, and APIClient returns the generated clients of
+// the channels in round-robin order.
+func (c *Client) APIClient() *vkit.Client {
+	c.sc.mu.Lock()
+	defer c.sc.mu.Unlock()
+	return c.sc.rrNextGapicClientLocked()
+}
+
+// PoolStats returns a snapshot of the state of the session pool of the
+// client. The snapshot is consistent, and the cumulative counters are never
+// reset during the lifetime of the client. PoolStats is cheap enough to be
+// called periodically, for example to export the state of the pool to a
+// monitoring system.
+func (c *Client) PoolStats() SessionPoolStats {
+	return c.idleSessions.stats()
+}
+
+// pingSQL is the statement that is executed by Ping.
+const pingSQL = "SELECT 1"
+
+// Ping verifies that the client can reach the database. It takes a session
+// from the session pool, which creates a new session if the pool has no idle
+// sessions, executes a trivial query on the session and returns the session to
+// the pool. Ping respects the deadline of ctx, and the returned error contains
+// the gRPC status code of the failed RPC. Ping can be used as a readiness
+// check during startup.
+func (c *Client) Ping(ctx context.Context) (err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.Ping")
+	defer func() { trace.EndSpan(ctx, err) }()
+	sh, err := c.idleSessions.take(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = sh.getClient().ExecuteSql(contextWithOutgoingMetadata(ctx, sh.getMetadata()), &sppb.ExecuteSqlRequest{
+		Session: sh.getID(),
+		Sql:     pingSQL,
+	})
+	if isSessionNotFoundError(err) {
+		sh.destroy()
+	} else {
+		sh.recycle()
+	}
+	return toSpannerError(err)
+}
+
+// RecentRPCs returns the most recent RPCs that were executed by the client,
+// ordered from the oldest to the most recent. It returns nil if
+// ClientConfig.RecentRPCBufferSize is 0.
+func (c *Client) RecentRPCs() []RPCInfo {
+	if c.rpcLog == nil {
+		return nil
+	}
+	return c.rpcLog.list()
+}
+
 // Close closes the client.
 func (c *Client) Close() {
 	if c.idleSessions != nil {
@@ -281,13 +647,24 @@ func (c *Client) Close() {
 // where only a single read or query is needed.  This is more efficient than
 // using ReadOnlyTransaction() for a single read or query.
 //
-// Single will use a strong TimestampBound by default. Use
-// ReadOnlyTransaction.WithTimestampBound to specify a different
-// TimestampBound. A non-strong bound can be used to reduce latency, or
+// Single will use ClientConfig.DefaultSingleBound, which is a strong
+// TimestampBound by default. Use ReadOnlyTransaction.WithTimestampBound to
+// specify a different TimestampBound. A non-strong bound can be used to reduce latency, or
 // "time-travel" to prior versions of the database, see the documentation of
 // TimestampBound for details.
+//
+// The timestamp that Cloud Spanner chose for the read or query is returned in
+// the metadata of the result. It is available through RowIterator.ReadTimestamp
+// and ReadOnlyTransaction.Timestamp after the first call to RowIterator.Next,
+// which is useful to find out which snapshot a stale read observed.
+//
+// Transient errors with code Unavailable are retried transparently during the
+// whole lifecycle of the single-use transaction: both when a new session has
+// to be created for the transaction and when the read or query is executed.
+// Retries continue until the read or query succeeds, fails with an error that
+// cannot be retried, or the context of the read or query is done.
 func (c *Client) Single() *ReadOnlyTransaction {
-	t := &ReadOnlyTransaction{singleUse: true, sp: c.idleSessions}
+	t := &ReadOnlyTransaction{singleUse: true, sp: c.idleSessions, tb: c.defaultSingleBound}
 	t.txReadOnly.txReadEnv = t
 	return t
 }
@@ -296,6 +673,12 @@ func (c *Client) Single() *ReadOnlyTransaction {
 // multiple reads from the database.  You must call Close() when the
 // ReadOnlyTransaction is no longer needed to release resources on the server.
 //
+// The transaction is begun on Cloud Spanner only once, by the first read or
+// query, and all subsequent reads and queries reuse the same transaction ID
+// and session. Prefer a ReadOnlyTransaction over repeated calls to Single()
+// when several reads need to be served from the same snapshot, as Single()
+// sends the TimestampBound with every read.
+//
 // ReadOnlyTransaction will use a strong TimestampBound by default.  Use
 // ReadOnlyTransaction.WithTimestampBound to specify a different
 // TimestampBound.  A non-strong bound can be used to reduce latency, or
@@ -407,6 +790,16 @@ func checkNestedTxn(ctx context.Context) error {
 	return nil
 }
 
+// checkContextDone returns an error if ctx is already done when an operation
+// is started. This prevents sessions from being checked out and RPCs from
+// being sent for operations that cannot succeed.
+func checkContextDone(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return toSpannerError(err)
+	}
+	return nil
+}
+
 // ReadWriteTransaction executes a read-write transaction, with retries as
 // necessary.
 //
@@ -421,42 +814,189 @@ func checkNestedTxn(ctx context.Context) error {
 //
 // To limit the number of retries, set a deadline on the Context rather than
 // using a fixed limit on the number of attempts. ReadWriteTransaction will
-// retry as needed until that deadline is met.
+// retry as needed until that deadline is met. A fixed limit can be set with
+// ClientConfig.MaxCommitAttempts or ReadWriteTransactionOptions.MaxAttempts.
 //
 // See https://godoc.org/cloud.google.com/go/spanner#ReadWriteTransaction for
 // more details.
 func (c *Client) ReadWriteTransaction(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error) (commitTimestamp time.Time, err error) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.ReadWriteTransaction")
+	return c.ReadWriteTransactionWithOptions(ctx, f, ReadWriteTransactionOptions{})
+}
+
+// ReadWriteTransactionOptions provides options for a read-write transaction.
+type ReadWriteTransactionOptions struct {
+	// Session is the name of an existing session that should be used for the
+	// transaction instead of a session from the session pool. The session must
+	// belong to the database of the client.
+	//
+	// This is an advanced option that is mainly intended for debugging, for
+	// example to reproduce a problem on a specific session. The session is not
+	// returned to the session pool and is not deleted when the transaction
+	// finishes.
+	Session string
+
+	// MaxAttempts is the maximum number of times that the transaction is
+	// attempted if it is aborted by Cloud Spanner. The error of the last
+	// attempt is returned if the transaction is still aborted after
+	// MaxAttempts attempts.
+	//
+	// Defaults to 0, which means that ClientConfig.MaxCommitAttempts is
+	// used. If that is also 0, the transaction is retried until it succeeds,
+	// fails with a different error or the context is done.
+	MaxAttempts int
+
+	// Timeout is the maximum amount of time that the transaction may take,
+	// including all retries of the transaction function. The transaction
+	// fails with codes.DeadlineExceeded if it has not been committed when the
+	// timeout expires. The deadline of the context that is passed in to
+	// ReadWriteTransactionWithOptions still applies.
+	//
+	// Defaults to 0, which means that the transaction is only bounded by the
+	// context.
+	Timeout time.Duration
+
+	// CommitBackoff is the backoff between the attempts of the transaction
+	// if it is aborted by Cloud Spanner. If Cloud Spanner returns a retry
+	// delay with the Aborted error, that delay is used instead. For example,
+	// the following backoff waits at least 100ms before retrying an aborted
+	// transaction, and increases the delay up to 5s under heavy contention:
+	//
+	// 	gax.Backoff{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2}
+	//
+	// Note that the delay of each retry is a random duration between 0 and
+	// the current backoff, as described in gax.Backoff. Defaults to
+	// DefaultRetryBackoff if Initial is zero.
+	CommitBackoff gax.Backoff
+
+	// RawTransactionOptions are the options that are sent verbatim in the
+	// BeginTransaction request of each attempt of the transaction. This is an
+	// advanced option that can be used for transaction features that are not
+	// yet supported by this client. The options must specify a read-write
+	// transaction.
+	//
+	// If set, the transaction does not use sessions that have been prepared
+	// for read-write transactions in advance by the session pool, which
+	// means that each attempt of the transaction requires an additional round
+	// trip to Cloud Spanner.
+	RawTransactionOptions *sppb.TransactionOptions
+}
+
+// errSessionNotInDatabase returns error for using a session that does not
+// belong to the database of the client.
+func errSessionNotInDatabase(session, database string) error {
+	return spannerErrorf(codes.InvalidArgument, "session %q does not belong to database %q", session, database)
+}
+
+// errNotReadWriteTransactionOptions returns error for raw transaction options
+// that do not specify a read-write transaction.
+func errNotReadWriteTransactionOptions(opts *sppb.TransactionOptions) error {
+	return spannerErrorf(codes.InvalidArgument, "RawTransactionOptions must specify a read-write transaction, got %v", opts)
+}
+
+// errTransactionTimeout returns error for a read-write transaction that did
+// not finish within the timeout of the transaction.
+func errTransactionTimeout(timeout time.Duration, err error) error {
+	return spannerErrorf(codes.DeadlineExceeded, "transaction did not finish within %v: %v", timeout, err)
+}
+
+// ReadWriteTransactionWithOptions executes a read-write transaction with the
+// given options, with retries as necessary. See ReadWriteTransaction for more
+// details.
+func (c *Client) ReadWriteTransactionWithOptions(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error, opts ReadWriteTransactionOptions) (commitTimestamp time.Time, err error) {
+	return c.readWriteTransaction(ctx, f, opts, nil)
+}
+
+// readWriteTransaction executes a read-write transaction with the given
+// options. If lease is not nil, each attempt of the transaction uses the
+// session of the lease, and the session is not returned to the session pool
+// at the end of the transaction.
+func (c *Client) readWriteTransaction(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error, opts ReadWriteTransactionOptions, lease *SessionLease) (commitTimestamp time.Time, err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.ReadWriteTransaction")
 	defer func() { trace.EndSpan(ctx, err) }()
 	if err := checkNestedTxn(ctx); err != nil {
 		return time.Time{}, err
 	}
+	if err := checkContextDone(ctx); err != nil {
+		return time.Time{}, err
+	}
+	if opts.RawTransactionOptions != nil && opts.RawTransactionOptions.GetReadWrite() == nil {
+		return time.Time{}, errNotReadWriteTransactionOptions(opts.RawTransactionOptions)
+	}
 	var (
 		ts time.Time
 		sh *sessionHandle
 	)
-	err = runWithRetryOnAborted(ctx, func(ctx context.Context) error {
+	if opts.Session != "" {
+		if !strings.HasPrefix(opts.Session, c.sc.database+"/sessions/") {
+			return time.Time{}, errSessionNotInDatabase(opts.Session, c.sc.database)
+		}
+		// The session is not owned by the session pool, so recycling it at
+		// the end of the transaction is a no-op.
+		sh = &sessionHandle{session: c.sc.sessionWithID(opts.Session)}
+	}
+	txCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		txCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = c.maxCommitAttempts
+	}
+	at := c.startTransaction()
+	defer c.endTransaction(at)
+	commitBackoff := opts.CommitBackoff
+	if commitBackoff.Initial == 0 {
+		commitBackoff = DefaultRetryBackoff
+	}
+	err = runWithRetryOnAbortedWithMaxAttempts(txCtx, maxAttempts, commitBackoff, func(ctx context.Context) error {
 		var (
 			err error
 			t   *ReadWriteTransaction
 		)
-		if sh == nil || sh.getID() == "" || sh.getClient() == nil {
+		if lease != nil {
+			// The lease takes a new session from the pool if the leased
+			// session has been destroyed.
+			if sh, err = lease.take(ctx); err != nil {
+				return err
+			}
+			t = &ReadWriteTransaction{
+				sh: sh,
+			}
+		} else if sh == nil || sh.getID() == "" || sh.getClient() == nil {
+			if opts.Session != "" {
+				// Do not silently switch to a session from the pool if the
+				// caller asked for a specific session.
+				return spannerErrorf(codes.FailedPrecondition, "session %q is no longer usable", opts.Session)
+			}
 			// Session handle hasn't been allocated or has been destroyed.
-			sh, err = c.idleSessions.takeWriteSession(ctx)
+			if opts.RawTransactionOptions != nil {
+				// The transaction must be started with the raw options, so
+				// a session with a prepared transaction cannot be used.
+				sh, err = c.idleSessions.take(ctx)
+			} else {
+				sh, err = c.idleSessions.takeWriteSession(ctx)
+			}
 			if err != nil {
 				// If session retrieval fails, just fail the transaction.
 				return err
 			}
 			t = &ReadWriteTransaction{
 				sh: sh,
-				tx: sh.getTransactionID(),
+			}
+			if opts.RawTransactionOptions == nil {
+				t.tx = sh.getTransactionID()
 			}
 		} else {
 			t = &ReadWriteTransaction{
 				sh: sh,
 			}
 		}
+		t.txOpts = opts.RawTransactionOptions
+		t.commitRetry = c.commitRetry
 		t.txReadOnly.txReadEnv = t
+		c.startTransactionAttempt(at, sh.getID())
 		trace.TracePrintf(ctx, map[string]interface{}{"transactionID": string(sh.getTransactionID())},
 			"Starting transaction attempt")
 		if err = t.begin(ctx); err != nil {
@@ -465,17 +1005,157 @@ func (c *Client) ReadWriteTransaction(ctx context.Context, f func(context.Contex
 		ts, err = t.runInTransaction(ctx, f)
 		return err
 	})
-	if sh != nil {
+	if err != nil && txCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		// The transaction timeout expired before the deadline of the caller.
+		err = errTransactionTimeout(opts.Timeout, err)
+	}
+	if sh != nil && lease == nil {
 		sh.recycle()
 	}
+	if err == nil {
+		c.recordCommitTimestamp(ts)
+	}
 	return ts, err
 }
 
+// SessionPoolConfig returns a copy of the effective configuration of the
+// session pool of the client, including any default values that were applied
+// when the client was created.
+func (c *Client) SessionPoolConfig() SessionPoolConfig {
+	config := c.idleSessions.SessionPoolConfig
+	// Do not share the session labels with the session pool.
+	if config.sessionLabels != nil {
+		labels := make(map[string]string, len(config.sessionLabels))
+		for k, v := range config.sessionLabels {
+			labels[k] = v
+		}
+		config.sessionLabels = labels
+	}
+	return config
+}
+
+// TransactionInfo contains metadata about a read-write transaction that is
+// running on a Client. It is intended for debugging purposes only.
+type TransactionInfo struct {
+	// StartTime is the time at which the transaction was started.
+	StartTime time.Time
+	// Attempts is the number of attempts that have been started for the
+	// transaction, including the current attempt.
+	Attempts int
+	// SessionID is the name of the session that is used by the current attempt
+	// of the transaction. It is empty if no session has been assigned yet.
+	SessionID string
+}
+
+// activeTransaction is the tracking state of a running read-write
+// transaction.
+type activeTransaction struct {
+	startTime time.Time
+	attempts  int
+	sessionID string
+}
+
+// startTransaction registers a new read-write transaction as active.
+func (c *Client) startTransaction() *activeTransaction {
+	at := &activeTransaction{startTime: time.Now()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.activeTxns == nil {
+		c.activeTxns = make(map[*activeTransaction]struct{})
+	}
+	c.activeTxns[at] = struct{}{}
+	return at
+}
+
+// startTransactionAttempt records a new attempt of an active read-write
+// transaction on the given session.
+func (c *Client) startTransactionAttempt(at *activeTransaction, sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at.attempts++
+	at.sessionID = sessionID
+	// Read-write transactions are only retried if they were aborted.
+	if at.attempts > 1 {
+		c.abortedRetries++
+	}
+}
+
+// AbortedRetryCount returns the total number of times that read-write
+// transactions of the client have been retried because they were aborted by
+// Cloud Spanner, since the client was created. The counter is never reset,
+// and can be exported periodically to a monitoring system to detect lock
+// contention.
+func (c *Client) AbortedRetryCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.abortedRetries
+}
+
+// recordCommitTimestamp updates the range of commit timestamps of the client
+// with the commit timestamp of a successful transaction.
+func (c *Client) recordCommitTimestamp(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.minCommitTimestamp.IsZero() || ts.Before(c.minCommitTimestamp) {
+		c.minCommitTimestamp = ts
+	}
+	if ts.After(c.maxCommitTimestamp) {
+		c.maxCommitTimestamp = ts
+	}
+}
+
+// CommitTimestampRange returns the smallest and the largest commit timestamp
+// of the read-write transactions and Apply calls that have been committed
+// successfully by the client, since the client was created. Both timestamps
+// are zero if the client has not committed any transaction yet.
+func (c *Client) CommitTimestampRange() (time.Time, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.minCommitTimestamp, c.maxCommitTimestamp
+}
+
+// endTransaction removes a read-write transaction from the active
+// transactions.
+func (c *Client) endTransaction(at *activeTransaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.activeTxns, at)
+}
+
+// ActiveTransactions returns metadata about the read-write transactions that
+// are currently running on the client, ordered by start time. This can be
+// used to diagnose transactions that are stuck or waiting for locks.
+//
+// The returned values are a snapshot and are not updated when the
+// transactions make progress.
+func (c *Client) ActiveTransactions() []TransactionInfo {
+	c.mu.Lock()
+	infos := make([]TransactionInfo, 0, len(c.activeTxns))
+	for at := range c.activeTxns {
+		infos = append(infos, TransactionInfo{
+			StartTime: at.startTime,
+			Attempts:  at.attempts,
+			SessionID: at.sessionID,
+		})
+	}
+	c.mu.Unlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.Before(infos[j].StartTime)
+	})
+	return infos
+}
+
 // applyOption controls the behavior of Client.Apply.
 type applyOption struct {
 	// If atLeastOnce == true, Client.Apply will execute the mutations on Cloud
 	// Spanner at least once.
 	atLeastOnce bool
+	// maxConcurrency is the maximum number of groups of mutations that
+	// Client.ApplyGroups applies in parallel.
+	maxConcurrency int
 }
 
 // An ApplyOption is an optional argument to Apply.
@@ -498,8 +1178,22 @@ func ApplyAtLeastOnce() ApplyOption {
 	}
 }
 
+// MaxConcurrency returns an ApplyOption that limits the number of groups of
+// mutations that Client.ApplyGroups applies in parallel to n. The option has no
+// effect on the other Apply methods. If n is not positive, or larger than the
+// maximum number of sessions in the session pool, the maximum number of
+// sessions is used.
+func MaxConcurrency(n int) ApplyOption {
+	return func(ao *applyOption) {
+		ao.maxConcurrency = n
+	}
+}
+
 // Apply applies a list of mutations atomically to the database.
 func (c *Client) Apply(ctx context.Context, ms []*Mutation, opts ...ApplyOption) (commitTimestamp time.Time, err error) {
+	if err := checkContextDone(ctx); err != nil {
+		return time.Time{}, err
+	}
 	ao := &applyOption{}
 	for _, opt := range opts {
 		opt(ao)
@@ -510,10 +1204,48 @@ func (c *Client) Apply(ctx context.Context, ms []*Mutation, opts ...ApplyOption)
 		})
 	}
 
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Apply")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.Apply")
 	defer func() { trace.EndSpan(ctx, err) }()
-	t := &writeOnlyTransaction{c.idleSessions}
-	return t.applyAtLeastOnce(ctx, ms...)
+	t := &writeOnlyTransaction{sp: c.idleSessions, commitRetry: c.commitRetry}
+	commitTimestamp, err = t.applyAtLeastOnce(ctx, ms...)
+	if err == nil {
+		c.recordCommitTimestamp(commitTimestamp)
+	}
+	return commitTimestamp, err
+}
+
+// errInvalidBatchSize returns error for a batch size that is not positive.
+func errInvalidBatchSize(batchSize int) error {
+	return spannerErrorf(codes.InvalidArgument, "batch size must be positive, got %d", batchSize)
+}
+
+// ApplyInBatches applies a list of mutations to the database in batches of at
+// most batchSize mutations. Each batch is applied atomically in a separate
+// transaction by calling Apply with the given options, which means that the
+// list of mutations as a whole is not applied atomically.
+//
+// ApplyInBatches returns the commit timestamp of each batch that was applied,
+// in the order of the batches. If a batch fails, ApplyInBatches stops and
+// returns the commit timestamps of the batches that were applied before the
+// failed batch together with the error.
+func (c *Client) ApplyInBatches(ctx context.Context, ms []*Mutation, batchSize int, opts ...ApplyOption) (commitTimestamps []time.Time, err error) {
+	if batchSize <= 0 {
+		return nil, errInvalidBatchSize(batchSize)
+	}
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.ApplyInBatches")
+	defer func() { trace.EndSpan(ctx, err) }()
+	for start := 0; start < len(ms); start += batchSize {
+		end := start + batchSize
+		if end > len(ms) {
+			end = len(ms)
+		}
+		ts, err := c.Apply(ctx, ms[start:end], opts...)
+		if err != nil {
+			return commitTimestamps, err
+		}
+		commitTimestamps = append(commitTimestamps, ts)
+	}
+	return commitTimestamps, nil
 }
 
 // logf logs the given message to the given logger, or the standard logger if
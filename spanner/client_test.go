@@ -22,19 +22,28 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	itestutil "cloud.google.com/go/internal/testutil"
 	. "cloud.google.com/go/spanner/internal/testutil"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	proto3 "github.com/golang/protobuf/ptypes/struct"
+	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	edpb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
 )
 
@@ -151,6 +160,74 @@ func TestClient_Single_Unavailable(t *testing.T) {
 	}
 }
 
+func TestClient_Single_UnavailableBeforeResumeToken(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	// The first attempt fails before any resume token has been returned.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Unavailable, "Temporary unavailable")},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// executeSingerQuery verifies that all rows are returned exactly once.
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	requests, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream is restarted from scratch.
+	for _, req := range requests[1:] {
+		if g := req.(*sppb.ExecuteSqlRequest).ResumeToken; g != nil {
+			t.Fatalf("resume token mismatch\nGot: %v\nWant: %v", g, nil)
+		}
+	}
+}
+
+func TestClient_Single_IsRetryableStreamError(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		IsRetryableStreamError: func(err error) bool {
+			return ErrCode(err) == codes.Unknown || ErrCode(err) == codes.Aborted
+		},
+	})
+	defer teardown()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Unknown errors are not retried by default, but are accepted by the
+	// custom retry predicate.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Unknown, "proxy error")},
+	})
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Aborted errors are never passed to the custom retry predicate.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Transaction aborted")},
+	})
+	err := executeSingerQuery(ctx, client.Single())
+	if g, w := ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
 func TestClient_Single_InvalidArgument(t *testing.T) {
 	t.Parallel()
 	err := testSingleQuery(t, status.Error(codes.InvalidArgument, "Invalid argument"))
@@ -159,6 +236,152 @@ func TestClient_Single_InvalidArgument(t *testing.T) {
 	}
 }
 
+func TestClient_Single_UnavailableOnCreateSessionAndExecuteStreamingSql(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	unavailable := SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Unavailable, "Temporary unavailable")},
+	}
+	server.TestSpanner.PutExecutionTime(MethodCreateSession, unavailable)
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, unavailable)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.CreateSessionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Single_UnavailableOnCreateSessionAndStreamingRead(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	unavailable := SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Unavailable, "Temporary unavailable")},
+	}
+	server.TestSpanner.PutExecutionTime(MethodCreateSession, unavailable)
+	server.TestSpanner.PutExecutionTime(MethodStreamingRead, unavailable)
+	server.TestSpanner.PutReadResult("Albums", &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("SingerId", intType()),
+						mkField("AlbumId", intType()),
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), intProto(1)),
+				listValueProto(intProto(1), intProto(2)),
+				listValueProto(intProto(2), intProto(1)),
+			},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	iter := client.Single().Read(ctx, "Albums", AllKeys(), []string{"SingerId", "AlbumId"})
+	var rowCount int
+	if err := iter.Do(func(r *Row) error {
+		rowCount++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rowCount, 3; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.CreateSessionRequest{},
+		&sppb.ReadRequest{},
+		&sppb.ReadRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Single_ReadTimestamp(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	tx := client.Single()
+	iter := tx.Query(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	defer iter.Stop()
+	if !iter.ReadTimestamp.IsZero() {
+		t.Fatalf("read timestamp should not be available before the first call to Next, got %v", iter.ReadTimestamp)
+	}
+	if _, err := iter.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if iter.ReadTimestamp.IsZero() {
+		t.Fatal("missing read timestamp after the first call to Next")
+	}
+	rts, err := tx.Timestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !iter.ReadTimestamp.Equal(rts) {
+		t.Fatalf("read timestamp mismatch\nGot: %v\nWant: %v", iter.ReadTimestamp, rts)
+	}
+	if err := iter.Do(func(*Row) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if !iter.ReadTimestamp.Equal(rts) {
+		t.Fatalf("read timestamp mismatch after iteration\nGot: %v\nWant: %v", iter.ReadTimestamp, rts)
+	}
+}
+
+func TestClient_Single_ReadTimestampExactStaleness(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	tx := client.Single().WithTimestampBound(ExactStaleness(15 * time.Second))
+	if err := executeSingerQuery(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	rts, err := tx.Timestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	var req *sppb.ExecuteSqlRequest
+	for _, r := range requests {
+		if sqlReq, ok := r.(*sppb.ExecuteSqlRequest); ok {
+			req = sqlReq
+		}
+	}
+	if req == nil {
+		t.Fatal("missing ExecuteSqlRequest")
+	}
+	ro := req.Transaction.GetSingleUse().GetReadOnly()
+	if !ro.GetReturnReadTimestamp() {
+		t.Fatal("single-use read-only transaction should request the read timestamp")
+	}
+	if g, w := ro.GetExactStaleness(), durationProto(15*time.Second); !testEqual(g, w) {
+		t.Fatalf("exact staleness mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The read timestamp is chosen by the server and is in the past.
+	if rts.IsZero() || rts.After(time.Now()) {
+		t.Fatalf("invalid read timestamp: %v", rts)
+	}
+}
+
 func TestClient_Single_RetryableErrorOnPartialResultSet(t *testing.T) {
 	t.Parallel()
 	server, client, teardown := setupMockedTestServer(t)
@@ -433,6 +656,91 @@ func TestClient_ResourceBasedRouting_WithPermissionDeniedError(t *testing.T) {
 	}
 }
 
+func TestClient_ResourceBasedRouting_OnEndpointFallback(t *testing.T) {
+	os.Setenv("GOOGLE_CLOUD_SPANNER_ENABLE_RESOURCE_BASED_ROUTING", "true")
+	defer os.Setenv("GOOGLE_CLOUD_SPANNER_ENABLE_RESOURCE_BASED_ROUTING", "")
+
+	server, opts, serverTeardown := NewMockedSpannerInMemTestServer(t)
+	defer serverTeardown()
+
+	server.TestInstanceAdmin.SetErr(status.Error(codes.PermissionDenied, "Permission Denied"))
+
+	ctx := context.Background()
+	formattedDatabase := fmt.Sprintf("projects/%s/instances/%s/databases/%s", "some-project", "some-instance", "some-database")
+	var reasons []error
+	client, err := NewClientWithConfig(ctx, formattedDatabase, ClientConfig{
+		OnEndpointFallback: func(reason error) { reasons = append(reasons, reason) },
+		logger:             log.New(ioutil.Discard, "", log.LstdFlags),
+	}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if g, w := len(reasons), 1; g != w {
+		t.Fatalf("number of fallback callbacks mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := status.Code(reasons[0]), codes.PermissionDenied; g != w {
+		t.Fatalf("fallback reason mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_EndpointResolver(t *testing.T) {
+	t.Parallel()
+
+	// The client options point to the default server, but the resolver
+	// returns the endpoint of the target server.
+	serverDefault, optsDefault, serverTeardownDefault := NewMockedSpannerInMemTestServer(t)
+	defer serverTeardownDefault()
+	serverTarget, optsTarget, serverTeardownTarget := NewMockedSpannerInMemTestServer(t)
+	defer serverTeardownTarget()
+	targetEndpoint := fmt.Sprintf("%s", optsTarget[0])
+
+	ctx := context.Background()
+	formattedDatabase := fmt.Sprintf("projects/%s/instances/%s/databases/%s", "some-project", "some-instance", "some-database")
+	var resolved []string
+	client, err := NewClientWithConfig(ctx, formattedDatabase, ClientConfig{
+		EndpointResolver: func(database string) (string, error) {
+			resolved = append(resolved, database)
+			return targetEndpoint, nil
+		},
+	}, optsDefault...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if g, w := resolved, []string{formattedDatabase}; !testEqual(g, w) {
+		t.Fatalf("resolved databases mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	// The default server should not receive any requests.
+	if _, err := shouldHaveReceived(serverDefault.TestSpanner, []interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = shouldHaveReceived(serverTarget.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.ExecuteSqlRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An error of the resolver is returned by NewClientWithConfig.
+	_, err = NewClientWithConfig(ctx, formattedDatabase, ClientConfig{
+		EndpointResolver: func(database string) (string, error) {
+			return "", status.Error(codes.NotFound, "unknown database")
+		},
+	}, optsDefault...)
+	if g, w := ErrCode(err), codes.NotFound; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
 func TestClient_ResourceBasedRouting_WithUnavailableError(t *testing.T) {
 	os.Setenv("GOOGLE_CLOUD_SPANNER_ENABLE_RESOURCE_BASED_ROUTING", "true")
 	defer os.Setenv("GOOGLE_CLOUD_SPANNER_ENABLE_RESOURCE_BASED_ROUTING", "")
@@ -753,6 +1061,184 @@ func TestClient_ApplyAtLeastOnce(t *testing.T) {
 	}
 }
 
+func TestClient_CommitRetryOnInternal(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	rstStream := status.Error(codes.Internal, "stream terminated by RST_STREAM with error code: INTERNAL_ERROR")
+	commitRetry := CommitRetryConfig{
+		IsRetryable: func(err error) bool { return strings.Contains(err.Error(), "RST_STREAM") },
+	}
+	ms := []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}
+
+	for _, test := range []struct {
+		name  string
+		apply func(c *Client) error
+	}{
+		{"ReadWriteTransaction", func(c *Client) error {
+			_, err := c.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+				return tx.BufferWrite(ms)
+			})
+			return err
+		}},
+		{"ApplyAtLeastOnce", func(c *Client) error {
+			_, err := c.Apply(ctx, ms, ApplyAtLeastOnce())
+			return err
+		}},
+	} {
+		// The Commit RPC is retried for errors that are accepted by the
+		// predicate.
+		server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{CommitRetry: commitRetry})
+		server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+			Errors: []error{rstStream},
+		})
+		if err := test.apply(client); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var commits int
+		for _, req := range drainRequestsFromServer(server.TestSpanner) {
+			if _, ok := req.(*sppb.CommitRequest); ok {
+				commits++
+			}
+		}
+		if g, w := commits, 2; g != w {
+			t.Fatalf("%s: number of commits mismatch\nGot: %v\nWant: %v", test.name, g, w)
+		}
+
+		// The number of retries is bounded.
+		server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+			Errors:    []error{rstStream},
+			KeepError: true,
+		})
+		if err := test.apply(client); ErrCode(err) != codes.Internal {
+			t.Fatalf("%s: error code mismatch\nGot: %v\nWant: %v", test.name, ErrCode(err), codes.Internal)
+		}
+		commits = 0
+		for _, req := range drainRequestsFromServer(server.TestSpanner) {
+			if _, ok := req.(*sppb.CommitRequest); ok {
+				commits++
+			}
+		}
+		if g, w := commits, 1+defaultMaxCommitRetries; g != w {
+			t.Fatalf("%s: number of commits mismatch\nGot: %v\nWant: %v", test.name, g, w)
+		}
+		teardown()
+	}
+
+	// Internal errors are not retried by default.
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{rstStream},
+	})
+	if _, err := client.Apply(ctx, ms, ApplyAtLeastOnce()); ErrCode(err) != codes.Internal {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", ErrCode(err), codes.Internal)
+	}
+}
+
+func TestClient_ApplyInBatches(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	var ms []*Mutation
+	for i := 0; i < 5; i++ {
+		ms = append(ms, Insert("Accounts", []string{"AccountId"}, []interface{}{int64(i)}))
+	}
+	timestamps, err := client.ApplyInBatches(context.Background(), ms, 2, ApplyAtLeastOnce())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(timestamps), 3; g != w {
+		t.Fatalf("commit timestamp count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	for i, ts := range timestamps {
+		if ts.IsZero() {
+			t.Fatalf("missing commit timestamp for batch %d", i)
+		}
+	}
+	var mutationCounts []int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if commit, ok := req.(*sppb.CommitRequest); ok {
+			mutationCounts = append(mutationCounts, len(commit.Mutations))
+		}
+	}
+	if g, w := mutationCounts, []int{2, 2, 1}; !testEqual(g, w) {
+		t.Fatalf("mutations per commit mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ApplyInBatches_Error(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	ms := []*Mutation{
+		Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)}),
+		Insert("Accounts", []string{"AccountId"}, []interface{}{int64(2)}),
+	}
+	if _, err := client.ApplyInBatches(context.Background(), ms, 0); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("Error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction,
+		SimulatedExecutionTime{
+			Errors: []error{status.Error(codes.FailedPrecondition, "Commit failed")},
+		})
+	timestamps, err := client.ApplyInBatches(context.Background(), ms, 1, ApplyAtLeastOnce())
+	if g, w := ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(timestamps), 0; g != w {
+		t.Fatalf("commit timestamp count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_CancelledContext(t *testing.T) {
+	t.Parallel()
+	// Do not create any sessions in advance.
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		SessionPoolConfig: SessionPoolConfig{MinOpened: 0},
+	})
+	defer teardown()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ms := []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}
+	for _, test := range []struct {
+		name string
+		f    func() error
+	}{
+		{"Query", func() error {
+			return client.Single().Query(ctx, NewStatement(SelectFooFromBar)).Do(func(*Row) error { return nil })
+		}},
+		{"Read", func() error {
+			return client.Single().Read(ctx, "Accounts", AllKeys(), []string{"AccountId"}).Do(func(*Row) error { return nil })
+		}},
+		{"ReadWriteTransaction", func() error {
+			_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+				return tx.BufferWrite(ms)
+			})
+			return err
+		}},
+		{"Apply", func() error {
+			_, err := client.Apply(ctx, ms)
+			return err
+		}},
+		{"ApplyAtLeastOnce", func() error {
+			_, err := client.Apply(ctx, ms, ApplyAtLeastOnce())
+			return err
+		}},
+		{"PartitionedUpdate", func() error {
+			_, err := client.PartitionedUpdate(ctx, NewStatement(UpdateBarSetFoo))
+			return err
+		}},
+	} {
+		if g, w := ErrCode(test.f()), codes.Canceled; g != w {
+			t.Errorf("%s: error code mismatch\nGot: %v\nWant: %v", test.name, g, w)
+		}
+	}
+	if reqs := drainRequestsFromServer(server.TestSpanner); len(reqs) != 0 {
+		t.Fatalf("got %d requests, want no requests:\n%v", len(reqs), reqs)
+	}
+}
+
 func TestReadWriteTransaction_ErrUnexpectedEOF(t *testing.T) {
 	t.Parallel()
 	_, client, teardown := setupMockedTestServer(t)
@@ -859,3 +1345,1207 @@ func TestReadWriteTransaction_WrapError(t *testing.T) {
 		t.Fatalf("Unexpected error\nGot: %v\nWant: %v", err, msg)
 	}
 }
+
+func TestClient_ActiveTransactions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	if got := client.ActiveTransactions(); len(got) != 0 {
+		t.Fatalf("Active transactions mismatch\nGot: %v\nWant: none", got)
+	}
+	const numTxns = 2
+	started := make(chan struct{}, numTxns)
+	release := make(chan struct{})
+	errs := make(chan error, numTxns)
+	for i := 0; i < numTxns; i++ {
+		go func() {
+			_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+			errs <- err
+		}()
+	}
+	for i := 0; i < numTxns; i++ {
+		<-started
+	}
+	before := time.Now()
+	infos := client.ActiveTransactions()
+	if g, w := len(infos), numTxns; g != w {
+		t.Fatalf("Number of active transactions mismatch\nGot: %d\nWant: %d", g, w)
+	}
+	sessions := make(map[string]bool)
+	for _, info := range infos {
+		if g, w := info.Attempts, 1; g != w {
+			t.Errorf("Attempts mismatch\nGot: %d\nWant: %d", g, w)
+		}
+		if info.SessionID == "" {
+			t.Errorf("Missing session ID for transaction %v", info)
+		}
+		if info.StartTime.IsZero() || info.StartTime.After(before) {
+			t.Errorf("Invalid start time for transaction: %v", info.StartTime)
+		}
+		sessions[info.SessionID] = true
+	}
+	if g, w := len(sessions), numTxns; g != w {
+		t.Errorf("Number of sessions used by active transactions mismatch\nGot: %d\nWant: %d", g, w)
+	}
+	close(release)
+	for i := 0; i < numTxns; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := client.ActiveTransactions(); len(got) != 0 {
+		t.Fatalf("Active transactions mismatch\nGot: %v\nWant: none", got)
+	}
+}
+
+func TestClient_AbortedRetryCount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	if g, w := client.AbortedRetryCount(), uint64(0); g != w {
+		t.Fatalf("Aborted retry count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The first transaction is aborted twice, the second one once.
+	for _, aborts := range []int{2, 1} {
+		errs := make([]error, aborts)
+		for i := range errs {
+			errs[i] = status.Error(codes.Aborted, "Transaction aborted")
+		}
+		server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{Errors: errs})
+		if _, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Transactions that are not aborted do not change the counter.
+	if _, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := client.AbortedRetryCount(), uint64(3); g != w {
+		t.Fatalf("Aborted retry count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_CommitBackoff(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// The configured backoff is so long that the transaction cannot be
+	// retried before the context expires.
+	opts := ReadWriteTransactionOptions{
+		CommitBackoff: gax.Backoff{Initial: time.Hour, Max: time.Hour, Multiplier: 1},
+	}
+	aborted := status.Error(codes.Aborted, "Transaction aborted")
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{aborted, aborted},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return nil
+	}, opts)
+	// The error of the context is returned as is while backing off, as for
+	// transactions without a custom backoff.
+	if g, w := err, context.DeadlineExceeded; g != w {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Each pause is a random duration between 0 and the configured backoff,
+	// so the total pause of ten retries is very unlikely to be shorter than
+	// one initial delay, and cannot be much longer than ten.
+	const numAborted = 10
+	backoff := 100 * time.Millisecond
+	var errs []error
+	for i := 0; i < numAborted; i++ {
+		errs = append(errs, aborted)
+	}
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: errs,
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return nil
+	}, ReadWriteTransactionOptions{
+		CommitBackoff: gax.Backoff{Initial: backoff, Max: backoff, Multiplier: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < backoff || elapsed > numAborted*backoff+2*time.Second {
+		t.Fatalf("transaction duration out of range\nGot: %v\nWant: between %v and %v", elapsed, backoff, numAborted*backoff+2*time.Second)
+	}
+
+	// A retry delay that is returned by Cloud Spanner takes precedence over
+	// the configured backoff.
+	st, err := status.New(codes.Aborted, "Transaction aborted").WithDetails(&edpb.RetryInfo{
+		RetryDelay: ptypes.DurationProto(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{st.Err(), st.Err()},
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	attempts := 0
+	if _, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		attempts++
+		return nil
+	}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := attempts, 3; g != w {
+		t.Fatalf("attempts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_CommitTimestampRange(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	if min, max := client.CommitTimestampRange(); !min.IsZero() || !max.IsZero() {
+		t.Fatalf("commit timestamp range should be empty, got [%v, %v]", min, max)
+	}
+	ms := []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}
+	var timestamps []time.Time
+	for i := 0; i < 3; i++ {
+		ts, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+			return tx.BufferWrite(ms)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamps = append(timestamps, ts)
+		ts, err = client.Apply(ctx, ms, ApplyAtLeastOnce())
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamps = append(timestamps, ts)
+	}
+	wantMin, wantMax := timestamps[0], timestamps[0]
+	for _, ts := range timestamps {
+		if ts.Before(wantMin) {
+			wantMin = ts
+		}
+		if ts.After(wantMax) {
+			wantMax = ts
+		}
+	}
+	min, max := client.CommitTimestampRange()
+	if !min.Equal(wantMin) {
+		t.Fatalf("min commit timestamp mismatch\nGot: %v\nWant: %v", min, wantMin)
+	}
+	if !max.Equal(wantMax) {
+		t.Fatalf("max commit timestamp mismatch\nGot: %v\nWant: %v", max, wantMax)
+	}
+
+	// Failed transactions do not change the range.
+	if _, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return spannerErrorf(codes.InvalidArgument, "invalid")
+	}); err == nil {
+		t.Fatal("missing error for failed transaction")
+	}
+	if gotMin, gotMax := client.CommitTimestampRange(); !gotMin.Equal(min) || !gotMax.Equal(max) {
+		t.Fatalf("commit timestamp range mismatch\nGot: [%v, %v]\nWant: [%v, %v]", gotMin, gotMax, min, max)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_Session(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	session, err := server.TestSpanner.CreateSession(ctx, &sppb.CreateSessionRequest{Database: client.sc.database})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ReadWriteTransactionOptions{Session: session.Name}
+	_, err = client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numReqs int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		var got string
+		switch req := req.(type) {
+		case *sppb.BeginTransactionRequest:
+			got = req.Session
+		case *sppb.ExecuteSqlRequest:
+			got = req.Session
+		case *sppb.CommitRequest:
+			got = req.Session
+		default:
+			continue
+		}
+		numReqs++
+		if got != session.Name {
+			t.Errorf("Session mismatch for %T\nGot: %v\nWant: %v", req, got, session.Name)
+		}
+	}
+	if g, w := numReqs, 3; g != w {
+		t.Fatalf("Number of transaction requests mismatch\nGot: %d\nWant: %d", g, w)
+	}
+	// The session is owned by the caller and should not have been deleted.
+	if _, ok := server.TestSpanner.DumpSessions()[session.Name]; !ok {
+		t.Fatalf("Session %s was deleted", session.Name)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_SessionFromOtherDatabase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	opts := ReadWriteTransactionOptions{Session: "projects/p/instances/i/databases/other/sessions/s"}
+	_, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		t.Fatal("Transaction function should not be called")
+		return nil
+	}, opts)
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_MaxAttempts(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	const maxAttempts = 3
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{
+			status.Error(codes.Aborted, "Aborted 1"),
+			status.Error(codes.Aborted, "Aborted 2"),
+			status.Error(codes.Aborted, "Aborted 3"),
+			status.Error(codes.Aborted, "Aborted 4"),
+		},
+	})
+	ctx := context.Background()
+	var attempts int
+	_, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		attempts++
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	}, ReadWriteTransactionOptions{MaxAttempts: maxAttempts})
+	if g, w := ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !strings.Contains(err.Error(), "Aborted 3") {
+		t.Fatalf("Error mismatch\nGot: %v\nWant: error of attempt %d", err, maxAttempts)
+	}
+	if g, w := attempts, maxAttempts; g != w {
+		t.Fatalf("Number of attempts mismatch\nGot: %d\nWant: %d", g, w)
+	}
+}
+
+func TestClient_APIClient(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	ctx := context.Background()
+	api := client.APIClient()
+	session, err := api.CreateSession(ctx, &sppb.CreateSessionRequest{Database: client.sc.database})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(session.Name, client.sc.database+"/sessions/") {
+		t.Fatalf("session name mismatch\nGot: %v\nWant prefix: %v", session.Name, client.sc.database+"/sessions/")
+	}
+	// The session is not known to the session pool.
+	if g, w := client.PoolStats().NumSessions, uint64(0); g != w {
+		t.Fatalf("number of sessions in pool mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if err := api.DeleteSession(ctx, &sppb.DeleteSessionRequest{Name: session.Name}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.DeleteSessionRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	server.TestSpanner.PutStatementResult(pingSQL, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				{Values: []*proto3.Value{intProto(1)}},
+			},
+		},
+	})
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	reqs := drainRequestsFromServer(server.TestSpanner)
+	var found bool
+	for _, req := range reqs {
+		if sqlReq, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			found = true
+			if g, w := sqlReq.Sql, pingSQL; g != w {
+				t.Fatalf("SQL mismatch\nGot: %v\nWant: %v", g, w)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("missing ExecuteSqlRequest in %v", reqs)
+	}
+	// The session is returned to the pool.
+	if g, w := client.PoolStats().NumInUse, uint64(0); g != w {
+		t.Fatalf("number of sessions in use mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Ping returns the status code of the failed RPC.
+	server.TestSpanner.PutExecutionTime(MethodExecuteSql, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.PermissionDenied, "Permission denied")},
+	})
+	if g, w := ErrCode(client.Ping(ctx)), codes.PermissionDenied; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Ping respects the deadline of the context.
+	server.TestSpanner.PutExecutionTime(MethodExecuteSql, SimulatedExecutionTime{
+		MinimumExecutionTime: time.Second,
+	})
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if g, w := ErrCode(client.Ping(ctx)), codes.DeadlineExceeded; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_DefaultSingleBound(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		DefaultSingleBound: ExactStaleness(10 * time.Second),
+	})
+	defer teardown()
+
+	ctx := context.Background()
+	singleUseReadOnly := func() *sppb.TransactionOptions_ReadOnly {
+		for _, req := range drainRequestsFromServer(server.TestSpanner) {
+			if sqlReq, ok := req.(*sppb.ExecuteSqlRequest); ok {
+				return sqlReq.Transaction.GetSingleUse().GetReadOnly()
+			}
+		}
+		t.Fatal("missing ExecuteSqlRequest")
+		return nil
+	}
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	ro := singleUseReadOnly()
+	if g, w := ro.GetExactStaleness(), ptypes.DurationProto(10*time.Second); !testEqual(g, w) {
+		t.Fatalf("exact staleness mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// The default bound can be overridden for a single transaction.
+	if err := executeSingerQuery(ctx, client.Single().WithTimestampBound(StrongRead())); err != nil {
+		t.Fatal(err)
+	}
+	if ro := singleUseReadOnly(); !ro.GetStrong() {
+		t.Fatalf("timestamp bound mismatch\nGot: %v\nWant: strong", ro)
+	}
+}
+
+func TestClient_MaxCommitAttempts(t *testing.T) {
+	t.Parallel()
+	const maxAttempts = 2
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		MaxCommitAttempts: maxAttempts,
+	})
+	defer teardown()
+
+	aborted := SimulatedExecutionTime{
+		Errors: []error{
+			status.Error(codes.Aborted, "Aborted 1"),
+			status.Error(codes.Aborted, "Aborted 2"),
+			status.Error(codes.Aborted, "Aborted 3"),
+		},
+	}
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, aborted)
+	ctx := context.Background()
+	var attempts int
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		attempts++
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	})
+	if g, w := status.Code(err), codes.Aborted; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !strings.Contains(err.Error(), "Aborted 2") {
+		t.Fatalf("Error mismatch\nGot: %v\nWant: error of attempt %d", err, maxAttempts)
+	}
+	if g, w := attempts, maxAttempts; g != w {
+		t.Fatalf("Number of attempts mismatch\nGot: %d\nWant: %d", g, w)
+	}
+
+	// The limit of a transaction overrides the limit of the client.
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, aborted)
+	attempts = 0
+	_, err = client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		attempts++
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	}, ReadWriteTransactionOptions{MaxAttempts: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := attempts, 4; g != w {
+		t.Fatalf("Number of attempts mismatch\nGot: %d\nWant: %d", g, w)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_Timeout(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// The context of the caller has no deadline.
+	ctx := context.Background()
+	_, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		// Simulate a slow transaction function.
+		time.Sleep(200 * time.Millisecond)
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	}, ReadWriteTransactionOptions{Timeout: 50 * time.Millisecond})
+	if g, w := ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ReadWriteTransactionWithOptions_RawTransactionOptions(t *testing.T) {
+	t.Parallel()
+	// Do not prepare any sessions for read-write transactions in advance.
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		SessionPoolConfig: SessionPoolConfig{WriteSessions: 0},
+	})
+	defer teardown()
+	ctx := context.Background()
+
+	raw := &sppb.TransactionOptions{
+		Mode: &sppb.TransactionOptions_ReadWrite_{
+			ReadWrite: &sppb.TransactionOptions_ReadWrite{},
+		},
+	}
+	_, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		_, err := tx.Update(ctx, Statement{SQL: UpdateBarSetFoo})
+		return err
+	}, ReadWriteTransactionOptions{RawTransactionOptions: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var begins []*sppb.BeginTransactionRequest
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if begin, ok := req.(*sppb.BeginTransactionRequest); ok {
+			begins = append(begins, begin)
+		}
+	}
+	if g, w := len(begins), 1; g != w {
+		t.Fatalf("BeginTransaction request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if !proto.Equal(begins[0].Options, raw) {
+		t.Fatalf("transaction options mismatch\nGot: %v\nWant: %v", begins[0].Options, raw)
+	}
+
+	// Raw options for a read-only transaction are not allowed.
+	_, err = client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return nil
+	}, ReadWriteTransactionOptions{RawTransactionOptions: &sppb.TransactionOptions{
+		Mode: &sppb.TransactionOptions_ReadOnly_{
+			ReadOnly: &sppb.TransactionOptions_ReadOnly{},
+		},
+	}})
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_ReadRowWithOptions(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	rowType := &sppb.StructType{
+		Fields: []*sppb.StructType_Field{
+			{Name: "AccountId", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+		},
+	}
+	server.TestSpanner.PutReadResult("Accounts", &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: rowType},
+			Rows:     []*proto3.ListValue{{Values: []*proto3.Value{intProto(1)}}},
+		},
+	})
+	server.TestSpanner.PutReadResult("Empty", &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: rowType},
+		},
+	})
+	ctx := context.Background()
+	columns := []string{"AccountId"}
+
+	// An existing row is returned regardless of AllowMissing.
+	for _, opts := range []*ReadRowOptions{nil, {AllowMissing: true}} {
+		row, err := client.Single().ReadRowWithOptions(ctx, "Accounts", Key{1}, columns, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var id int64
+		if err := row.Column(0, &id); err != nil {
+			t.Fatal(err)
+		}
+		if g, w := id, int64(1); g != w {
+			t.Fatalf("AccountId mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	}
+
+	// A missing row is an error by default.
+	_, err := client.Single().ReadRowWithOptions(ctx, "Empty", Key{1}, columns, nil)
+	if g, w := ErrCode(err), codes.NotFound; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// A missing row returns nil if AllowMissing is set.
+	row, err := client.Single().ReadRowWithOptions(ctx, "Empty", Key{1}, columns, &ReadRowOptions{AllowMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row != nil {
+		t.Fatalf("Row mismatch\nGot: %v\nWant: nil", row)
+	}
+}
+
+func TestClient_SessionPoolConfig(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	got := client.SessionPoolConfig()
+	if g, w := got.MaxOpened, uint64(numChannels*100); g != w {
+		t.Errorf("MaxOpened mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.MaxBurst, DefaultSessionPoolConfig.MaxBurst; g != w {
+		t.Errorf("MaxBurst mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.HealthCheckWorkers, DefaultSessionPoolConfig.HealthCheckWorkers; g != w {
+		t.Errorf("HealthCheckWorkers mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.HealthCheckInterval, DefaultSessionPoolConfig.HealthCheckInterval; g != w {
+		t.Errorf("HealthCheckInterval mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.healthCheckSampleInterval, time.Minute; g != w {
+		t.Errorf("healthCheckSampleInterval mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// Changing the returned config should not affect the client.
+	got.MaxOpened = 1
+	if client.SessionPoolConfig().MaxOpened == 1 {
+		t.Fatal("SessionPoolConfig did not return a copy")
+	}
+}
+
+func TestClient_DialTimeout(t *testing.T) {
+	t.Parallel()
+	// Create a listener that accepts connections, but never responds.
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns []net.Conn
+	accepted := make(chan struct{})
+	go func() {
+		defer close(accepted)
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	defer func() {
+		// Stop accepting connections before closing the accepted ones.
+		lis.Close()
+		<-accepted
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	database := "projects/[PROJECT]/instances/[INSTANCE]/databases/[DATABASE]"
+	timeout := 100 * time.Millisecond
+	start := time.Now()
+	_, err = NewClientWithConfig(ctx, database, ClientConfig{DialTimeout: timeout},
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+		option.WithoutAuthentication(),
+	)
+	if g, w := ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Fatalf("NewClientWithConfig took too long to fail: %v", elapsed)
+	}
+}
+
+func TestClient_DialTimeout_Connected(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{DialTimeout: 5 * time.Second})
+	defer teardown()
+
+	if _, err := client.Apply(context.Background(), []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compressionCounter is a client stats handler that counts the number of RPCs
+// that have sent their messages with the gzip compressor. The stats handler
+// only sees the RPCs of the client, and not the responses that the mock
+// server compresses with the same compressor.
+type compressionCounter struct {
+	count int32
+}
+
+func (c *compressionCounter) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (c *compressionCounter) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.OutHeader); ok && h.Client && h.Compression == gzip.Name {
+		atomic.AddInt32(&c.count, 1)
+	}
+}
+
+func (c *compressionCounter) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (c *compressionCounter) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestClient_CompressionThreshold(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	counter := &compressionCounter{}
+	_, client, teardown := setupMockedTestServerWithConfigAndClientOptions(t,
+		ClientConfig{CompressionThreshold: 1024},
+		[]option.ClientOption{
+			option.WithGRPCDialOption(grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name))),
+			option.WithGRPCDialOption(grpc.WithStatsHandler(counter)),
+		},
+	)
+	defer teardown()
+
+	small := []*Mutation{Insert("Accounts", []string{"AccountId", "Nickname"}, []interface{}{int64(1), "Foo"})}
+	if _, err := client.Apply(ctx, small, ApplyAtLeastOnce()); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := atomic.LoadInt32(&counter.count), int32(0); g != w {
+		t.Fatalf("Number of compressed messages mismatch\nGot: %d\nWant: %d", g, w)
+	}
+	large := []*Mutation{Insert("Accounts", []string{"AccountId", "Nickname"}, []interface{}{int64(2), strings.Repeat("a", 4096)})}
+	if _, err := client.Apply(ctx, large, ApplyAtLeastOnce()); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := atomic.LoadInt32(&counter.count), int32(1); g != w {
+		t.Fatalf("Number of compressed messages mismatch\nGot: %d\nWant: %d", g, w)
+	}
+}
+
+func TestClient_Compression(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	counter := &compressionCounter{}
+	server, client, teardown := setupMockedTestServerWithConfigAndClientOptions(t,
+		ClientConfig{Compression: gzip.Name},
+		[]option.ClientOption{option.WithGRPCDialOption(grpc.WithStatsHandler(counter))},
+	)
+	defer teardown()
+
+	ms := []*Mutation{Insert("Accounts", []string{"AccountId", "Nickname"}, []interface{}{int64(1), "Foo"})}
+	if _, err := client.Apply(ctx, ms, ApplyAtLeastOnce()); err != nil {
+		t.Fatal(err)
+	}
+	// All RPCs of the client are compressed, including the requests of the
+	// session pool.
+	if g, w := atomic.LoadInt32(&counter.count), int32(len(drainRequestsFromServer(server.TestSpanner))); g != w {
+		t.Fatalf("Number of compressed messages mismatch\nGot: %d\nWant: %d", g, w)
+	}
+
+	_, err := NewClientWithConfig(ctx, "projects/p/instances/i/databases/d", ClientConfig{Compression: "unknown"})
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryToMaps(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	sql := "SELECT ID, Name, Score, Tags FROM Mixed"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("ID", intType()),
+						mkField("Name", stringType()),
+						mkField("Score", floatType()),
+						mkField("Tags", listType(stringType())),
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), stringProto("foo"), floatProto(1.5), listProto(stringProto("a"), nullProto())),
+				listValueProto(intProto(2), nullProto(), nullProto(), nullProto()),
+			},
+		},
+	})
+	got, err := QueryToMaps(ctx, client.Single(), NewStatement(sql))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"ID": int64(1), "Name": "foo", "Score": 1.5, "Tags": []interface{}{"a", nil}},
+		{"ID": int64(2), "Name": nil, "Score": nil, "Tags": nil},
+	}
+	if !testEqual(got, want) {
+		t.Fatalf("Rows mismatch\nGot: %v\nWant: %v", got, want)
+	}
+}
+
+func TestClient_QueryWithSplitArray(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	sql := "SELECT ID FROM Ids WHERE ID IN UNNEST(@ids)"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{mkField("ID", intType())},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1)),
+				listValueProto(intProto(2)),
+			},
+		},
+	})
+	ids := make([]int64, 25)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	stmt := Statement{SQL: sql, Params: map[string]interface{}{"ids": ids, "other": "foo"}}
+	tx := client.ReadOnlyTransaction()
+	defer tx.Close()
+	var got []int64
+	if err := QueryWithSplitArray(ctx, tx, stmt, "ids", 10, func(r *Row) error {
+		var id int64
+		if err := r.Columns(&id); err != nil {
+			return err
+		}
+		got = append(got, id)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The mock server returns the same rows for each query.
+	if g, w := got, []int64{1, 2, 1, 2, 1, 2}; !testEqual(g, w) {
+		t.Fatalf("rows mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var lengths []int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if sqlReq, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			lengths = append(lengths, len(sqlReq.Params.Fields["ids"].GetListValue().GetValues()))
+			if g, w := sqlReq.Params.Fields["other"].GetStringValue(), "foo"; g != w {
+				t.Fatalf("param mismatch\nGot: %v\nWant: %v", g, w)
+			}
+		}
+	}
+	if g, w := lengths, []int{10, 10, 5}; !testEqual(g, w) {
+		t.Fatalf("array param lengths mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The statement of the caller is not modified.
+	if g, w := len(stmt.Params["ids"].([]int64)), len(ids); g != w {
+		t.Fatalf("array param length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	err := QueryWithSplitArray(ctx, tx, NewStatement(sql), "ids", 10, func(r *Row) error { return nil })
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryWithOptions_TypeCoercions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	sql := "SELECT ID, UpdatedAt FROM Events"
+	ts := time.Date(2020, 3, 1, 10, 0, 0, 123000000, time.UTC)
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("ID", intType()),
+						mkField("UpdatedAt", timeType()),
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), timeProto(ts)),
+				listValueProto(intProto(2), nullProto()),
+			},
+		},
+	})
+	toMillis := func(v GenericColumnValue) (interface{}, error) {
+		var t NullTime
+		if err := v.Decode(&t); err != nil {
+			return nil, err
+		}
+		if !t.Valid {
+			return NullInt64{}, nil
+		}
+		return t.Time.UnixNano() / int64(time.Millisecond), nil
+	}
+	iter := client.Single().QueryWithOptions(ctx, NewStatement(sql), QueryOptions{
+		TypeCoercions: map[sppb.TypeCode]TypeCoercion{sppb.TypeCode_TIMESTAMP: toMillis},
+	})
+	var got []NullInt64
+	if err := iter.Do(func(r *Row) error {
+		var id int64
+		var millis NullInt64
+		if err := r.Columns(&id, &millis); err != nil {
+			return err
+		}
+		got = append(got, millis)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []NullInt64{{Int64: ts.UnixNano() / int64(time.Millisecond), Valid: true}, {}}
+	if !testEqual(got, want) {
+		t.Fatalf("coerced values mismatch\nGot: %v\nWant: %v", got, want)
+	}
+
+	// An error that is returned by a coercion stops the iteration.
+	iter = client.Single().QueryWithOptions(ctx, NewStatement(sql), QueryOptions{
+		TypeCoercions: map[sppb.TypeCode]TypeCoercion{
+			sppb.TypeCode_INT64: func(GenericColumnValue) (interface{}, error) {
+				return nil, fmt.Errorf("coercion failed")
+			},
+		},
+	})
+	err := iter.Do(func(r *Row) error { return nil })
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryWithOptions_OnDecodeError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	sql := "SELECT ID, Name FROM Malformed"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("ID", intType()),
+						mkField("Name", stringType()),
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), stringProto("foo")),
+				// The INT64 value is not a valid integer.
+				listValueProto(stringProto("two"), stringProto("bar")),
+				listValueProto(intProto(3), stringProto("baz")),
+			},
+		},
+	})
+	query := func(action DecodeErrorAction) ([]int64, int, error) {
+		var calls int
+		iter := client.Single().QueryWithOptions(ctx, NewStatement(sql), QueryOptions{
+			OnDecodeError: func(row *Row, err error) DecodeErrorAction {
+				calls++
+				return action
+			},
+		})
+		var ids []int64
+		err := iter.Do(func(r *Row) error {
+			var id int64
+			if err := r.Column(0, &id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+		return ids, calls, err
+	}
+
+	ids, calls, err := query(DecodeErrorSkipRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := ids, []int64{1, 3}; !testEqual(g, w) {
+		t.Fatalf("IDs mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := calls, 1; g != w {
+		t.Fatalf("OnDecodeError calls mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	ids, calls, err = query(DecodeErrorAbort)
+	if g, w := ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := ids, []int64{1}; !testEqual(g, w) {
+		t.Fatalf("IDs mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := calls, 1; g != w {
+		t.Fatalf("OnDecodeError calls mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryWithOptions_DuplicateColumnNames(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	sql := "SELECT * FROM Singers JOIN Albums ON Singers.Id = Albums.SingerId"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						mkField("Id", intType()),
+						mkField("Name", stringType()),
+						mkField("Id", intType()),
+						mkField("SingerId", intType()),
+						mkField("Name", stringType()),
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), stringProto("Singer"), intProto(10), intProto(1), stringProto("Album")),
+			},
+		},
+	})
+
+	// The default returns the column names as they are, and accessing a
+	// duplicate column by name fails.
+	iter := client.Single().Query(ctx, NewStatement(sql))
+	err := iter.Do(func(r *Row) error {
+		want := []string{"Id", "Name", "Id", "SingerId", "Name"}
+		if got := r.ColumnNames(); !testEqual(got, want) {
+			t.Fatalf("column names mismatch\nGot: %v\nWant: %v", got, want)
+		}
+		var id int64
+		return r.ColumnByName("Id", &id)
+	})
+	if g, w := ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Suffixing renames the duplicate columns.
+	iter = client.Single().QueryWithOptions(ctx, NewStatement(sql), QueryOptions{
+		DuplicateColumnNames: DuplicateColumnNamesSuffix,
+	})
+	var rows int
+	if err := iter.Do(func(r *Row) error {
+		rows++
+		want := []string{"Id", "Name", "Id_1", "SingerId", "Name_1"}
+		if got := r.ColumnNames(); !testEqual(got, want) {
+			t.Fatalf("column names mismatch\nGot: %v\nWant: %v", got, want)
+		}
+		var albumID int64
+		var albumName string
+		if err := r.ColumnByName("Id_1", &albumID); err != nil {
+			return err
+		}
+		if err := r.ColumnByName("Name_1", &albumName); err != nil {
+			return err
+		}
+		if albumID != 10 || albumName != "Album" {
+			t.Fatalf("album mismatch\nGot: %v, %v\nWant: %v, %v", albumID, albumName, 10, "Album")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rows, 1; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// Returning an error fails the query.
+	iter = client.Single().QueryWithOptions(ctx, NewStatement(sql), QueryOptions{
+		DuplicateColumnNames: DuplicateColumnNamesError,
+	})
+	err = iter.Do(func(r *Row) error {
+		t.Fatal("unexpected row for query with duplicate column names")
+		return nil
+	})
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryWithOptions_RetryDeadlineExceeded(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// The first attempt will not return any results within the attempt
+	// timeout. The second attempt will succeed.
+	server.TestSpanner.AddPartialResultSetError(
+		SelectSingerIDAlbumIDAlbumTitleFromAlbums,
+		PartialResultSetExecutionTime{
+			ResumeToken:   EncodeResumeToken(1),
+			ExecutionTime: time.Second,
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	iter := client.Single().QueryWithOptions(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums), QueryOptions{
+		AttemptTimeout:        100 * time.Millisecond,
+		RetryDeadlineExceeded: true,
+	})
+	var rowCount int64
+	if err := iter.Do(func(r *Row) error {
+		rowCount++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rowCount, SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("Row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_QueryWithOptions_RetryResourceExhausted(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	serverDelay := 300 * time.Millisecond
+	st, err := status.New(codes.ResourceExhausted, "too many requests").WithDetails(&edpb.RetryInfo{
+		RetryDelay: ptypes.DurationProto(serverDelay),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceExhausted := SimulatedExecutionTime{Errors: []error{st.Err()}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// ResourceExhausted is not retried by default.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, resourceExhausted)
+	iter := client.Single().Query(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	err = iter.Do(func(r *Row) error { return nil })
+	if g, w := ErrCode(err), codes.ResourceExhausted; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// If enabled, the query is retried after the delay of the server.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, resourceExhausted)
+	start := time.Now()
+	iter = client.Single().QueryWithOptions(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums), QueryOptions{
+		RetryResourceExhausted: true,
+	})
+	var rowCount int64
+	if err := iter.Do(func(r *Row) error {
+		rowCount++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < serverDelay {
+		t.Fatalf("Retry delay mismatch\nGot: %v\nWant at least: %v", elapsed, serverDelay)
+	}
+	if g, w := rowCount, SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("Row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_QueryWithOptions_AttemptTimeoutWithoutRetry(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	server.TestSpanner.AddPartialResultSetError(
+		SelectSingerIDAlbumIDAlbumTitleFromAlbums,
+		PartialResultSetExecutionTime{
+			ResumeToken:   EncodeResumeToken(1),
+			ExecutionTime: time.Second,
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	iter := client.Single().QueryWithOptions(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums), QueryOptions{
+		AttemptTimeout: 100 * time.Millisecond,
+	})
+	err := iter.Do(func(r *Row) error { return nil })
+	if g, w := ErrCode(err), codes.DeadlineExceeded; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+	"github.com/golang/protobuf/proto"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// coalescedQueryTimeout is the timeout of a query that is shared by the
+// callers of a QueryCoalescer.
+const coalescedQueryTimeout = 5 * time.Minute
+
+// QueryCoalescer coalesces identical read-only queries that are executed
+// concurrently. Only one of the identical queries is sent to Cloud Spanner,
+// and the result is shared with all callers that executed the query while it
+// was running.
+//
+// The queries are executed in single-use read-only transactions with the
+// timestamp bound of the QueryCoalescer. A caller that joins a query that is
+// already running receives the result of that query, which may have been read
+// at an earlier timestamp than a query that would have been started by the
+// caller. The timestamp bound should therefore allow the staleness that is
+// acceptable to the application, for example MaxStaleness(10*time.Second).
+//
+// A QueryCoalescer is safe for concurrent use by multiple goroutines.
+type QueryCoalescer struct {
+	client *Client
+	tb     TimestampBound
+
+	mu    sync.Mutex
+	calls map[string]*coalescedQuery
+}
+
+// coalescedQuery is a query that is executed on behalf of one or more callers.
+type coalescedQuery struct {
+	// done is closed when the query has finished.
+	done chan struct{}
+	rows []*Row
+	err  error
+}
+
+// NewQueryCoalescer returns a QueryCoalescer that executes queries on the
+// given client with the given timestamp bound.
+func NewQueryCoalescer(client *Client, tb TimestampBound) *QueryCoalescer {
+	return &QueryCoalescer{
+		client: client,
+		tb:     tb,
+		calls:  make(map[string]*coalescedQuery),
+	}
+}
+
+// Query executes the given statement and returns all rows of the result. If
+// an identical statement is already being executed by the QueryCoalescer,
+// Query waits for that statement to finish and returns its result instead of
+// executing the statement again.
+//
+// The returned rows may be shared with other callers and must not be modified.
+// The shared query is not cancelled when the context of one of its callers is
+// done, and has a timeout of five minutes instead. Each caller stops waiting
+// for the query when its own context is done.
+func (q *QueryCoalescer) Query(ctx context.Context, statement Statement) (rows []*Row, err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.QueryCoalescer.Query")
+	defer func() { trace.EndSpan(ctx, err) }()
+	key, err := coalesceKey(statement)
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	c, ok := q.calls[key]
+	if ok {
+		trace.TracePrintf(ctx, nil, "Waiting for identical query")
+	} else {
+		c = &coalescedQuery{done: make(chan struct{})}
+		q.calls[key] = c
+		go q.run(detachedContext{ctx}, key, c, statement)
+	}
+	q.mu.Unlock()
+	select {
+	case <-c.done:
+		return c.rows, c.err
+	case <-ctx.Done():
+		return nil, toSpannerError(ctx.Err())
+	}
+}
+
+// run executes the shared query c and closes c.done when it has finished.
+func (q *QueryCoalescer) run(ctx context.Context, key string, c *coalescedQuery, statement Statement) {
+	ctx, cancel := context.WithTimeout(ctx, coalescedQueryTimeout)
+	defer cancel()
+	c.rows, c.err = q.query(ctx, statement)
+	q.mu.Lock()
+	delete(q.calls, key)
+	q.mu.Unlock()
+	close(c.done)
+}
+
+// query executes the statement in a single-use read-only transaction and
+// returns all rows of the result.
+func (q *QueryCoalescer) query(ctx context.Context, statement Statement) ([]*Row, error) {
+	iter := q.client.Single().WithTimestampBound(q.tb).Query(ctx, statement)
+	defer iter.Stop()
+	var rows []*Row
+	if err := iter.Do(func(r *Row) error {
+		rows = append(rows, r)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// coalesceKey returns the key that is used to identify identical statements.
+// Two statements are identical if they have the same SQL string and the same
+// parameter values and types.
+func coalesceKey(statement Statement) (string, error) {
+	params, paramTypes, err := statement.convertParams()
+	if err != nil {
+		return "", err
+	}
+	var b proto.Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(&sppb.ExecuteSqlRequest{
+		Sql:        statement.SQL,
+		Params:     params,
+		ParamTypes: paramTypes,
+	}); err != nil {
+		return "", toSpannerError(err)
+	}
+	return string(b.Bytes()), nil
+}
+
+// detachedContext is a context that carries the values of its parent, such as
+// the trace span and correlation ID, but is not done when its parent is done.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
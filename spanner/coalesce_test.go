@@ -0,0 +1,171 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+)
+
+func TestQueryCoalescer(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	// Make sure that the queries overlap.
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		MinimumExecutionTime: 200 * time.Millisecond,
+	})
+	qc := NewQueryCoalescer(client, MaxStaleness(10*time.Second))
+
+	const numQueries = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, numQueries)
+	rowCounts := make(chan int, numQueries)
+	for i := 0; i < numQueries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows, err := qc.Query(context.Background(), NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+			if err != nil {
+				errs <- err
+				return
+			}
+			rowCounts <- len(rows)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(rowCounts)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for c := range rowCounts {
+		if g, w := int64(c), SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+			t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	}
+	var queries int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			queries++
+		}
+	}
+	if g, w := queries, 1; g != w {
+		t.Fatalf("ExecuteSql request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// A query that is executed after the previous query finished should be
+	// sent to Cloud Spanner.
+	if _, err := qc.Query(context.Background(), NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums)); err != nil {
+		t.Fatal(err)
+	}
+	queries = 0
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			queries++
+		}
+	}
+	if g, w := queries, 1; g != w {
+		t.Fatalf("ExecuteSql request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestQueryCoalescer_CallerCancelled(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		MinimumExecutionTime: 200 * time.Millisecond,
+	})
+	qc := NewQueryCoalescer(client, MaxStaleness(10*time.Second))
+	stmt := NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums)
+
+	// The first caller starts the query and cancels it while the second
+	// caller is waiting for the result.
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := qc.Query(ctx, stmt)
+		firstErr <- err
+	}()
+	for {
+		qc.mu.Lock()
+		n := len(qc.calls)
+		qc.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rowsCh := make(chan []*Row, 1)
+	secondErr := make(chan error, 1)
+	go func() {
+		rows, err := qc.Query(context.Background(), stmt)
+		rowsCh <- rows
+		secondErr <- err
+	}()
+	cancel()
+	if g, w := ErrCode(<-firstErr), codes.Canceled; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The cancellation of the first caller does not cancel the shared query.
+	rows := <-rowsCh
+	if err := <-secondErr; err != nil {
+		t.Fatal(err)
+	}
+	if g, w := int64(len(rows)), SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var queries int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			queries++
+		}
+	}
+	if g, w := queries, 1; g != w {
+		t.Fatalf("ExecuteSql request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	key := func(s Statement) string {
+		k, err := coalesceKey(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	s1 := Statement{SQL: "SELECT * FROM Singers WHERE Id=@id", Params: map[string]interface{}{"id": int64(1), "name": "foo"}}
+	s2 := Statement{SQL: "SELECT * FROM Singers WHERE Id=@id", Params: map[string]interface{}{"name": "foo", "id": int64(1)}}
+	s3 := Statement{SQL: "SELECT * FROM Singers WHERE Id=@id", Params: map[string]interface{}{"id": int64(2), "name": "foo"}}
+	s4 := Statement{SQL: "SELECT * FROM Singers WHERE Id=@id", Params: map[string]interface{}{"id": "1", "name": "foo"}}
+	if key(s1) != key(s2) {
+		t.Fatal("identical statements should have the same key")
+	}
+	if key(s1) == key(s3) {
+		t.Fatal("statements with different parameter values should have different keys")
+	}
+	if key(s1) == key(s4) {
+		t.Fatal("statements with different parameter types should have different keys")
+	}
+}
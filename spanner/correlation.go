@@ -0,0 +1,64 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	octrace "go.opencensus.io/trace"
+)
+
+// correlationIDAttribute is the name of the span attribute that contains the
+// correlation ID of a context.
+const correlationIDAttribute = "correlationID"
+
+// correlationIDKey is the context key of the correlation ID.
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx that carries the given correlation
+// ID. The client adds the correlation ID as the span attribute correlationID
+// to the trace span of each operation that is executed with the returned
+// context, or a context that is derived from it. This can be used to link the
+// traces of the transactions that belong to the same user request.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// correlationID returns the correlation ID of ctx, or an empty string if ctx
+// has no correlation ID.
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// addCorrelationIDAttribute adds the correlation ID of ctx to the current
+// span of ctx, if ctx has a correlation ID.
+func addCorrelationIDAttribute(ctx context.Context) {
+	if id := correlationID(ctx); id != "" {
+		octrace.FromContext(ctx).AddAttributes(octrace.StringAttribute(correlationIDAttribute, id))
+	}
+}
+
+// startSpan starts a trace span with the given name and adds the correlation
+// ID of ctx to it. All operations of the client start their spans with this
+// function, so that each span of an operation carries the correlation ID.
+func startSpan(ctx context.Context, name string) context.Context {
+	ctx = trace.StartSpan(ctx, name)
+	addCorrelationIDAttribute(ctx)
+	return ctx
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	octrace "go.opencensus.io/trace"
+)
+
+// spanRecorder is a trace exporter that records the exported spans.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*octrace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *octrace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// spansWithAttribute returns the names of the recorded spans that have the
+// given attribute value.
+func (r *spanRecorder) spansWithAttribute(key string, value interface{}) map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make(map[string]bool)
+	for _, s := range r.spans {
+		if s.Attributes[key] == value {
+			names[s.Name] = true
+		}
+	}
+	return names
+}
+
+func TestWithCorrelationID(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	recorder := &spanRecorder{}
+	octrace.RegisterExporter(recorder)
+	defer octrace.UnregisterExporter(recorder)
+
+	ctx := WithCorrelationID(context.Background(), "request-1")
+	if g, w := correlationID(ctx), "request-1"; g != w {
+		t.Fatalf("correlation ID mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	ctx, span := octrace.StartSpan(ctx, "TestWithCorrelationID", octrace.WithSampler(octrace.AlwaysSample()))
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		_, err := tx.Update(ctx, NewStatement(UpdateBarSetFoo))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PartitionedUpdate(ctx, NewStatement(UpdateBarSetFoo)); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	// Operations without a correlation ID do not get the attribute.
+	ctx, span = octrace.StartSpan(context.Background(), "TestWithCorrelationID", octrace.WithSampler(octrace.AlwaysSample()))
+	if _, err := client.Apply(ctx, []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}, ApplyAtLeastOnce()); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	got := recorder.spansWithAttribute(correlationIDAttribute, "request-1")
+	for _, name := range []string{
+		"cloud.google.com/go/spanner.Query",
+		"cloud.google.com/go/spanner.Update",
+		"cloud.google.com/go/spanner.ReadWriteTransaction",
+		"cloud.google.com/go/spanner.PartitionedUpdate",
+	} {
+		if !got[name] {
+			t.Errorf("missing correlation ID on span %v, got %v", name, got)
+		}
+	}
+	if got["cloud.google.com/go/spanner.Apply"] {
+		t.Errorf("unexpected correlation ID on span %v", "cloud.google.com/go/spanner.Apply")
+	}
+}
@@ -317,13 +317,28 @@ the Go struct. If a field in the struct type definition has a
 used as the name for that field in the built StructType, otherwise the field
 name in the struct definition is used. To specify a field with an empty field
 name in a Cloud Spanner STRUCT type, use the `spanner:""` tag annotation against
-the corresponding field in the Go struct's type definition.
+the corresponding field in the Go struct's type definition. Fields that are
+part of the primary key of a table can be marked with the key option, for
+example `spanner:"SingerId,key"`, and KeyFromStruct returns the primary key of
+a row from such a struct.
 
 A STRUCT value can contain STRUCT-typed and Array-of-STRUCT typed fields and
 these can be specified using named struct-typed and []struct-typed fields inside
 a Go struct. However, embedded struct fields are not allowed. Unexported struct
 fields are ignored.
 
+An ARRAY<STRUCT> column can be decoded into a slice of Go structs or a slice of
+pointers to Go structs, and nested ARRAY<STRUCT> fields are decoded in the same
+way. Only a slice of pointers can hold NULL elements. The fields of each STRUCT
+are matched to the fields of the Go struct by name, and decoding fails if a
+STRUCT field has no corresponding Go field, unless the Go struct contains a
+blank field with the ignoreunknown option:
+
+    type Album struct {
+        _     struct{} `spanner:",ignoreunknown"`
+        Title string
+    }
+
 NULL STRUCT values in Cloud Spanner are typed. A nil pointer to a Go struct
 value can be used to specify a NULL STRUCT value of the corresponding
 StructType.  Nil and empty slices of a Go STRUCT type can be used to specify
@@ -1650,7 +1650,7 @@ func TestIntegration_BatchQuery(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer txn.Cleanup(ctx)
-	if partitions, err = txn.PartitionQuery(ctx, stmt, PartitionOptions{0, 3}); err != nil {
+	if partitions, err = txn.PartitionQuery(ctx, stmt, PartitionOptions{MaxPartitions: 3}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1735,7 +1735,7 @@ func TestIntegration_BatchRead(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer txn.Cleanup(ctx)
-	if partitions, err = txn.PartitionRead(ctx, "test", AllKeys(), simpleDBTableColumns, PartitionOptions{0, 3}); err != nil {
+	if partitions, err = txn.PartitionRead(ctx, "test", AllKeys(), simpleDBTableColumns, PartitionOptions{MaxPartitions: 3}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1808,7 +1808,7 @@ func TestIntegration_BROTNormal(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer txn.Cleanup(ctx)
-	if _, err := txn.PartitionRead(ctx, "test", AllKeys(), simpleDBTableColumns, PartitionOptions{0, 3}); err != nil {
+	if _, err := txn.PartitionRead(ctx, "test", AllKeys(), simpleDBTableColumns, PartitionOptions{MaxPartitions: 3}); err != nil {
 		t.Fatal(err)
 	}
 	// Normal query should work with BatchReadOnlyTransaction.
@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	emptypb "github.com/golang/protobuf/ptypes/empty"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/golang/protobuf/ptypes/timestamp"
@@ -62,6 +63,7 @@ const (
 	MethodGetSession          string = "GET_SESSION"
 	MethodExecuteSql          string = "EXECUTE_SQL"
 	MethodExecuteStreamingSql string = "EXECUTE_STREAMING_SQL"
+	MethodStreamingRead       string = "STREAMING_READ"
 )
 
 // StatementResult represents a mocked result on the test server. The result is
@@ -113,12 +115,33 @@ func (s *StatementResult) toPartialResultSets(resumeToken []byte) (result []*spa
 		})
 		startIndex += rowCount
 		if startIndex == totalRows {
+			// Include the statistics of the result set, if any, in the last
+			// PartialResultSet.
+			// The statistics are shared by all streams of the result, so
+			// each stream gets its own copy.
+			if s.ResultSet.Stats != nil {
+				result[len(result)-1].Stats = proto.Clone(s.ResultSet.Stats).(*spannerpb.ResultSetStats)
+			}
 			break
 		}
 	}
 	return result, nil
 }
 
+// setReadTimestamp adds the read timestamp of a single-use read-only
+// transaction to the metadata of the first PartialResultSet if the transaction
+// selector requests it.
+func setReadTimestamp(parts []*spannerpb.PartialResultSet, ts *spannerpb.TransactionSelector) {
+	if len(parts) == 0 || !ts.GetSingleUse().GetReadOnly().GetReturnReadTimestamp() {
+		return
+	}
+	// The metadata is shared by all streams of the result, and must not be
+	// modified.
+	md := proto.Clone(parts[0].Metadata).(*spannerpb.ResultSetMetadata)
+	md.Transaction = &spannerpb.Transaction{ReadTimestamp: getCurrentTimestamp()}
+	parts[0].Metadata = md
+}
+
 func min(x, y uint64) uint64 {
 	if x > y {
 		return y
@@ -187,6 +210,11 @@ type InMemSpannerServer interface {
 	// expect a SQL statement, including (batch) DML methods.
 	PutStatementResult(sql string, result *StatementResult) error
 
+	// Puts a mocked result on the server for reads from a specific table. The
+	// result will be returned for all StreamingRead requests for the table,
+	// regardless of the columns and keys that are requested.
+	PutReadResult(table string, result *StatementResult) error
+
 	// Adds a PartialResultSetExecutionTime to the server that should be returned
 	// for the specified SQL string.
 	AddPartialResultSetError(sql string, err PartialResultSetExecutionTime)
@@ -212,6 +240,10 @@ type InMemSpannerServer interface {
 
 	ReceivedRequests() chan interface{}
 	DumpSessions() map[string]bool
+	// Drops all sessions from the server. This simulates a switch to an
+	// endpoint that does not know the sessions that were created before.
+	// Requests that use a dropped session will fail with `Session not found`.
+	DropAllSessions()
 	ClearPings()
 	DumpPings() []string
 }
@@ -234,6 +266,8 @@ type inMemSpannerServer struct {
 	sessions map[string]*spannerpb.Session
 	// Last use times per session.
 	sessionLastUseTime map[string]time.Time
+	// The sessions that have been dropped by DropAllSessions.
+	droppedSessions map[string]bool
 	// The mock server creates transaction IDs per session using these
 	// counters.
 	transactionCounters map[string]*uint64
@@ -245,6 +279,8 @@ type inMemSpannerServer struct {
 	partitionedDmlTransactions map[string]bool
 	// The mocked results for this server.
 	statementResults map[string]*StatementResult
+	// The mocked read results per table for this server.
+	readResults map[string]*StatementResult
 	// The simulated execution times per method.
 	executionTimes map[string]*SimulatedExecutionTime
 	// The simulated errors for partial result sets
@@ -268,6 +304,7 @@ func NewInMemSpannerServer() InMemSpannerServer {
 	res := &inMemSpannerServer{}
 	res.initDefaults()
 	res.statementResults = make(map[string]*StatementResult)
+	res.readResults = make(map[string]*StatementResult)
 	res.executionTimes = make(map[string]*SimulatedExecutionTime)
 	res.partialResultSetErrors = make(map[string][]*PartialResultSetExecutionTime)
 	res.receivedRequests = make(chan interface{}, 1000000)
@@ -309,6 +346,14 @@ func (s *inMemSpannerServer) PutStatementResult(sql string, result *StatementRes
 	return nil
 }
 
+// Registers a mocked result for reads from a table on the server.
+func (s *inMemSpannerServer) PutReadResult(table string, result *StatementResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readResults[table] = result
+	return nil
+}
+
 func (s *inMemSpannerServer) RemoveStatementResult(sql string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -409,11 +454,21 @@ func (s *inMemSpannerServer) DumpSessions() map[string]bool {
 	return st
 }
 
+func (s *inMemSpannerServer) DropAllSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.sessions {
+		s.droppedSessions[name] = true
+	}
+	s.sessions = make(map[string]*spannerpb.Session)
+}
+
 func (s *inMemSpannerServer) initDefaults() {
 	s.sessionCounter = 0
 	s.maxSessionsReturnedByServerPerBatchRequest = 100
 	s.sessions = make(map[string]*spannerpb.Session)
 	s.sessionLastUseTime = make(map[string]time.Time)
+	s.droppedSessions = make(map[string]bool)
 	s.transactions = make(map[string]*spannerpb.Transaction)
 	s.abortedTransactions = make(map[string]bool)
 	s.partitionedDmlTransactions = make(map[string]bool)
@@ -429,6 +484,9 @@ func (s *inMemSpannerServer) findSession(name string) (*spannerpb.Session, error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	session := s.sessions[name]
+	if session == nil && s.droppedSessions[name] {
+		return nil, gstatus.Error(codes.NotFound, fmt.Sprintf("Session not found: %s", name))
+	}
 	if session == nil {
 		return nil, gstatus.Error(codes.NotFound, fmt.Sprintf("Session %s not found", name))
 	}
@@ -740,6 +798,7 @@ func (s *inMemSpannerServer) ExecuteStreamingSql(req *spannerpb.ExecuteSqlReques
 		if err != nil {
 			return err
 		}
+		setReadTimestamp(parts, req.Transaction)
 		var nextPartialResultSetError *PartialResultSetExecutionTime
 		s.mu.Lock()
 		pErrors := s.partialResultSetErrors[req.Sql]
@@ -830,14 +889,45 @@ func (s *inMemSpannerServer) Read(ctx context.Context, req *spannerpb.ReadReques
 }
 
 func (s *inMemSpannerServer) StreamingRead(req *spannerpb.ReadRequest, stream spannerpb.Spanner_StreamingReadServer) error {
-	s.mu.Lock()
-	if s.stopped {
-		s.mu.Unlock()
-		return gstatus.Error(codes.Unavailable, "server has been stopped")
+	if err := s.simulateExecutionTime(MethodStreamingRead, req); err != nil {
+		return err
 	}
-	s.receivedRequests <- req
+	if req.Session == "" {
+		return gstatus.Error(codes.InvalidArgument, "Missing session name")
+	}
+	session, err := s.findSession(req.Session)
+	if err != nil {
+		return err
+	}
+	s.updateSessionLastUseTime(session.Name)
+	if id := s.getTransactionID(session, req.Transaction); id != nil {
+		if _, err := s.getTransactionByID(id); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	readResult, ok := s.readResults[req.Table]
 	s.mu.Unlock()
-	return gstatus.Error(codes.Unimplemented, "Method not yet implemented")
+	if !ok {
+		return gstatus.Error(codes.Unimplemented, fmt.Sprintf("No read result found for table %v", req.Table))
+	}
+	switch readResult.Type {
+	case StatementResultError:
+		return readResult.Err
+	case StatementResultResultSet:
+		parts, err := readResult.toPartialResultSets(req.ResumeToken)
+		if err != nil {
+			return err
+		}
+		setReadTimestamp(parts, req.Transaction)
+		for _, part := range parts {
+			if err := stream.Send(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return gstatus.Error(codes.InvalidArgument, fmt.Sprintf("Invalid read result type for table %v", req.Table))
 }
 
 func (s *inMemSpannerServer) BeginTransaction(ctx context.Context, req *spannerpb.BeginTransactionRequest) (*spannerpb.Transaction, error) {
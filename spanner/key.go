@@ -19,6 +19,7 @@ package spanner
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -59,6 +60,65 @@ import (
 //   - civil.Date and NullDate are mapped to Cloud Spanner's DATE type.
 type Key []interface{}
 
+// errNoKeyFields returns error for a struct without any fields that are tagged
+// as part of the primary key.
+func errNoKeyFields(in interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "%T has no fields with the key option in the spanner tag", in)
+}
+
+// errNilStructPtr returns error for a nil pointer to a struct.
+func errNilStructPtr(in interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "cannot get key from nil %T", in)
+}
+
+// KeyFromStruct returns the primary key of a row from a Go struct or a
+// pointer to a Go struct. The parts of the key are the values of the fields
+// that have the key option in their spanner tag, in the order in which the
+// fields are declared in the struct. For example, the key of the following
+// struct consists of the values of SingerID and AlbumID:
+//
+// 	type Album struct {
+// 		SingerID int64  `spanner:"SingerId,key"`
+// 		AlbumID  int64  `spanner:"AlbumId,key"`
+// 		Title    string `spanner:"AlbumTitle"`
+// 	}
+//
+// The key option can also be used without a field name: `spanner:",key"`.
+func KeyFromStruct(in interface{}) (Key, error) {
+	if in == nil {
+		return nil, errNotStruct(in)
+	}
+	v := reflect.ValueOf(in)
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+		if v.IsNil() {
+			return nil, errNilStructPtr(in)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, errNotStruct(in)
+	}
+	fields, err := fieldCache.Fields(v.Type())
+	if err != nil {
+		return nil, toSpannerError(err)
+	}
+	var key Key
+	for _, f := range fields {
+		if opts, ok := f.ParsedTag.(spannerTagOptions); !ok || !opts.key {
+			continue
+		}
+		part := v.FieldByIndex(f.Index).Interface()
+		if _, err := keyPartValue(part); err != nil {
+			return nil, err
+		}
+		key = append(key, part)
+	}
+	if len(key) == 0 {
+		return nil, errNoKeyFields(in)
+	}
+	return key, nil
+}
+
 // errInvdKeyPartType returns error for unsupported key part type.
 func errInvdKeyPartType(part interface{}) error {
 	return spannerErrorf(codes.InvalidArgument, "key part has unsupported type %T", part)
@@ -23,6 +23,7 @@ import (
 	"cloud.google.com/go/civil"
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 // Test Key.String() and Key.proto().
@@ -370,3 +371,56 @@ func TestKeySets(t *testing.T) {
 		}
 	}
 }
+
+// Test KeyFromStruct.
+func TestKeyFromStruct(t *testing.T) {
+	type album struct {
+		Title    string `spanner:"AlbumTitle"`
+		SingerID int64  `spanner:"SingerId,key"`
+		AlbumID  int64  `spanner:",key"`
+		Ignored  int64  `spanner:"-"`
+	}
+	type noKey struct {
+		ID int64 `spanner:"Id"`
+	}
+	type invalidKey struct {
+		ID []int64 `spanner:"Id,key"`
+	}
+	for i, test := range []struct {
+		in       interface{}
+		want     Key
+		wantCode codes.Code
+	}{
+		{album{Title: "Go", SingerID: 1, AlbumID: 2}, Key{int64(1), int64(2)}, codes.OK},
+		{&album{Title: "Go", SingerID: 3, AlbumID: 4}, Key{int64(3), int64(4)}, codes.OK},
+		{(*album)(nil), nil, codes.InvalidArgument},
+		{noKey{ID: 1}, nil, codes.InvalidArgument},
+		{invalidKey{ID: []int64{1}}, nil, codes.InvalidArgument},
+		{int64(1), nil, codes.InvalidArgument},
+		{nil, nil, codes.InvalidArgument},
+	} {
+		got, err := KeyFromStruct(test.in)
+		if g, w := ErrCode(err), test.wantCode; g != w {
+			t.Errorf("#%d: error code mismatch\nGot: %v\nWant: %v", i, g, w)
+			continue
+		}
+		if !testEqual(got, test.want) {
+			t.Errorf("#%d: key mismatch\nGot: %v\nWant: %v", i, got, test.want)
+		}
+	}
+}
+
+// Test that the key option in a spanner tag does not change the column name.
+func TestKeyTagOptionColumnName(t *testing.T) {
+	type singer struct {
+		SingerID  int64  `spanner:"SingerId,key"`
+		FirstName string `spanner:"FirstName"`
+	}
+	cols, _, err := structToMutationParams(singer{SingerID: 1, FirstName: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := cols, []string{"SingerId", "FirstName"}; !testEqual(g, w) {
+		t.Fatalf("column mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
@@ -18,6 +18,8 @@ package spanner
 
 import (
 	"reflect"
+	"strings"
+	"time"
 
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
@@ -430,3 +432,72 @@ func mutationsProto(ms []*Mutation) ([]*sppb.Mutation, error) {
 	}
 	return l, nil
 }
+
+// CommitTimestampColumns is a set of columns that have the
+// allow_commit_timestamp=true option, keyed by table name. Cloud Spanner only
+// reports an error for an invalid value in such a column when the mutation is
+// committed. CommitTimestampColumns can be used to detect such errors before
+// the mutations are sent to Cloud Spanner, as the client library does not
+// know the schema of the database.
+//
+// Table and column names are matched case-insensitively.
+type CommitTimestampColumns map[string][]string
+
+// errInvalidCommitTimestamp returns error for writing a value to a commit
+// timestamp column that Cloud Spanner does not accept.
+func errInvalidCommitTimestamp(table, column string, v interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "column %s.%s only accepts spanner.CommitTimestamp, NULL or a timestamp that is not in the future, got %v", table, column, v)
+}
+
+// validCommitTimestampValue returns true if v can be written to a commit
+// timestamp column, i.e. if v is the CommitTimestamp placeholder, a NULL
+// value or a timestamp that is not after now.
+func validCommitTimestampValue(v interface{}, now time.Time) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case time.Time:
+		return v == commitTimestamp || !v.After(now)
+	case *time.Time:
+		return v == nil || validCommitTimestampValue(*v, now)
+	case NullTime:
+		return !v.Valid || validCommitTimestampValue(v.Time, now)
+	case *NullTime:
+		return v == nil || validCommitTimestampValue(*v, now)
+	}
+	return false
+}
+
+// Validate returns an error if any of the given mutations writes a value to
+// one of the commit timestamp columns that Cloud Spanner does not accept. A
+// commit timestamp column accepts CommitTimestamp, NULL and timestamps that
+// are not in the future. Timestamps are compared with the clock of the
+// client, which can differ slightly from the clock of Cloud Spanner.
+func (c CommitTimestampColumns) Validate(ms []*Mutation) error {
+	now := time.Now()
+	for _, m := range ms {
+		if m == nil || m.op == opDelete {
+			continue
+		}
+		var columns []string
+		for table, cols := range c {
+			if strings.EqualFold(table, m.table) {
+				columns = append(columns, cols...)
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		for i, col := range m.columns {
+			if i >= len(m.values) {
+				break
+			}
+			for _, tsCol := range columns {
+				if strings.EqualFold(col, tsCol) && !validCommitTimestampValue(m.values[i], now) {
+					return errInvalidCommitTimestamp(m.table, col, m.values[i])
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -20,9 +20,11 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 // keysetProto returns protobuf encoding of valid spanner.KeySet.
@@ -568,3 +570,89 @@ func TestEncodeMutationArray(t *testing.T) {
 		}
 	}
 }
+
+func TestCommitTimestampColumnsValidate(t *testing.T) {
+	type account struct {
+		AccountId   int64
+		LastUpdated time.Time
+	}
+	columns := CommitTimestampColumns{"Accounts": {"LastUpdated"}}
+	for _, test := range []struct {
+		desc    string
+		ms      []*Mutation
+		wantErr bool
+	}{
+		{
+			desc: "commit timestamp",
+			ms:   []*Mutation{Insert("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), CommitTimestamp})},
+		},
+		{
+			desc: "valid NullTime with commit timestamp",
+			ms:   []*Mutation{Update("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), NullTime{CommitTimestamp, true}})},
+		},
+		{
+			desc: "case-insensitive names",
+			ms:   []*Mutation{InsertOrUpdate("accounts", []string{"AccountId", "lastupdated"}, []interface{}{int64(1), CommitTimestamp})},
+		},
+		{
+			desc: "other column",
+			ms:   []*Mutation{Insert("Accounts", []string{"AccountId", "Created"}, []interface{}{int64(1), time.Now()})},
+		},
+		{
+			desc: "other table",
+			ms:   []*Mutation{Insert("Singers", []string{"SingerId", "LastUpdated"}, []interface{}{int64(1), time.Now()})},
+		},
+		{
+			desc: "delete",
+			ms:   []*Mutation{Delete("Accounts", Key{1})},
+		},
+		{
+			desc: "past time value",
+			ms:   []*Mutation{Insert("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), time.Now().Add(-time.Hour)})},
+		},
+		{
+			desc: "struct value",
+			ms:   []*Mutation{insertStructMustSucceed(t, "Accounts", account{1, time.Now().Add(-time.Hour)})},
+		},
+		{
+			desc: "null value",
+			ms:   []*Mutation{Replace("ACCOUNTS", []string{"AccountId", "LASTUPDATED"}, []interface{}{int64(1), nil})},
+		},
+		{
+			desc: "null NullTime",
+			ms:   []*Mutation{Update("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), NullTime{}})},
+		},
+		{
+			desc:    "future time value",
+			ms:      []*Mutation{Insert("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), time.Now().Add(time.Hour)})},
+			wantErr: true,
+		},
+		{
+			desc:    "future struct value",
+			ms:      []*Mutation{insertStructMustSucceed(t, "Accounts", account{1, time.Now().Add(time.Hour)})},
+			wantErr: true,
+		},
+		{
+			desc:    "string value",
+			ms:      []*Mutation{Insert("Accounts", []string{"AccountId", "LastUpdated"}, []interface{}{int64(1), "2020-01-01T00:00:00Z"})},
+			wantErr: true,
+		},
+	} {
+		err := columns.Validate(test.ms)
+		if test.wantErr {
+			if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+				t.Errorf("%s: error code mismatch\nGot: %v\nWant: %v", test.desc, g, w)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+		}
+	}
+}
+
+func insertStructMustSucceed(t *testing.T, table string, in interface{}) *Mutation {
+	m, err := InsertStruct(table, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
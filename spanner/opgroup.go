@@ -0,0 +1,81 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+)
+
+// operationContext is a context that has been registered with an operation
+// group of a Client.
+type operationContext struct {
+	cancel context.CancelFunc
+}
+
+// WithOperationGroup returns a copy of ctx that is added to the operation
+// group with the given key. All operations that use the returned context, or
+// a context that is derived from it, are cancelled when
+// Client.CancelOperationGroup is called for the group. This can for example
+// be used to cancel all queries and transactions that were started for a
+// user request that has been cancelled, without closing the client.
+//
+// Canceling the returned context releases the resources associated with it and
+// removes it from the group, so code should call cancel as soon as the
+// operations running in this context complete.
+func (c *Client) WithOperationGroup(ctx context.Context, group string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	oc := &operationContext{cancel: cancel}
+	c.mu.Lock()
+	if c.opGroups == nil {
+		c.opGroups = make(map[string]map[*operationContext]struct{})
+	}
+	if c.opGroups[group] == nil {
+		c.opGroups[group] = make(map[*operationContext]struct{})
+	}
+	c.opGroups[group][oc] = struct{}{}
+	c.mu.Unlock()
+	return ctx, func() {
+		c.removeFromOperationGroup(group, oc)
+		cancel()
+	}
+}
+
+// CancelOperationGroup cancels all operations that are running with a context
+// that was returned by WithOperationGroup for the given group, and returns the
+// number of contexts that were cancelled. Operations that are started in the
+// group after CancelOperationGroup has returned are not affected.
+func (c *Client) CancelOperationGroup(group string) int {
+	c.mu.Lock()
+	ocs := c.opGroups[group]
+	delete(c.opGroups, group)
+	c.mu.Unlock()
+	for oc := range ocs {
+		oc.cancel()
+	}
+	return len(ocs)
+}
+
+// removeFromOperationGroup removes the given context from an operation group.
+func (c *Client) removeFromOperationGroup(group string, oc *operationContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ocs := c.opGroups[group]
+	delete(ocs, oc)
+	if len(ocs) == 0 {
+		delete(c.opGroups, group)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClient_CancelOperationGroup(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	server.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		MinimumExecutionTime: time.Second,
+	})
+
+	query := func(ctx context.Context) error {
+		return client.Single().Query(ctx, NewStatement(SelectFooFromBar)).Do(func(*Row) error { return nil })
+	}
+	groupCtx, cancel := client.WithOperationGroup(context.Background(), "request-1")
+	defer cancel()
+	otherCtx, cancelOther := client.WithOperationGroup(context.Background(), "request-2")
+	defer cancelOther()
+
+	const numQueries = 3
+	errs := make(chan error, numQueries)
+	for i := 0; i < numQueries; i++ {
+		go func() { errs <- query(groupCtx) }()
+	}
+	otherErr := make(chan error, 1)
+	go func() { otherErr <- query(otherCtx) }()
+
+	// Wait until all queries have been sent to the server.
+	<-time.After(100 * time.Millisecond)
+	start := time.Now()
+	if g, w := client.CancelOperationGroup("request-1"), 1; g != w {
+		t.Fatalf("number of cancelled contexts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	for i := 0; i < numQueries; i++ {
+		if err := <-errs; ErrCode(err) != codes.Canceled {
+			t.Fatalf("error code mismatch\nGot: %v\nWant: %v", ErrCode(err), codes.Canceled)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("queries were not cancelled in time, took %v", elapsed)
+	}
+	// Queries in other groups are not affected.
+	if err := <-otherErr; err != nil {
+		t.Fatal(err)
+	}
+	if g, w := client.CancelOperationGroup("request-1"), 0; g != w {
+		t.Fatalf("number of cancelled contexts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_WithOperationGroup_Cancel(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	ctx, cancel := client.WithOperationGroup(context.Background(), "request")
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("context should be cancelled, got %v", ctx.Err())
+	}
+	// A cancelled context is removed from the group.
+	client.mu.Lock()
+	n := len(client.opGroups)
+	client.mu.Unlock()
+	if g, w := n, 0; g != w {
+		t.Fatalf("number of operation groups mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
@@ -32,11 +32,14 @@ import (
 // PartitionedUpdate returns an estimated count of the number of rows affected.
 // The actual number of affected rows may be greater than the estimate.
 func (c *Client) PartitionedUpdate(ctx context.Context, statement Statement) (count int64, err error) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.PartitionedUpdate")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.PartitionedUpdate")
 	defer func() { trace.EndSpan(ctx, err) }()
 	if err := checkNestedTxn(ctx); err != nil {
 		return 0, err
 	}
+	if err := checkContextDone(ctx); err != nil {
+		return 0, err
+	}
 	var (
 		s  *session
 		sh *sessionHandle
@@ -64,10 +67,13 @@ func (c *Client) PartitionedUpdate(ctx context.Context, statement Statement) (co
 		ParamTypes: paramTypes,
 	}
 
-	// Make a retryer for Aborted errors.
+	// Make a retryer for Aborted and Unavailable errors. The statement of a
+	// PDML transaction is idempotent, so it is safe to execute it again in a
+	// new transaction after an Unavailable error.
 	// TODO: use generic Aborted retryer when merged with master
-	retryer := gax.OnCodes([]codes.Code{codes.Aborted}, DefaultRetryBackoff)
-	// Execute the PDML and retry if the transaction is aborted.
+	retryer := gax.OnCodes([]codes.Code{codes.Aborted, codes.Unavailable}, DefaultRetryBackoff)
+	// Execute the PDML and retry if the transaction is aborted or the
+	// statement failed with Unavailable.
 	executePdmlWithRetry := func(ctx context.Context) (int64, error) {
 		for {
 			count, err := executePdml(ctx, sh, req)
@@ -103,6 +103,48 @@ func TestPartitionedUpdate_Aborted(t *testing.T) {
 	}
 }
 
+// PDML should be executed in a PartitionedDml transaction, and should be
+// retried if the statement fails with Unavailable.
+func TestPartitionedUpdate_Unavailable(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	server.TestSpanner.PutExecutionTime(MethodExecuteSql,
+		SimulatedExecutionTime{
+			Errors: []error{status.Error(codes.Unavailable, "Temporary unavailable")},
+		})
+	rowCount, err := client.PartitionedUpdate(ctx, NewStatement(UpdateBarSetFoo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rowCount, int64(UpdateBarSetFooRowCount); g != w {
+		t.Errorf("Row count mismatch\nGot: %d\nWant: %d", g, w)
+	}
+	var begins, executes int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		switch req := req.(type) {
+		case *sppb.BeginTransactionRequest:
+			begins++
+			if req.Options.GetPartitionedDml() == nil {
+				t.Fatalf("transaction mode mismatch\nGot: %v\nWant: PartitionedDml", req.Options)
+			}
+		case *sppb.ExecuteSqlRequest:
+			executes++
+			if req.Transaction.GetId() == nil {
+				t.Fatalf("missing transaction id in selector: %v", req.Transaction)
+			}
+		}
+	}
+	if begins == 0 {
+		t.Fatal("missing BeginTransaction request")
+	}
+	if g, w := executes, 2; g != w {
+		t.Fatalf("ExecuteSql request count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
 // Test that a deadline is respected by PDML, and that the session that was
 // created is also deleted, even though the update timed out.
 func TestPartitionedUpdate_WithDeadline(t *testing.T) {
@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package spanner
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+)
+
+// ReadMetadata contains metadata about the result of ReadOnlyQuery.
+type ReadMetadata struct {
+	// ReadTimestamp is the timestamp at which the data was read.
+	ReadTimestamp time.Time
+	// RowCount is the number of rows that were returned by the query.
+	RowCount int64
+}
+
+// ReadOnlyQuery executes a query in a single-use read-only transaction with a
+// strong timestamp bound and decodes each row of the result into a value of
+// type T using Row.ToStruct. T must be a struct type. ReadOnlyQuery is only
+// available in Go 1.18 and later builds.
+//
+//	type Album struct {
+//		SingerID int64 `spanner:"SingerId"`
+//		Title    string
+//	}
+//	albums, md, err := spanner.ReadOnlyQuery[Album](ctx, client, stmt)
+func ReadOnlyQuery[T any](ctx context.Context, client *Client, statement Statement) (res []T, md ReadMetadata, err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.ReadOnlyQuery")
+	defer func() { trace.EndSpan(ctx, err) }()
+	iter := client.Single().Query(ctx, statement)
+	defer iter.Stop()
+	if err := iter.Do(func(r *Row) error {
+		var v T
+		if err := r.ToStruct(&v); err != nil {
+			return err
+		}
+		res = append(res, v)
+		return nil
+	}); err != nil {
+		return nil, ReadMetadata{}, err
+	}
+	return res, ReadMetadata{ReadTimestamp: iter.ReadTimestamp, RowCount: int64(len(res))}, nil
+}
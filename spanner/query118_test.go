@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package spanner
+
+import (
+	"context"
+	"testing"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestReadOnlyQuery(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	type album struct {
+		SingerID   int64 `spanner:"SingerId"`
+		AlbumID    int64 `spanner:"AlbumId"`
+		AlbumTitle string
+	}
+	got, md, err := ReadOnlyQuery[album](context.Background(), client, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []album{
+		{1, 0, "Album title 0"},
+		{2, 11, "Album title 1"},
+		{3, 22, "Album title 2"},
+	}
+	if !testEqual(got, want) {
+		t.Fatalf("rows mismatch\nGot: %v\nWant: %v", got, want)
+	}
+	if g, w := md.RowCount, SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if md.ReadTimestamp.IsZero() {
+		t.Fatal("missing read timestamp")
+	}
+}
+
+func TestReadOnlyQuery_DecodeError(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	type album struct {
+		SingerID   string `spanner:"SingerId"`
+		AlbumID    int64  `spanner:"AlbumId"`
+		AlbumTitle string
+	}
+	_, _, err := ReadOnlyQuery[album](context.Background(), client, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
@@ -19,8 +19,12 @@ package spanner
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -34,6 +38,20 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+// ByteBudgetExceededError is returned by RowIterator.Next if returning the
+// next row would exceed QueryOptions.MaxBytes.
+type ByteBudgetExceededError struct {
+	// MaxBytes is the byte budget of the query.
+	MaxBytes int64
+	// Bytes is the approximate number of bytes of the rows that were returned
+	// before the error, including the row that exceeded the budget.
+	Bytes int64
+}
+
+func (e *ByteBudgetExceededError) Error() string {
+	return fmt.Sprintf("spanner: query result exceeds the byte budget of %d bytes, got at least %d bytes", e.MaxBytes, e.Bytes)
+}
+
 // streamingReceiver is the interface for receiving data from a client side
 // stream.
 type streamingReceiver interface {
@@ -49,14 +67,28 @@ func errEarlyReadEnd() error {
 // stream is the internal fault tolerant method for streaming data from Cloud
 // Spanner.
 func stream(ctx context.Context, logger *log.Logger, rpc func(ct context.Context, resumeToken []byte) (streamingReceiver, error), setTimestamp func(time.Time), release func(error)) *RowIterator {
+	return streamWithOptions(ctx, logger, rpc, setTimestamp, release, QueryOptions{})
+}
+
+// streamWithOptions is like stream, but applies the given QueryOptions to the
+// stream.
+func streamWithOptions(ctx context.Context, logger *log.Logger, rpc func(ct context.Context, resumeToken []byte) (streamingReceiver, error), setTimestamp func(time.Time), release func(error), opts QueryOptions) *RowIterator {
 	ctx, cancel := context.WithCancel(ctx)
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.RowIterator")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.RowIterator")
+	streamd := newResumableStreamDecoder(ctx, logger, rpc)
+	streamd.attemptTimeout = opts.AttemptTimeout
+	streamd.retryDeadlineExceeded = opts.RetryDeadlineExceeded
+	streamd.retryResourceExhausted = opts.RetryResourceExhausted
 	return &RowIterator{
-		streamd:      newResumableStreamDecoder(ctx, logger, rpc),
-		rowd:         &partialResultSetDecoder{},
-		setTimestamp: setTimestamp,
-		release:      release,
-		cancel:       cancel,
+		streamd:       streamd,
+		rowd:          &partialResultSetDecoder{duplicateColumns: opts.DuplicateColumnNames},
+		setTimestamp:  setTimestamp,
+		release:       release,
+		cancel:        cancel,
+		prefetchDepth: opts.PrefetchDepth,
+		coercions:     opts.TypeCoercions,
+		maxBytes:      opts.MaxBytes,
+		onDecodeError: opts.OnDecodeError,
 	}
 }
 
@@ -75,6 +107,14 @@ type RowIterator struct {
 	// iterator.Done.
 	RowCount int64
 
+	// The timestamp at which the data was read. Available after the first
+	// call to RowIterator.Next for reads and queries in single-use read-only
+	// transactions, for example transactions that were created with
+	// Client.Single(). For other transactions, the read timestamp is not
+	// returned by the read or query and ReadTimestamp is the zero value; use
+	// ReadOnlyTransaction.Timestamp instead.
+	ReadTimestamp time.Time
+
 	streamd      *resumableStreamDecoder
 	rowd         *partialResultSetDecoder
 	setTimestamp func(time.Time)
@@ -83,6 +123,70 @@ type RowIterator struct {
 	err          error
 	rows         []*Row
 	sawStats     bool
+
+	// prefetchDepth is the number of PartialResultSets to fetch in the
+	// background. prefetch is started by the first call to Next if
+	// prefetchDepth > 0.
+	prefetchDepth int
+	prefetch      *prefetcher
+
+	// coercions are applied to the columns of each row before it is
+	// returned. See QueryOptions.TypeCoercions.
+	coercions map[sppb.TypeCode]TypeCoercion
+
+	// maxBytes is the maximum approximate number of bytes of the returned
+	// rows, or 0 if there is no limit. bytes is the number of bytes of the
+	// rows that have been returned so far.
+	maxBytes int64
+	bytes    int64
+
+	// onDecodeError decides whether a row that cannot be decoded is skipped.
+	// See QueryOptions.OnDecodeError.
+	onDecodeError func(*Row, error) DecodeErrorAction
+}
+
+// errCoerceColumn returns error for a TypeCoercion that failed for a column.
+func errCoerceColumn(name string, err error) error {
+	var se *Error
+	if !errorAs(err, &se) {
+		return spannerErrorf(codes.InvalidArgument, "failed to coerce column %q, error = <%v>", name, err)
+	}
+	se.decorate(fmt.Sprintf("failed to coerce column %q", name))
+	return se
+}
+
+// coerceRow returns a copy of row with the given coercions applied to its
+// columns. It returns row itself if none of the columns has a coercion.
+func coerceRow(row *Row, coercions map[sppb.TypeCode]TypeCoercion) (*Row, error) {
+	var res *Row
+	for i, f := range row.fields {
+		c, ok := coercions[f.Type.GetCode()]
+		if !ok {
+			continue
+		}
+		v, err := c(GenericColumnValue{Type: f.Type, Value: row.vals[i]})
+		if err != nil {
+			return nil, errCoerceColumn(f.Name, err)
+		}
+		pb, t, err := encodeValue(v)
+		if err != nil {
+			return nil, errCoerceColumn(f.Name, err)
+		}
+		if res == nil {
+			res = &Row{
+				fields: append([]*sppb.StructType_Field(nil), row.fields...),
+				vals:   append([]*proto3.Value(nil), row.vals...),
+			}
+		}
+		if t != nil {
+			res.fields[i] = &sppb.StructType_Field{Name: f.Name, Type: t}
+		}
+		res.vals[i] = pb
+	}
+	if res == nil {
+		return row, nil
+	}
+	return res, nil
 }
 
 // Next returns the next result. Its second return value is iterator.Done if
@@ -92,35 +196,62 @@ func (r *RowIterator) Next() (*Row, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
-	for len(r.rows) == 0 && r.streamd.next() {
-		prs := r.streamd.get()
-		if prs.Stats != nil {
-			r.sawStats = true
-			r.QueryPlan = prs.Stats.QueryPlan
-			r.QueryStats = protostruct.DecodeToMap(prs.Stats.QueryStats)
-			if prs.Stats.RowCount != nil {
-				rc, err := extractRowCount(prs.Stats)
-				if err != nil {
-					return nil, err
+	if r.prefetchDepth > 0 && r.prefetch == nil {
+		r.prefetch = newPrefetcher(r.streamd, r.prefetchDepth)
+	}
+	for {
+		for len(r.rows) == 0 && r.nextPartialResultSet() {
+			prs := r.partialResultSet()
+			if prs.Stats != nil {
+				r.sawStats = true
+				r.QueryPlan = prs.Stats.QueryPlan
+				r.QueryStats = protostruct.DecodeToMap(prs.Stats.QueryStats)
+				if prs.Stats.RowCount != nil {
+					rc, err := extractRowCount(prs.Stats)
+					if err != nil {
+						return nil, err
+					}
+					r.RowCount = rc
+				}
+			}
+			r.rows, r.err = r.rowd.add(prs)
+			if r.err != nil {
+				return nil, r.err
+			}
+			if !r.rowd.ts.IsZero() && r.ReadTimestamp.IsZero() {
+				r.ReadTimestamp = r.rowd.ts
+				if r.setTimestamp != nil {
+					r.setTimestamp(r.rowd.ts)
+					r.setTimestamp = nil
 				}
-				r.RowCount = rc
 			}
 		}
-		r.rows, r.err = r.rowd.add(prs)
-		if r.err != nil {
-			return nil, r.err
-		}
-		if !r.rowd.ts.IsZero() && r.setTimestamp != nil {
-			r.setTimestamp(r.rowd.ts)
-			r.setTimestamp = nil
+		if len(r.rows) == 0 {
+			break
 		}
-	}
-	if len(r.rows) > 0 {
 		row := r.rows[0]
 		r.rows = r.rows[1:]
-		return row, nil
+		if r.maxBytes > 0 {
+			r.bytes += rowSize(row)
+			if r.bytes > r.maxBytes {
+				r.err = &ByteBudgetExceededError{MaxBytes: r.maxBytes, Bytes: r.bytes}
+				r.rows = nil
+				// Stop receiving results from the stream.
+				r.cancel()
+				return nil, r.err
+			}
+		}
+		decoded, err := r.decodeRow(row)
+		if err != nil {
+			if r.onDecodeError != nil && r.onDecodeError(row, err) == DecodeErrorSkipRow {
+				continue
+			}
+			r.err = err
+			return nil, r.err
+		}
+		return decoded, nil
 	}
-	if err := r.streamd.lastErr(); err != nil {
+	if err := r.streamErr(); err != nil {
 		r.err = toSpannerError(err)
 	} else if !r.rowd.done() {
 		r.err = errEarlyReadEnd()
@@ -130,6 +261,80 @@ func (r *RowIterator) Next() (*Row, error) {
 	return nil, r.err
 }
 
+// decodeRow prepares row to be returned by Next. If an OnDecodeError policy
+// is set, it verifies that all values of the row can be decoded into the Go
+// values of their types. The type coercions of the iterator are applied to
+// the row.
+func (r *RowIterator) decodeRow(row *Row) (*Row, error) {
+	if r.onDecodeError != nil {
+		for i, f := range row.fields {
+			if _, err := decodeNativeValue(row.vals[i], f.Type); err != nil {
+				return nil, errDecodeColumn(i, err)
+			}
+		}
+	}
+	if len(r.coercions) > 0 {
+		return coerceRow(row, r.coercions)
+	}
+	return row, nil
+}
+
+// rowSize returns the approximate size in bytes of row.
+func rowSize(row *Row) int64 {
+	var n int
+	for _, v := range row.vals {
+		n += proto.Size(v)
+	}
+	return int64(n)
+}
+
+// ServerElapsedTime returns the elapsed time of the query on the server as
+// reported in the "elapsed_time" entry of QueryStats. The difference between
+// the latency that is observed by the client and the server elapsed time is
+// the overhead of the client and the network.
+//
+// The elapsed time is available after RowIterator.Next returns iterator.Done
+// if QueryWithStats was called. The second return value is false if the
+// elapsed time is not available or could not be parsed.
+func (r *RowIterator) ServerElapsedTime() (time.Duration, bool) {
+	v, ok := r.QueryStats["elapsed_time"].(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := parseElapsedTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+// elapsedTimeUnits contains the units that are used by Cloud Spanner for the
+// elapsed time in the query statistics.
+var elapsedTimeUnits = map[string]time.Duration{
+	"usecs": time.Microsecond,
+	"msecs": time.Millisecond,
+	"secs":  time.Second,
+	"mins":  time.Minute,
+}
+
+// parseElapsedTime parses an elapsed time in the format that is used by the
+// query statistics of Cloud Spanner, for example "1.23 msecs".
+func parseElapsedTime(s string) (time.Duration, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0, spannerErrorf(codes.InvalidArgument, "invalid elapsed time: %q", s)
+	}
+	unit, ok := elapsedTimeUnits[parts[1]]
+	if !ok {
+		return 0, spannerErrorf(codes.InvalidArgument, "unknown unit in elapsed time: %q", s)
+	}
+	v, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || v < 0 {
+		return 0, spannerErrorf(codes.InvalidArgument, "invalid elapsed time: %q", s)
+	}
+	return time.Duration(v * float64(unit)), nil
+}
+
 func extractRowCount(stats *sppb.ResultSetStats) (int64, error) {
 	if stats.RowCount == nil {
 		return 0, spannerErrorf(codes.Internal, "missing RowCount")
@@ -178,6 +383,11 @@ func (r *RowIterator) Stop() {
 	if r.cancel != nil {
 		r.cancel()
 	}
+	if r.prefetch != nil {
+		// The session must not be released while the prefetch goroutine can
+		// still read from its stream.
+		r.prefetch.wait()
+	}
 	if r.release != nil {
 		r.release(r.err)
 		if r.err == nil {
@@ -188,6 +398,92 @@ func (r *RowIterator) Stop() {
 	}
 }
 
+// Queryer is implemented by the transaction types that can execute queries,
+// i.e. *ReadOnlyTransaction, *ReadWriteTransaction and
+// *BatchReadOnlyTransaction.
+type Queryer interface {
+	Query(ctx context.Context, statement Statement) *RowIterator
+}
+
+// QueryToMaps executes a query in the given transaction and returns all rows
+// of the result as maps from column name to native Go value. See Row.ToMap
+// for how column values are decoded.
+func QueryToMaps(ctx context.Context, tx Queryer, statement Statement) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := tx.Query(ctx, statement).Do(func(r *Row) error {
+		m, err := r.ToMap()
+		if err != nil {
+			return err
+		}
+		rows = append(rows, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// DefaultMaxArrayParamLength is the default maximum number of elements of the
+// array parameter of a single query that is executed by QueryWithSplitArray.
+const DefaultMaxArrayParamLength = 10000
+
+// errNotArrayParam returns error for a query parameter that cannot be split
+// by QueryWithSplitArray because it is not a slice.
+func errNotArrayParam(param string, v interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "parameter %q must be a slice, got %T", param, v)
+}
+
+// QueryWithSplitArray executes a query with a large array parameter, such as
+// the array of a `WHERE Id IN UNNEST(@ids)` condition, that could otherwise
+// exceed the size limits of the parameters of a query. The array parameter
+// with the given name is split into arrays of at most maxLength elements, and
+// the query is executed once for each of these arrays. f is called for each
+// row of each query, which yields the union of the results of the queries.
+// maxLength defaults to DefaultMaxArrayParamLength if it is zero or negative.
+// If the array has no more than maxLength elements, the query is executed
+// once with the statement as is.
+//
+// The queries are executed sequentially in the given transaction. A
+// single-use transaction can only execute one query, so use a transaction
+// that is returned by Client.ReadOnlyTransaction to read the results of all
+// queries at the same timestamp.
+//
+// The results are only equal to the result of executing the query with the
+// whole array if each row of the result is selected by at most one element
+// of the array, and the query does not aggregate, order or limit the rows
+// that are selected by the array. Duplicate elements of the array that end up
+// in different arrays can yield the same row more than once.
+//
+// If f returns an error, QueryWithSplitArray stops and returns that error.
+func QueryWithSplitArray(ctx context.Context, tx Queryer, statement Statement, param string, maxLength int, f func(r *Row) error) error {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxArrayParamLength
+	}
+	arr := reflect.ValueOf(statement.Params[param])
+	if arr.Kind() != reflect.Slice {
+		return errNotArrayParam(param, statement.Params[param])
+	}
+	if arr.Len() <= maxLength {
+		return tx.Query(ctx, statement).Do(f)
+	}
+	for start := 0; start < arr.Len(); start += maxLength {
+		end := start + maxLength
+		if end > arr.Len() {
+			end = arr.Len()
+		}
+		chunk := Statement{SQL: statement.SQL, Params: make(map[string]interface{}, len(statement.Params))}
+		for k, v := range statement.Params {
+			chunk.Params[k] = v
+		}
+		chunk.Params[param] = arr.Slice(start, end).Interface()
+		if err := tx.Query(ctx, chunk).Do(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // partialResultQueue implements a simple FIFO queue.  The zero value is a valid
 // queue.
 type partialResultQueue struct {
@@ -328,6 +624,31 @@ type resumableStreamDecoder struct {
 
 	// backoff is used for the retry settings
 	backoff gax.Backoff
+
+	// attemptTimeout is the maximum duration of a single attempt of the
+	// stream. Zero means no timeout per attempt.
+	attemptTimeout time.Duration
+
+	// attemptCancel cancels the context of the current attempt, if the
+	// attempt has its own timeout.
+	attemptCancel context.CancelFunc
+
+	// retryDeadlineExceeded indicates that attempts that fail with
+	// DeadlineExceeded should be retried while ctx has not expired.
+	retryDeadlineExceeded bool
+
+	// retryResourceExhausted indicates that attempts that fail with
+	// ResourceExhausted should be retried while ctx has not expired.
+	retryResourceExhausted bool
+
+	// isRetryable decides whether attempts that fail with an error that is
+	// not retried by default should be retried. It may be nil. See
+	// ClientConfig.IsRetryableStreamError.
+	isRetryable func(error) bool
+
+	// retryGate coordinates the server retry delays of the decoder with the
+	// other operations of the same client. It may be nil.
+	retryGate *retryGate
 }
 
 // newResumableStreamDecoder creates a new resumeableStreamDecoder instance.
@@ -422,12 +743,22 @@ var (
 )
 
 func (d *resumableStreamDecoder) next() bool {
-	retryer := gax.OnCodes([]codes.Code{codes.Unavailable, codes.Internal}, d.backoff)
+	retryCodes := []codes.Code{codes.Unavailable, codes.Internal}
+	if d.retryDeadlineExceeded {
+		retryCodes = append(retryCodes, codes.DeadlineExceeded)
+	}
+	if d.retryResourceExhausted {
+		retryCodes = append(retryCodes, codes.ResourceExhausted)
+	}
+	// The retryer uses the retry delay that is returned by Cloud Spanner if
+	// there is one, and the backoff of the stream otherwise. Errors that are
+	// accepted by isRetryable are retried in addition to retryCodes.
+	retryer := orRetryable(onCodesWithGate(d.backoff, d.retryGate, retryCodes...), d.backoff, d.isRetryable)
 	for {
 		switch d.state {
 		case unConnected:
 			// If no gRPC stream is available, try to initiate one.
-			d.stream, d.err = d.rpc(d.ctx, d.resumeToken)
+			d.stream, d.err = d.rpc(d.newAttemptContext(), d.resumeToken)
 			if d.err == nil {
 				d.changeState(queueingRetryable)
 				continue
@@ -494,6 +825,7 @@ func (d *resumableStreamDecoder) next() bool {
 			// Discard all pending items because none of them should be yield
 			// to caller.
 			d.q.clear()
+			d.cancelAttempt()
 			return false
 		case finished:
 			// If query has finished, check if there are still buffered messages.
@@ -527,6 +859,7 @@ func (d *resumableStreamDecoder) tryRecv(retryer gax.Retryer) {
 	}
 	if d.err == io.EOF {
 		d.err = nil
+		d.cancelAttempt()
 		d.changeState(finished)
 		return
 	}
@@ -540,6 +873,15 @@ func (d *resumableStreamDecoder) tryRecv(retryer gax.Retryer) {
 		d.changeState(aborted)
 		return
 	}
+	// If the stream failed before any resume token was received, nothing has
+	// been yielded to the caller yet, and the stream is transparently
+	// restarted from scratch. Otherwise the stream is resumed from the last
+	// resume token.
+	if d.resumeToken == nil {
+		trace.TracePrintf(d.ctx, nil, "Restarting stream that failed before any resume token was received: %v", d.err)
+	} else {
+		trace.TracePrintf(d.ctx, nil, "Resuming stream from last resume token after: %v", d.err)
+	}
 	// Clear error and retry the stream.
 	d.err = nil
 	// Discard all queue items (none have resume tokens).
@@ -548,6 +890,26 @@ func (d *resumableStreamDecoder) tryRecv(retryer gax.Retryer) {
 	d.changeState(unConnected)
 }
 
+// newAttemptContext returns the context for a new attempt of the stream. The
+// context of the previous attempt, if any, is cancelled.
+func (d *resumableStreamDecoder) newAttemptContext() context.Context {
+	d.cancelAttempt()
+	if d.attemptTimeout <= 0 {
+		return d.ctx
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, d.attemptTimeout)
+	d.attemptCancel = cancel
+	return ctx
+}
+
+// cancelAttempt cancels the context of the current attempt of the stream.
+func (d *resumableStreamDecoder) cancelAttempt() {
+	if d.attemptCancel != nil {
+		d.attemptCancel()
+		d.attemptCancel = nil
+	}
+}
+
 // get returns the most recent PartialResultSet generated by a call to next.
 func (d *resumableStreamDecoder) get() *sppb.PartialResultSet {
 	return d.np
@@ -558,6 +920,93 @@ func (d *resumableStreamDecoder) lastErr() error {
 	return d.err
 }
 
+// nextPartialResultSet advances the iterator to the next PartialResultSet,
+// either from the prefetcher or directly from the stream.
+func (r *RowIterator) nextPartialResultSet() bool {
+	if r.prefetch != nil {
+		return r.prefetch.next()
+	}
+	return r.streamd.next()
+}
+
+// partialResultSet returns the current PartialResultSet.
+func (r *RowIterator) partialResultSet() *sppb.PartialResultSet {
+	if r.prefetch != nil {
+		return r.prefetch.get()
+	}
+	return r.streamd.get()
+}
+
+// streamErr returns the last non-EOF error of the stream.
+func (r *RowIterator) streamErr() error {
+	if r.prefetch != nil {
+		return r.prefetch.lastErr()
+	}
+	return r.streamd.lastErr()
+}
+
+// prefetcher reads PartialResultSets from a resumableStreamDecoder in a
+// background goroutine, and buffers up to a fixed number of them until they
+// are requested by the caller. The resumableStreamDecoder must not be used by
+// anyone else after it has been handed to a prefetcher.
+type prefetcher struct {
+	d  *resumableStreamDecoder
+	ch chan *sppb.PartialResultSet
+	np *sppb.PartialResultSet
+}
+
+// newPrefetcher creates a prefetcher for d that buffers up to depth
+// PartialResultSets, and starts fetching results in the background. The
+// background goroutine stops when the stream is finished or the context of d
+// is done.
+func newPrefetcher(d *resumableStreamDecoder, depth int) *prefetcher {
+	p := &prefetcher{
+		d:  d,
+		ch: make(chan *sppb.PartialResultSet, depth),
+	}
+	go func() {
+		defer close(p.ch)
+		for d.next() {
+			select {
+			case p.ch <- d.get():
+			case <-d.ctx.Done():
+				if d.err == nil {
+					d.err = d.ctx.Err()
+				}
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// next blocks until the next PartialResultSet is available, and returns false
+// if there are no more PartialResultSets.
+func (p *prefetcher) next() bool {
+	np, ok := <-p.ch
+	p.np = np
+	return ok
+}
+
+// get returns the current PartialResultSet.
+func (p *prefetcher) get() *sppb.PartialResultSet {
+	return p.np
+}
+
+// wait discards the PartialResultSets that have not been returned by next,
+// and blocks until the background goroutine has stopped. The context of the
+// decoder must be done, or the stream must be finished, for wait to return.
+func (p *prefetcher) wait() {
+	for range p.ch {
+	}
+}
+
+// lastErr returns the last non-EOF error of the stream. It may only be called
+// after next has returned false.
+func (p *prefetcher) lastErr() error {
+	return p.d.lastErr()
+}
+
 // partialResultSetDecoder assembles PartialResultSet(s) into Cloud Spanner
 // Rows.
 type partialResultSetDecoder struct {
@@ -566,6 +1015,62 @@ type partialResultSetDecoder struct {
 	chunked bool // if true, next value should be merged with last values
 	// entry.
 	ts time.Time // read timestamp
+	// duplicateColumns determines how duplicate column names in the
+	// metadata are handled.
+	duplicateColumns DuplicateColumnNames
+}
+
+// errDuplicateColumnName returns error for a result that contains more than
+// one column with the same name.
+func errDuplicateColumnName(n string) error {
+	return spannerErrorf(codes.InvalidArgument, "duplicate column name %q in result", n)
+}
+
+// resolveDuplicateColumnNames returns the fields of a result with duplicate
+// column names handled according to mode. The given fields are not modified.
+func resolveDuplicateColumnNames(fields []*sppb.StructType_Field, mode DuplicateColumnNames) ([]*sppb.StructType_Field, error) {
+	if mode == DuplicateColumnNamesKeep {
+		return fields, nil
+	}
+	used := make(map[string]bool, len(fields))
+	var dups bool
+	for _, f := range fields {
+		if f.GetName() == "" {
+			continue
+		}
+		if used[f.Name] {
+			if mode == DuplicateColumnNamesError {
+				return nil, errDuplicateColumnName(f.Name)
+			}
+			dups = true
+		}
+		used[f.Name] = true
+	}
+	if !dups {
+		return fields, nil
+	}
+	resolved := make([]*sppb.StructType_Field, len(fields))
+	seen := make(map[string]int, len(fields))
+	for i, f := range fields {
+		resolved[i] = f
+		if f.GetName() == "" {
+			continue
+		}
+		n := seen[f.Name]
+		seen[f.Name] = n + 1
+		if n == 0 {
+			continue
+		}
+		name := fmt.Sprintf("%s_%d", f.Name, n)
+		for used[name] {
+			n++
+			name = fmt.Sprintf("%s_%d", f.Name, n)
+		}
+		seen[f.Name] = n + 1
+		used[name] = true
+		resolved[i] = &sppb.StructType_Field{Name: name, Type: f.Type}
+	}
+	return resolved, nil
 }
 
 // yield checks we have a complete row, and if so returns it.  A row is not
@@ -607,7 +1112,11 @@ func (p *partialResultSetDecoder) add(r *sppb.PartialResultSet) ([]*Row, error)
 	if r.Metadata != nil {
 		// Metadata should only be returned in the first result.
 		if p.row.fields == nil {
-			p.row.fields = r.Metadata.RowType.Fields
+			fields, err := resolveDuplicateColumnNames(r.Metadata.RowType.GetFields(), p.duplicateColumns)
+			if err != nil {
+				return nil, err
+			}
+			p.row.fields = fields
 		}
 		if p.tx == nil && r.Metadata.Transaction != nil {
 			p.tx = r.Metadata.Transaction
@@ -0,0 +1,52 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+// +build go1.23
+
+package spanner
+
+import (
+	"iter"
+
+	"google.golang.org/api/iterator"
+)
+
+// All returns an iterator over the rows of r that can be used in a
+// range-over-func loop:
+//
+//	for row, err := range iter.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// If reading the rows fails, the error is yielded with a nil row and the
+// iteration ends. All calls Stop on r when the iteration ends, also if the
+// loop is exited early. All is only available in Go 1.23 and later builds.
+func (r *RowIterator) All() iter.Seq2[*Row, error] {
+	return func(yield func(*Row, error) bool) {
+		defer r.Stop()
+		for {
+			row, err := r.Next()
+			if err == iterator.Done {
+				return
+			}
+			if !yield(row, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+// +build go1.23
+
+package spanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRowIteratorAll(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	iter := client.Single().Query(context.Background(), NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	var rows int64
+	for row, err := range iter.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var singerID, albumID int64
+		var albumTitle string
+		if err := row.Columns(&singerID, &albumID, &albumTitle); err != nil {
+			t.Fatal(err)
+		}
+		rows++
+	}
+	if g, w := rows, SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRowIteratorAll_Break(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	iter := client.Single().Query(context.Background(), NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	var rows int
+	for _, err := range iter.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows++
+		break
+	}
+	if g, w := rows, 1; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The iterator should have been stopped when the loop ended.
+	if _, err := iter.Next(); ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("Next after break mismatch\nGot: %v\nWant: Next called after Stop", err)
+	}
+}
+
+func TestRowIteratorAll_Error(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	sql := "SELECT * FROM NonExistingTable"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultError,
+		Err:  status.Error(codes.InvalidArgument, "Table not found: NonExistingTable"),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	iter := client.Single().Query(ctx, NewStatement(sql))
+	var errs int
+	for row, err := range iter.All() {
+		if row != nil {
+			t.Fatalf("unexpected row: %v", row)
+		}
+		if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+			t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		errs++
+	}
+	if g, w := errs, 1; g != w {
+		t.Fatalf("error count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
@@ -1746,3 +1746,269 @@ func dialMock(t *testing.T, ms *MockCloudSpanner) *grpc.ClientConn {
 	}
 	return cc
 }
+
+// delayedReceiver is a streamingReceiver that returns a fixed number of
+// PartialResultSets with one INT64 row each, and waits for a fixed latency
+// before returning each PartialResultSet.
+type delayedReceiver struct {
+	ctx     context.Context
+	latency time.Duration
+	numRows int
+	next    int
+}
+
+// Recv implements streamingReceiver.Recv for delayedReceiver.
+func (r *delayedReceiver) Recv() (*sppb.PartialResultSet, error) {
+	if r.next == r.numRows {
+		return nil, io.EOF
+	}
+	time.Sleep(r.latency)
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+	prs := &sppb.PartialResultSet{
+		Values:      []*proto3.Value{intProto(int64(r.next))},
+		ResumeToken: EncodeResumeToken(uint64(r.next + 1)),
+	}
+	if r.next == 0 {
+		prs.Metadata = &sppb.ResultSetMetadata{
+			RowType: &sppb.StructType{
+				Fields: []*sppb.StructType_Field{{Name: "Id", Type: intType()}},
+			},
+		}
+	}
+	r.next++
+	return prs, nil
+}
+
+// streamDelayed returns a RowIterator over a delayedReceiver.
+func streamDelayed(ctx context.Context, latency time.Duration, numRows int, opts QueryOptions) *RowIterator {
+	return streamWithOptions(ctx, nil,
+		func(ct context.Context, resumeToken []byte) (streamingReceiver, error) {
+			return &delayedReceiver{ctx: ct, latency: latency, numRows: numRows}, nil
+		},
+		nil,
+		func(error) {},
+		opts)
+}
+
+func TestRowIteratorPrefetch(t *testing.T) {
+	t.Parallel()
+	const numRows = 20
+	for _, depth := range []int{0, 1, 4, numRows * 2} {
+		iter := streamDelayed(context.Background(), 0, numRows, QueryOptions{PrefetchDepth: depth})
+		var want int64
+		err := iter.Do(func(r *Row) error {
+			var id int64
+			if err := r.Column(0, &id); err != nil {
+				return err
+			}
+			if id != want {
+				return fmt.Errorf("row order mismatch\nGot: %v\nWant: %v", id, want)
+			}
+			want++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("depth %d: %v", depth, err)
+		}
+		if want != numRows {
+			t.Fatalf("depth %d: number of rows mismatch\nGot: %v\nWant: %v", depth, want, numRows)
+		}
+	}
+}
+
+func TestRowIteratorPrefetch_StopEarly(t *testing.T) {
+	t.Parallel()
+	iter := streamDelayed(context.Background(), time.Millisecond, 100, QueryOptions{PrefetchDepth: 2})
+	if _, err := iter.Next(); err != nil {
+		t.Fatal(err)
+	}
+	// Stopping the iterator must stop the background goroutine, even if the
+	// prefetch buffer is full, before the session is released.
+	iter.Stop()
+	select {
+	case _, ok := <-iter.prefetch.ch:
+		if ok {
+			t.Fatal("prefetch buffer was not drained by Stop")
+		}
+	default:
+		t.Fatal("prefetch goroutine still running after Stop")
+	}
+}
+
+func TestRowIteratorMaxBytes(t *testing.T) {
+	t.Parallel()
+	const numRows = 20
+	var sizes []int64
+	if err := streamDelayed(context.Background(), 0, numRows, QueryOptions{}).Do(func(r *Row) error {
+		sizes = append(sizes, rowSize(r))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	const wantRows = 5
+	var budget int64
+	for _, size := range sizes[:wantRows] {
+		budget += size
+	}
+
+	var gotRows int
+	err := streamDelayed(context.Background(), 0, numRows, QueryOptions{MaxBytes: budget}).Do(func(r *Row) error {
+		gotRows++
+		return nil
+	})
+	var budgetErr *ByteBudgetExceededError
+	if !errorAs(err, &budgetErr) {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %T", err, budgetErr)
+	}
+	if g, w := gotRows, wantRows; g != w {
+		t.Fatalf("number of rows mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := budgetErr.Bytes, budget+sizes[wantRows]; g != w {
+		t.Fatalf("bytes mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func BenchmarkRowIteratorPrefetch(b *testing.B) {
+	const (
+		numRows = 50
+		latency = 100 * time.Microsecond
+		work    = 100 * time.Microsecond
+	)
+	for _, depth := range []int{0, 4} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				iter := streamDelayed(context.Background(), latency, numRows, QueryOptions{PrefetchDepth: depth})
+				err := iter.Do(func(r *Row) error {
+					// Simulate processing of the row.
+					time.Sleep(work)
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRowIteratorServerElapsedTime(t *testing.T) {
+	t.Parallel()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	const sql = "SELECT * FROM Singers"
+	server.TestSpanner.PutStatementResult(sql, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "SingerId", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				{Values: []*proto3.Value{intProto(1)}},
+			},
+			Stats: &sppb.ResultSetStats{
+				QueryStats: &proto3.Struct{
+					Fields: map[string]*proto3.Value{
+						"elapsed_time": stringProto("1.5 msecs"),
+					},
+				},
+			},
+		},
+	})
+	iter := client.Single().QueryWithStats(context.Background(), NewStatement(sql))
+	if _, ok := iter.ServerElapsedTime(); ok {
+		t.Fatal("elapsed time should not be available before iterating")
+	}
+	if err := iter.Do(func(*Row) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := iter.ServerElapsedTime()
+	if !ok {
+		t.Fatal("missing elapsed time")
+	}
+	if g, w := got, 1500*time.Microsecond; g != w {
+		t.Fatalf("elapsed time mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestParseElapsedTime(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"250 usecs", 250 * time.Microsecond, false},
+		{"1.5 msecs", 1500 * time.Microsecond, false},
+		{"2 secs", 2 * time.Second, false},
+		{"0.5 mins", 30 * time.Second, false},
+		{"", 0, true},
+		{"1.5", 0, true},
+		{"1.5 hours", 0, true},
+		{"abc msecs", 0, true},
+		{"-1 msecs", 0, true},
+	} {
+		got, err := parseElapsedTime(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error mismatch\nGot: %v\nWant error: %v", test.in, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: elapsed time mismatch\nGot: %v\nWant: %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestResolveDuplicateColumnNames(t *testing.T) {
+	for _, test := range []struct {
+		in   []string
+		mode DuplicateColumnNames
+		want []string
+		fail bool
+	}{
+		{[]string{"A", "B", "A"}, DuplicateColumnNamesKeep, []string{"A", "B", "A"}, false},
+		{[]string{"A", "B"}, DuplicateColumnNamesSuffix, []string{"A", "B"}, false},
+		{[]string{"A", "B", "A", "A"}, DuplicateColumnNamesSuffix, []string{"A", "B", "A_1", "A_2"}, false},
+		{[]string{"A", "A", "A_1"}, DuplicateColumnNamesSuffix, []string{"A", "A_2", "A_1"}, false},
+		{[]string{"", "", "A"}, DuplicateColumnNamesSuffix, []string{"", "", "A"}, false},
+		{[]string{"", "", "A"}, DuplicateColumnNamesError, []string{"", "", "A"}, false},
+		{[]string{"A", "B", "A"}, DuplicateColumnNamesError, nil, true},
+	} {
+		var fields []*sppb.StructType_Field
+		for _, n := range test.in {
+			fields = append(fields, mkField(n, intType()))
+		}
+		got, err := resolveDuplicateColumnNames(fields, test.mode)
+		if err != nil {
+			if !test.fail {
+				t.Errorf("%v, %v: unexpected error: %v", test.in, test.mode, err)
+			} else if g, w := ErrCode(err), codes.InvalidArgument; g != w {
+				t.Errorf("%v, %v: error code mismatch\nGot: %v\nWant: %v", test.in, test.mode, g, w)
+			}
+			continue
+		}
+		if test.fail {
+			t.Errorf("%v, %v: missing expected error", test.in, test.mode)
+			continue
+		}
+		var names []string
+		for _, f := range got {
+			names = append(names, f.Name)
+		}
+		if !testEqual(names, test.want) {
+			t.Errorf("%v, %v: column names mismatch\nGot: %v\nWant: %v", test.in, test.mode, names, test.want)
+		}
+		// The input must not be modified.
+		for i, f := range fields {
+			if f.Name != test.in[i] {
+				t.Errorf("%v, %v: input was modified: %v", test.in, test.mode, fields)
+				break
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ package spanner
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/internal/trace"
@@ -27,6 +28,7 @@ import (
 	edpb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -41,22 +43,85 @@ var DefaultRetryBackoff = gax.Backoff{
 	Multiplier: 1.3,
 }
 
+// retryGateSpacingFraction is the fraction of the server retry delay that
+// concurrent retries for the same class of error are spaced apart by a
+// retryGate.
+const retryGateSpacingFraction = 0.1
+
+// gatedRetryCodes are the error codes for which the server retry delays are
+// coordinated between concurrent operations. These are the errors that
+// Cloud Spanner returns when the client should back off to reduce the load.
+var gatedRetryCodes = map[codes.Code]bool{
+	codes.ResourceExhausted: true,
+}
+
+// retryGate coordinates the retries of concurrent operations that receive a
+// retry delay from Cloud Spanner for the same class of error. Without it, all
+// operations that were rejected at the same time would also retry at the same
+// time once the delay has passed, and would likely be rejected again.
+type retryGate struct {
+	mu sync.Mutex
+	// next contains the time of the last scheduled retry per error code.
+	next map[codes.Code]time.Time
+}
+
+// newRetryGate creates a new retryGate.
+func newRetryGate() *retryGate {
+	return &retryGate{next: make(map[codes.Code]time.Time)}
+}
+
+// delay returns the time that an operation that received the given server
+// delay for an error with the given code should wait before retrying. The
+// retry is scheduled no earlier than the server delay, and at least a fraction
+// of the server delay after the previously scheduled retry for the same code.
+// The stagger that is added to the server delay is at most one server delay,
+// so an operation never waits more than twice the delay that it received.
+func (g *retryGate) delay(code codes.Code, serverDelay time.Duration, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	at := now.Add(serverDelay)
+	if next, ok := g.next[code]; ok {
+		earliest := next.Add(time.Duration(float64(serverDelay) * retryGateSpacingFraction))
+		if latest := at.Add(serverDelay); earliest.After(latest) {
+			earliest = latest
+		}
+		if earliest.After(at) {
+			at = earliest
+		}
+	}
+	g.next[code] = at
+	return at.Sub(now)
+}
+
 // spannerRetryer extends the generic gax Retryer, but also checks for any
 // retry info returned by Cloud Spanner and uses that if present.
 type spannerRetryer struct {
 	gax.Retryer
+	// gate coordinates the server retry delays with other operations.
+	gate *retryGate
 }
 
 // onCodes returns a spannerRetryer that will retry on the specified error
 // codes.
 func onCodes(bo gax.Backoff, cc ...codes.Code) gax.Retryer {
+	return onCodesWithGate(bo, nil, cc...)
+}
+
+// onCodesWithGate returns a spannerRetryer that will retry on the specified
+// error codes, and that coordinates server retry delays with the other
+// retryers that use the same gate. A nil gate disables the coordination.
+func onCodesWithGate(bo gax.Backoff, gate *retryGate, cc ...codes.Code) gax.Retryer {
 	return &spannerRetryer{
 		Retryer: gax.OnCodes(cc, bo),
+		gate:    gate,
 	}
 }
 
 // Retry returns the retry delay returned by Cloud Spanner if that is present.
 // Otherwise it returns the retry delay calculated by the generic gax Retryer.
+// Server retry delays for errors that indicate that the client should back
+// off are staggered with the retries of other operations that received the
+// same error.
 func (r *spannerRetryer) Retry(err error) (time.Duration, bool) {
 	delay, shouldRetry := r.Retryer.Retry(err)
 	if !shouldRetry {
@@ -64,22 +129,165 @@ func (r *spannerRetryer) Retry(err error) (time.Duration, bool) {
 	}
 	if serverDelay, hasServerDelay := extractRetryDelay(err); hasServerDelay {
 		delay = serverDelay
+		if code := ErrCode(err); r.gate != nil && gatedRetryCodes[code] {
+			delay = r.gate.delay(code, serverDelay, time.Now())
+		}
 	}
 	return delay, true
 }
 
+// retryableRetryer is a gax.Retryer that retries the errors that are retried
+// by the embedded Retryer, and additionally the errors for which isRetryable
+// returns true.
+type retryableRetryer struct {
+	gax.Retryer
+	backoff     gax.Backoff
+	isRetryable func(error) bool
+}
+
+// orRetryable returns a gax.Retryer that retries the errors that are retried
+// by r, and the errors for which isRetryable returns true. Aborted errors are
+// never passed to isRetryable, as they must be handled by retrying the whole
+// read-write transaction. It returns r if isRetryable is nil.
+func orRetryable(r gax.Retryer, bo gax.Backoff, isRetryable func(error) bool) gax.Retryer {
+	if isRetryable == nil {
+		return r
+	}
+	return &retryableRetryer{Retryer: r, backoff: bo, isRetryable: isRetryable}
+}
+
+// Retry implements gax.Retryer.
+func (r *retryableRetryer) Retry(err error) (time.Duration, bool) {
+	if delay, shouldRetry := r.Retryer.Retry(err); shouldRetry {
+		return delay, true
+	}
+	if ErrCode(err) == codes.Aborted || !r.isRetryable(err) {
+		return 0, false
+	}
+	if delay, ok := extractRetryDelay(err); ok {
+		return delay, true
+	}
+	return r.backoff.Pause(), true
+}
+
+// defaultMaxCommitRetries is the default maximum number of times that a Commit
+// RPC is retried for errors that are accepted by a CommitRetryConfig.
+const defaultMaxCommitRetries = 3
+
+// CommitRetryConfig configures the retrying of Commit RPCs that fail with an
+// Internal error. Some Internal errors, such as errors that are caused by a
+// reset HTTP/2 stream, are transient and can safely be retried, but are not
+// retried by default, as most Internal errors are not transient.
+type CommitRetryConfig struct {
+	// IsRetryable returns true if the given Internal error that was returned
+	// by a Commit RPC should be retried. It is only called for errors with
+	// code Internal. For example:
+	//
+	// 	func(err error) bool { return strings.Contains(err.Error(), "RST_STREAM") }
+	//
+	// Defaults to nil, which means that Commit RPCs are not retried for
+	// Internal errors.
+	IsRetryable func(err error) bool
+
+	// MaxRetries is the maximum number of times that a Commit RPC is retried
+	// for errors that are accepted by IsRetryable.
+	//
+	// Defaults to 3.
+	MaxRetries int
+}
+
+// shouldRetry returns true if a Commit RPC that failed with the given error
+// after the given number of retries should be retried.
+func (c CommitRetryConfig) shouldRetry(err error, retries int) bool {
+	if c.IsRetryable == nil || ErrCode(err) != codes.Internal {
+		return false
+	}
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxCommitRetries
+	}
+	return retries < maxRetries && c.IsRetryable(err)
+}
+
+// run executes the given Commit function and retries it for Internal errors
+// that are accepted by the CommitRetryConfig.
+func (c CommitRetryConfig) run(ctx context.Context, commit func() error) error {
+	bo := DefaultRetryBackoff
+	for retries := 0; ; retries++ {
+		err := commit()
+		if err == nil || !c.shouldRetry(err, retries) {
+			return err
+		}
+		delay := bo.Pause()
+		trace.TracePrintf(ctx, nil, "Backing off after INTERNAL error on commit for %s, then retrying", delay)
+		if err := gax.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// retryInfoFromTrailers returns the RetryInfo in the given trailers, if any.
+func retryInfoFromTrailers(trailers metadata.MD) (*edpb.RetryInfo, bool) {
+	elem, ok := trailers[retryInfoKey]
+	if !ok || len(elem) <= 0 {
+		return nil, false
+	}
+	_, b, err := metadata.DecodeKeyValue(retryInfoKey, elem[0])
+	if err != nil {
+		return nil, false
+	}
+	var retryInfo edpb.RetryInfo
+	if proto.Unmarshal([]byte(b), &retryInfo) != nil {
+		return nil, false
+	}
+	return &retryInfo, true
+}
+
+// retryInfoFromStatusDetails returns the RetryInfo in the details of the gRPC
+// status of err or any error that it wraps, if any.
+func retryInfoFromStatusDetails(err error) (*edpb.RetryInfo, bool) {
+	for ; err != nil; err = unwrap(err) {
+		// The gRPC status of a spanner.Error does not contain any details.
+		if _, ok := err.(*Error); ok {
+			continue
+		}
+		s, ok := status.FromError(err)
+		if !ok {
+			continue
+		}
+		for _, d := range s.Details() {
+			if retryInfo, ok := d.(*edpb.RetryInfo); ok {
+				return retryInfo, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // runWithRetryOnAborted executes the given function and retries it if it
 // returns an Aborted error. The delay between retries is the delay returned
 // by Cloud Spanner, and if none is returned, the calculated delay with a
 // minimum of 10ms and maximum of 32s.
 func runWithRetryOnAborted(ctx context.Context, f func(context.Context) error) error {
-	retryer := onCodes(DefaultRetryBackoff, codes.Aborted)
+	return runWithRetryOnAbortedWithMaxAttempts(ctx, 0, DefaultRetryBackoff, f)
+}
+
+// runWithRetryOnAbortedWithMaxAttempts is the same as runWithRetryOnAborted,
+// but executes the function at most maxAttempts times, and uses the given
+// backoff if Cloud Spanner does not return a retry delay. The error of the
+// last attempt is returned if all attempts were aborted. A maxAttempts value
+// <= 0 means that the number of attempts is unlimited.
+func runWithRetryOnAbortedWithMaxAttempts(ctx context.Context, maxAttempts int, bo gax.Backoff, f func(context.Context) error) error {
+	retryer := onCodes(bo, codes.Aborted)
 	funcWithRetry := func(ctx context.Context) error {
-		for {
+		for attempts := 1; ; attempts++ {
 			err := f(ctx)
 			if err == nil {
 				return nil
 			}
+			if maxAttempts > 0 && attempts >= maxAttempts {
+				return err
+			}
 			// Get Spanner error.
 			var se *Error
 			if !errorAs(err, &se) {
@@ -99,21 +307,14 @@ func runWithRetryOnAborted(ctx context.Context, f func(context.Context) error) e
 }
 
 // extractRetryDelay extracts retry backoff if present.
+// The retry information is read from the trailers of the error, or if these
+// do not contain any, from the details of the gRPC status of the error.
 func extractRetryDelay(err error) (time.Duration, bool) {
-	trailers := errTrailers(err)
-	if trailers == nil {
-		return 0, false
-	}
-	elem, ok := trailers[retryInfoKey]
-	if !ok || len(elem) <= 0 {
-		return 0, false
+	retryInfo, ok := retryInfoFromTrailers(errTrailers(err))
+	if !ok {
+		retryInfo, ok = retryInfoFromStatusDetails(err)
 	}
-	_, b, err := metadata.DecodeKeyValue(retryInfoKey, elem[0])
-	if err != nil {
-		return 0, false
-	}
-	var retryInfo edpb.RetryInfo
-	if proto.Unmarshal([]byte(b), &retryInfo) != nil {
+	if !ok {
 		return 0, false
 	}
 	delay, err := ptypes.Duration(retryInfo.RetryDelay)
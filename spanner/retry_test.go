@@ -17,6 +17,8 @@ limitations under the License.
 package spanner
 
 import (
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -42,6 +44,24 @@ func TestRetryInfo(t *testing.T) {
 	}
 }
 
+func TestRetryInfoFromStatusDetails(t *testing.T) {
+	st, err := status.New(codes.ResourceExhausted, "too many requests").WithDetails(&edpb.RetryInfo{
+		RetryDelay: ptypes.DurationProto(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{st.Err(), toSpannerError(st.Err())} {
+		gotDelay, ok := extractRetryDelay(err)
+		if !ok || !testEqual(time.Second, gotDelay) {
+			t.Errorf("%T: <ok, retryDelay> = <%t, %v>, want <true, %v>", err, ok, gotDelay, time.Second)
+		}
+	}
+	if _, ok := extractRetryDelay(status.Errorf(codes.ResourceExhausted, "too many requests")); ok {
+		t.Error("unexpected retry delay for error without RetryInfo")
+	}
+}
+
 func TestRetryerRespectsServerDelay(t *testing.T) {
 	t.Parallel()
 	serverDelay := 50 * time.Millisecond
@@ -61,3 +81,81 @@ func TestRetryerRespectsServerDelay(t *testing.T) {
 		t.Fatalf("Retry delay mismatch:\ngot: %v\nwant: %v", maxSeenDelay, serverDelay)
 	}
 }
+
+func TestRetryerStaggersServerDelayForResourceExhausted(t *testing.T) {
+	t.Parallel()
+	serverDelay := 100 * time.Millisecond
+	b, _ := proto.Marshal(&edpb.RetryInfo{
+		RetryDelay: ptypes.DurationProto(serverDelay),
+	})
+	trailers := map[string]string{
+		retryInfoKey: string(b),
+	}
+	gate := newRetryGate()
+	newRetryer := func() gax.Retryer {
+		return onCodesWithGate(gax.Backoff{}, gate, codes.ResourceExhausted, codes.Aborted)
+	}
+
+	// Simulate a number of concurrent operations that all receive the same
+	// retry delay at the same time.
+	const numOps = 5
+	retryAt := make(chan time.Time, numOps)
+	var wg sync.WaitGroup
+	for i := 0; i < numOps; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := toSpannerErrorWithMetadata(status.Errorf(codes.ResourceExhausted, "too many requests"), metadata.New(trailers))
+			now := time.Now()
+			delay, shouldRetry := newRetryer().Retry(err)
+			if !shouldRetry {
+				t.Errorf("expected shouldRetry to be true")
+			}
+			if delay < serverDelay {
+				t.Errorf("Retry delay is less than the server delay:\ngot: %v\nwant: >= %v", delay, serverDelay)
+			}
+			retryAt <- now.Add(delay)
+		}()
+	}
+	wg.Wait()
+	close(retryAt)
+	var times []time.Time
+	for at := range retryAt {
+		times = append(times, at)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	minSpacing := time.Duration(float64(serverDelay) * retryGateSpacingFraction)
+	for i := 1; i < len(times); i++ {
+		// Allow for a small difference caused by the time between taking
+		// the timestamp and calling Retry.
+		if spacing := times[i].Sub(times[i-1]); spacing < minSpacing-5*time.Millisecond {
+			t.Errorf("Retries %d and %d are not staggered:\ngot spacing: %v\nwant: >= %v", i-1, i, spacing, minSpacing)
+		}
+	}
+
+	// Other errors are not staggered.
+	for i := 0; i < 2; i++ {
+		err := toSpannerErrorWithMetadata(status.Errorf(codes.Aborted, "transaction was aborted"), metadata.New(trailers))
+		if delay, _ := newRetryer().Retry(err); delay != serverDelay {
+			t.Fatalf("Retry delay mismatch:\ngot: %v\nwant: %v", delay, serverDelay)
+		}
+	}
+}
+
+func TestRetryGateCapsStagger(t *testing.T) {
+	t.Parallel()
+	serverDelay := time.Second
+	now := time.Now()
+	gate := newRetryGate()
+	for i := 0; i < 100; i++ {
+		delay := gate.delay(codes.ResourceExhausted, serverDelay, now)
+		if delay < serverDelay || delay > 2*serverDelay {
+			t.Fatalf("Retry delay %d out of range:\ngot: %v\nwant: between %v and %v", i, delay, serverDelay, 2*serverDelay)
+		}
+	}
+
+	// Gates of different clients do not interact.
+	if delay := newRetryGate().delay(codes.ResourceExhausted, serverDelay, now); delay != serverDelay {
+		t.Fatalf("Retry delay mismatch:\ngot: %v\nwant: %v", delay, serverDelay)
+	}
+}
@@ -17,9 +17,15 @@ limitations under the License.
 package spanner
 
 import (
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"cloud.google.com/go/civil"
+	"github.com/golang/protobuf/proto"
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc/codes"
@@ -65,7 +71,9 @@ import (
 //	*[]*some_go_struct, *[]NullRow - STRUCT ARRAY
 //	*GenericColumnValue - any Cloud Spanner type
 //
-// For TIMESTAMP columns, the returned time.Time object will be in UTC.
+// For TIMESTAMP columns, the returned time.Time object will be in UTC. DATE
+// columns can also be decoded into the time.Time based types by passing the
+// DateAsTime option to ColumnWithOptions.
 //
 // To fetch an array of BYTES, pass a *[][]byte. To fetch an array of (sub)rows, pass
 // a *[]spanner.NullRow or a *[]*some_go_struct where some_go_struct holds all
@@ -199,6 +207,16 @@ func errNilColType(i int) error {
 // See the Row documentation for the list of acceptable argument types.
 // see Client.ReadWriteTransaction for an example.
 func (r *Row) Column(i int, ptr interface{}) error {
+	return r.ColumnWithOptions(i, ptr)
+}
+
+// ColumnWithOptions is the same as Column, but decodes the value with the
+// given options. For example, the following decodes a DATE column into a
+// time.Time:
+//
+//	var birthDate time.Time
+//	err := row.ColumnWithOptions(2, &birthDate, spanner.DateAsTime())
+func (r *Row) ColumnWithOptions(i int, ptr interface{}, opts ...DecodeOption) error {
 	if len(r.vals) != len(r.fields) {
 		return errFieldsMismatchVals(r)
 	}
@@ -208,7 +226,7 @@ func (r *Row) Column(i int, ptr interface{}) error {
 	if r.fields[i] == nil {
 		return errNilColType(i)
 	}
-	if err := decodeValue(r.vals[i], r.fields[i].Type, ptr); err != nil {
+	if err := decodeValueWithSetting(r.vals[i], r.fields[i].Type, ptr, newDecodeSetting(opts)); err != nil {
 		return errDecodeColumn(i, err)
 	}
 	return nil
@@ -227,11 +245,94 @@ func errColNotFound(n string) error {
 // ColumnByName fetches the value from the named column, decoding it into ptr.
 // See the Row documentation for the list of acceptable argument types.
 func (r *Row) ColumnByName(name string, ptr interface{}) error {
+	return r.ColumnByNameWithOptions(name, ptr)
+}
+
+// ColumnByNameWithOptions is the same as ColumnByName, but decodes the value
+// with the given options.
+func (r *Row) ColumnByNameWithOptions(name string, ptr interface{}, opts ...DecodeOption) error {
 	index, err := r.ColumnIndex(name)
 	if err != nil {
 		return err
 	}
-	return r.Column(index, ptr)
+	return r.ColumnWithOptions(index, ptr, opts...)
+}
+
+// GetString returns the value of the named STRING column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a STRING column or is NULL.
+// Use ColumnByName with a *NullString to read a column that may be NULL.
+func (r *Row) GetString(name string) (string, error) {
+	var v string
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetBytes returns the value of the named BYTES column. A NULL value is
+// returned as nil.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a BYTES column.
+func (r *Row) GetBytes(name string) ([]byte, error) {
+	var v []byte
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetInt64 returns the value of the named INT64 column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not an INT64 column or is NULL.
+// Use ColumnByName with a *NullInt64 to read a column that may be NULL.
+func (r *Row) GetInt64(name string) (int64, error) {
+	var v int64
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetBool returns the value of the named BOOL column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a BOOL column or is NULL.
+// Use ColumnByName with a *NullBool to read a column that may be NULL.
+func (r *Row) GetBool(name string) (bool, error) {
+	var v bool
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetFloat64 returns the value of the named FLOAT64 column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a FLOAT64 column or is NULL.
+// Use ColumnByName with a *NullFloat64 to read a column that may be NULL.
+func (r *Row) GetFloat64(name string) (float64, error) {
+	var v float64
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetTime returns the value of the named TIMESTAMP column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a TIMESTAMP column or is NULL.
+// Use ColumnByName with a *NullTime to read a column that may be NULL.
+func (r *Row) GetTime(name string) (time.Time, error) {
+	var v time.Time
+	err := r.ColumnByName(name, &v)
+	return v, err
+}
+
+// GetDate returns the value of the named DATE column.
+//
+// The returned error has code NotFound if the row does not contain the column,
+// and code InvalidArgument if the column is not a DATE column or is NULL.
+// Use ColumnByName with a *NullDate to read a column that may be NULL.
+func (r *Row) GetDate(name string) (civil.Date, error) {
+	var v civil.Date
+	err := r.ColumnByName(name, &v)
+	return v, err
 }
 
 // errNumOfColValue returns error for providing wrong number of values to Columns.
@@ -264,6 +365,201 @@ func (r *Row) Columns(ptrs ...interface{}) error {
 	return nil
 }
 
+// ToMap returns the columns of the row as a map from column name to column
+// value. The values are decoded into the native Go type of the column:
+//
+//	STRING - string
+//	BYTES - []byte
+//	INT64 - int64
+//	BOOL - bool
+//	FLOAT64 - float64
+//	TIMESTAMP - time.Time
+//	DATE - civil.Date
+//	ARRAY - []interface{}
+//	STRUCT - map[string]interface{}
+//
+// NULL values, including NULL elements of arrays, are returned as nil. ToMap
+// returns an error if the row contains more than one column with the same
+// name.
+func (r *Row) ToMap() (map[string]interface{}, error) {
+	if len(r.vals) != len(r.fields) {
+		return nil, errFieldsMismatchVals(r)
+	}
+	m := make(map[string]interface{}, len(r.fields))
+	for i, f := range r.fields {
+		if f == nil {
+			return nil, errNilColType(i)
+		}
+		if _, ok := m[f.Name]; ok {
+			return nil, errDupColName(f.Name)
+		}
+		v, err := decodeNativeValue(r.vals[i], f.Type)
+		if err != nil {
+			return nil, errDecodeColumn(i, err)
+		}
+		m[f.Name] = v
+	}
+	return m, nil
+}
+
+// ToTypedMap decodes all columns of the row into a map keyed by column name.
+// The argument p must be a pointer to a map with string keys, for example a
+// *map[string]int64, and all columns of the row must be decodable into the
+// element type of the map in the same way as Column would decode them. This
+// is useful for rows where all columns have the same type, for example:
+//
+//	var counts map[string]int64
+//	err := row.ToTypedMap(&counts)
+//
+// To decode NULL values, use one of the spanner.NullXXX types as the element
+// type of the map. ToTypedMap sets *p to a new map, and returns an error if
+// the row contains more than one column with the same name.
+func (r *Row) ToTypedMap(p interface{}) error {
+	v := reflect.ValueOf(p)
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() ||
+		v.Elem().Kind() != reflect.Map || v.Elem().Type().Key().Kind() != reflect.String {
+		return errToTypedMapArgType(p)
+	}
+	if len(r.vals) != len(r.fields) {
+		return errFieldsMismatchVals(r)
+	}
+	mt := v.Elem().Type()
+	m := reflect.MakeMapWithSize(mt, len(r.fields))
+	for i, f := range r.fields {
+		if f == nil {
+			return errNilColType(i)
+		}
+		key := reflect.ValueOf(f.Name).Convert(mt.Key())
+		if m.MapIndex(key).IsValid() {
+			return errDupColName(f.Name)
+		}
+		elem := reflect.New(mt.Elem())
+		if err := decodeValue(r.vals[i], f.Type, elem.Interface()); err != nil {
+			return errDecodeColumn(i, err)
+		}
+		m.SetMapIndex(key, elem.Elem())
+	}
+	v.Elem().Set(m)
+	return nil
+}
+
+// errToTypedMapArgType returns error for p not being a pointer to a map with
+// string keys.
+func errToTypedMapArgType(p interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "ToTypedMap(): type %T is not a valid pointer to a Go map with string keys", p)
+}
+
+// Values returns the columns of the row as a slice of values in column order.
+// The values are decoded into the same native Go types as in ToMap, and NULL
+// values are returned as nil.
+func (r *Row) Values() ([]interface{}, error) {
+	if len(r.vals) != len(r.fields) {
+		return nil, errFieldsMismatchVals(r)
+	}
+	vals := make([]interface{}, len(r.fields))
+	for i, f := range r.fields {
+		if f == nil {
+			return nil, errNilColType(i)
+		}
+		v, err := decodeNativeValue(r.vals[i], f.Type)
+		if err != nil {
+			return nil, errDecodeColumn(i, err)
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// StringValues returns the string representation of each column of the row,
+// in column order, regardless of the type of the column. It is intended for
+// logging and debugging. The values are formatted as follows:
+//
+//	STRING - the string itself
+//	BYTES - hexadecimal
+//	INT64 - decimal
+//	BOOL - true or false
+//	FLOAT64 - the shortest decimal representation
+//	TIMESTAMP - RFC 3339 with nanoseconds, in UTC
+//	DATE - YYYY-MM-DD
+//	ARRAY - [elem1, elem2, ...]
+//	STRUCT - {field1: value1, field2: value2, ...}
+//
+// NULL values are formatted as NULL, and STRING values inside an ARRAY or
+// STRUCT are quoted. Values that cannot be decoded are formatted as the text
+// of their encoded protobuf value.
+func (r *Row) StringValues() []string {
+	vals := make([]string, len(r.vals))
+	for i, v := range r.vals {
+		var t *sppb.Type
+		if i < len(r.fields) && r.fields[i] != nil {
+			t = r.fields[i].Type
+		}
+		vals[i] = formatValue(v, t, false)
+	}
+	return vals
+}
+
+// formatValue returns the string representation of v for StringValues.
+// Strings are quoted if quote is true.
+func formatValue(v *proto3.Value, t *sppb.Type, quote bool) string {
+	if v == nil {
+		return "NULL"
+	}
+	if _, isNull := v.Kind.(*proto3.Value_NullValue); isNull {
+		return "NULL"
+	}
+	if t == nil {
+		return proto.CompactTextString(v)
+	}
+	switch t.Code {
+	case sppb.TypeCode_ARRAY:
+		x, err := getListValue(v)
+		if err != nil || t.ArrayElementType == nil {
+			break
+		}
+		elems := make([]string, len(x.Values))
+		for i, ev := range x.Values {
+			elems[i] = formatValue(ev, t.ArrayElementType, true)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case sppb.TypeCode_STRUCT:
+		x, err := getListValue(v)
+		if err != nil || t.StructType == nil || len(x.Values) != len(t.StructType.Fields) {
+			break
+		}
+		fields := make([]string, len(x.Values))
+		for i, f := range t.StructType.Fields {
+			fields[i] = f.Name + ": " + formatValue(x.Values[i], f.Type, true)
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	default:
+		x, err := decodeNativeValue(v, t)
+		if err != nil {
+			break
+		}
+		switch x := x.(type) {
+		case string:
+			if quote {
+				return strconv.Quote(x)
+			}
+			return x
+		case []byte:
+			return hex.EncodeToString(x)
+		case int64:
+			return strconv.FormatInt(x, 10)
+		case bool:
+			return strconv.FormatBool(x)
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		case time.Time:
+			return x.UTC().Format(time.RFC3339Nano)
+		case civil.Date:
+			return x.String()
+		}
+	}
+	return proto.CompactTextString(v)
+}
+
 // errToStructArgType returns error for p not having the correct data type(pointer to Go struct) to
 // be the argument of Row.ToStruct.
 func errToStructArgType(p interface{}) error {
@@ -306,3 +602,87 @@ func (r *Row) ToStruct(p interface{}) error {
 		p,
 	)
 }
+
+// UnknownColumnPolicy determines how ToStructWithOptions handles columns that
+// have no corresponding field in the destination struct.
+type UnknownColumnPolicy int
+
+const (
+	// UnknownColumnError returns an error for columns that have no
+	// corresponding field. This is the behavior of ToStruct.
+	UnknownColumnError UnknownColumnPolicy = iota
+	// UnknownColumnIgnore skips columns that have no corresponding field.
+	UnknownColumnIgnore
+	// UnknownColumnCollect adds the values of columns that have no
+	// corresponding field to ToStructOptions.Leftovers.
+	UnknownColumnCollect
+)
+
+// ToStructOptions are the options for Row.ToStructWithOptions.
+type ToStructOptions struct {
+	// UnknownColumns determines how columns that have no corresponding field
+	// in the destination struct are handled.
+	//
+	// Defaults to UnknownColumnError.
+	UnknownColumns UnknownColumnPolicy
+
+	// Leftovers receives the values of the columns that have no corresponding
+	// field in the destination struct if UnknownColumns is
+	// UnknownColumnCollect. The values are decoded into the same native Go
+	// types as in Row.ToMap, and existing entries with the same name are
+	// overwritten. Leftovers must be non-nil if UnknownColumns is
+	// UnknownColumnCollect.
+	Leftovers map[string]interface{}
+
+	// DecodeOptions are the options that are used to decode the column values
+	// into the fields of the destination struct.
+	DecodeOptions []DecodeOption
+}
+
+// errNilLeftovers returns error for using UnknownColumnCollect without a map
+// for the leftover columns.
+func errNilLeftovers() error {
+	return spannerErrorf(codes.InvalidArgument, "ToStructWithOptions(): Leftovers must be non-nil when using UnknownColumnCollect")
+}
+
+// ToStructWithOptions fetches the columns in a row into the fields of a
+// struct in the same way as ToStruct, but uses the given options to handle
+// columns that have no corresponding field in the struct. This can be used
+// to read tables whose schema is newer than the struct.
+//
+// Fields in the struct that have no corresponding column are left unchanged.
+func (r *Row) ToStructWithOptions(p interface{}, opts ToStructOptions) error {
+	// Check if p is a pointer to a struct
+	if t := reflect.TypeOf(p); t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return errToStructArgType(p)
+	}
+	if len(r.vals) != len(r.fields) {
+		return errFieldsMismatchVals(r)
+	}
+	var unknown func(f *sppb.StructType_Field, v *proto3.Value) error
+	switch opts.UnknownColumns {
+	case UnknownColumnIgnore:
+		unknown = func(f *sppb.StructType_Field, v *proto3.Value) error {
+			return nil
+		}
+	case UnknownColumnCollect:
+		if opts.Leftovers == nil {
+			return errNilLeftovers()
+		}
+		unknown = func(f *sppb.StructType_Field, v *proto3.Value) error {
+			x, err := decodeNativeValue(v, f.Type)
+			if err != nil {
+				return err
+			}
+			opts.Leftovers[f.Name] = x
+			return nil
+		}
+	}
+	return decodeStructWithUnknownFields(
+		&sppb.StructType{Fields: r.fields},
+		&proto3.ListValue{Values: r.vals},
+		p,
+		unknown,
+		newDecodeSetting(opts.DecodeOptions),
+	)
+}
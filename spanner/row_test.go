@@ -18,6 +18,7 @@ package spanner
 
 import (
 	"encoding/base64"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -30,6 +31,7 @@ import (
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/go-cmp/cmp"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -1403,6 +1405,58 @@ func TestBrokenRow(t *testing.T) {
 }
 
 // Test Row.ToStruct().
+func TestToStructWithOptions(t *testing.T) {
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col1", Type: intType()},
+			{Name: "Extra1", Type: stringType()},
+			{Name: "Col2", Type: stringType()},
+			{Name: "Extra2", Type: intType()},
+		},
+		[]*proto3.Value{intProto(1), stringProto("extra"), stringProto("value"), nullProto()},
+	}
+	type dest struct {
+		Col1    int64
+		Col2    string
+		Missing string
+	}
+	want := dest{Col1: 1, Col2: "value", Missing: "unchanged"}
+
+	// UnknownColumnError behaves like ToStruct.
+	got := dest{Missing: "unchanged"}
+	if err := r.ToStructWithOptions(&got, ToStructOptions{}); !testEqual(err, errNoOrDupGoField(&got, "Extra1")) {
+		t.Errorf("ToStructWithOptions with UnknownColumnError returns error %v, want %v", err, errNoOrDupGoField(&got, "Extra1"))
+	}
+
+	// UnknownColumnIgnore skips the unknown columns.
+	got = dest{Missing: "unchanged"}
+	if err := r.ToStructWithOptions(&got, ToStructOptions{UnknownColumns: UnknownColumnIgnore}); err != nil {
+		t.Fatalf("ToStructWithOptions with UnknownColumnIgnore returns error: %v", err)
+	}
+	if !testEqual(got, want) {
+		t.Errorf("ToStructWithOptions with UnknownColumnIgnore mismatch\nGot: %v\nWant: %v", got, want)
+	}
+
+	// UnknownColumnCollect adds the unknown columns to the leftovers.
+	got = dest{Missing: "unchanged"}
+	leftovers := make(map[string]interface{})
+	if err := r.ToStructWithOptions(&got, ToStructOptions{UnknownColumns: UnknownColumnCollect, Leftovers: leftovers}); err != nil {
+		t.Fatalf("ToStructWithOptions with UnknownColumnCollect returns error: %v", err)
+	}
+	if !testEqual(got, want) {
+		t.Errorf("ToStructWithOptions with UnknownColumnCollect mismatch\nGot: %v\nWant: %v", got, want)
+	}
+	wantLeftovers := map[string]interface{}{"Extra1": "extra", "Extra2": nil}
+	if !testEqual(leftovers, wantLeftovers) {
+		t.Errorf("Leftovers mismatch\nGot: %v\nWant: %v", leftovers, wantLeftovers)
+	}
+
+	// UnknownColumnCollect requires a map for the leftovers.
+	if err := r.ToStructWithOptions(&got, ToStructOptions{UnknownColumns: UnknownColumnCollect}); !testEqual(err, errNilLeftovers()) {
+		t.Errorf("ToStructWithOptions without Leftovers returns error %v, want %v", err, errNilLeftovers())
+	}
+}
+
 func TestToStruct(t *testing.T) {
 	s := []struct {
 		// STRING / STRING ARRAY
@@ -1672,6 +1726,125 @@ func TestToStructEmbedded(t *testing.T) {
 	}
 }
 
+func TestToStructEmbeddedMultipleLevels(t *testing.T) {
+	type (
+		Audit struct {
+			CreatedAt string
+			UpdatedAt string
+			// Name is hidden by the Name field of Singer.
+			Name string
+		}
+		Versioned struct {
+			*Audit
+			Version int64
+		}
+		Singer struct {
+			Versioned
+			Name string
+			// Named structs are not flattened.
+			Info struct{ Country string } `spanner:"-"`
+		}
+	)
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Name", Type: stringType()},
+			{Name: "Version", Type: intType()},
+			{Name: "CreatedAt", Type: stringType()},
+			{Name: "UpdatedAt", Type: stringType()},
+		},
+		[]*proto3.Value{
+			stringProto("name"),
+			intProto(2),
+			stringProto("created"),
+			stringProto("updated"),
+		},
+	}
+	var got Singer
+	if err := r.ToStruct(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := Singer{
+		Versioned: Versioned{
+			Audit:   &Audit{CreatedAt: "created", UpdatedAt: "updated"},
+			Version: 2,
+		},
+		Name: "name",
+	}
+	if !testEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// A nil pointer to an unexported embedded struct cannot be allocated.
+	type (
+		audit   struct{ CreatedAt string }
+		Account struct {
+			*audit
+			Name string
+		}
+	)
+	if err := r.ToStructWithOptions(&Account{}, ToStructOptions{UnknownColumns: UnknownColumnIgnore}); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	account := Account{audit: &audit{}}
+	if err := r.ToStructWithOptions(&account, ToStructOptions{UnknownColumns: UnknownColumnIgnore}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := account.CreatedAt, "created"; g != w {
+		t.Fatalf("CreatedAt mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestToStructAnonymous(t *testing.T) {
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "SingerId", Type: intType()},
+			{Name: "FirstName", Type: stringType()},
+			{Name: "Albums", Type: listType(structType(mkField("Title", stringType())))},
+		},
+		[]*proto3.Value{
+			intProto(1),
+			stringProto("Marc"),
+			listProto(listProto(stringProto("Go, Go, Go")), listProto(stringProto("Total Junk"))),
+		},
+	}
+	// Anonymous structs, including the anonymous struct types of fields,
+	// are decoded in the same way as named structs.
+	var got struct {
+		ID      int64  `spanner:"SingerId"`
+		Name    string `spanner:"FirstName"`
+		Ignored string `spanner:"-"`
+		Albums  []struct {
+			Title string
+		}
+	}
+	if err := r.ToStruct(&got); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := got.ID, int64(1); g != w {
+		t.Errorf("ID mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.Name, "Marc"; g != w {
+		t.Errorf("Name mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(got.Albums), 2; g != w {
+		t.Fatalf("Albums length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := got.Albums[1].Title, "Total Junk"; g != w {
+		t.Errorf("Album title mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// A pointer to an anonymous struct literal can be used directly.
+	name := &struct {
+		FirstName string
+	}{}
+	if err := r.ToStructWithOptions(name, ToStructOptions{UnknownColumns: UnknownColumnIgnore}); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := name.FirstName, "Marc"; g != w {
+		t.Errorf("FirstName mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
 // Test helpers for getting column names.
 func TestColumnNameAndIndex(t *testing.T) {
 	// Test Row.Size().
@@ -1756,6 +1929,278 @@ func TestNewRow(t *testing.T) {
 	}
 }
 
+func TestToMap(t *testing.T) {
+	got, err := row.ToMap()
+	if err != nil {
+		t.Fatalf("row.ToMap() returns error: %v", err)
+	}
+	want := map[string]interface{}{
+		// STRING / STRING ARRAY
+		"STRING":            "value",
+		"NULL_STRING":       nil,
+		"STRING_ARRAY":      []interface{}{"value1", nil, "value3"},
+		"NULL_STRING_ARRAY": nil,
+		// BYTES / BYTES ARRAY
+		"BYTES":            []byte("value"),
+		"NULL_BYTES":       nil,
+		"BYTES_ARRAY":      []interface{}{[]byte("value1"), nil, []byte("value3")},
+		"NULL_BYTES_ARRAY": nil,
+		// INT64 / INT64 ARRAY
+		"INT64":            int64(17),
+		"NULL_INT64":       nil,
+		"INT64_ARRAY":      []interface{}{int64(1), int64(2), nil},
+		"NULL_INT64_ARRAY": nil,
+		// BOOL / BOOL ARRAY
+		"BOOL":            true,
+		"NULL_BOOL":       nil,
+		"BOOL_ARRAY":      []interface{}{nil, true, false},
+		"NULL_BOOL_ARRAY": nil,
+		// FLOAT64 / FLOAT64 ARRAY
+		"FLOAT64":            1.7,
+		"NULL_FLOAT64":       nil,
+		"FLOAT64_ARRAY":      []interface{}{nil, nil, 1.7},
+		"NULL_FLOAT64_ARRAY": nil,
+		// TIMESTAMP / TIMESTAMP ARRAY
+		"TIMESTAMP":            tm,
+		"NULL_TIMESTAMP":       nil,
+		"TIMESTAMP_ARRAY":      []interface{}{nil, tm},
+		"NULL_TIMESTAMP_ARRAY": nil,
+		// DATE / DATE ARRAY
+		"DATE":            dt,
+		"NULL_DATE":       nil,
+		"DATE_ARRAY":      []interface{}{nil, dt},
+		"NULL_DATE_ARRAY": nil,
+		// STRUCT ARRAY
+		"STRUCT_ARRAY": []interface{}{
+			nil,
+			map[string]interface{}{"Col1": int64(3), "Col2": 33.3, "Col3": "three"},
+			nil,
+		},
+		"NULL_STRUCT_ARRAY": nil,
+	}
+	if !testEqual(got, want) {
+		t.Errorf("row.ToMap() = %v, want %v", got, want)
+	}
+
+	// Duplicate column names cannot be mapped.
+	dup := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col", Type: intType()},
+			{Name: "Col", Type: stringType()},
+		},
+		[]*proto3.Value{intProto(1), stringProto("value")},
+	}
+	if _, err := dup.ToMap(); !testEqual(err, errDupColName("Col")) {
+		t.Errorf("dup.ToMap() returns error %v, want %v", err, errDupColName("Col"))
+	}
+}
+
+func TestToTypedMap(t *testing.T) {
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Singers", Type: intType()},
+			{Name: "Albums", Type: intType()},
+			{Name: "Songs", Type: intType()},
+		},
+		[]*proto3.Value{intProto(10), intProto(25), intProto(312)},
+	}
+	var got map[string]int64
+	if err := r.ToTypedMap(&got); err != nil {
+		t.Fatalf("r.ToTypedMap() returns error: %v", err)
+	}
+	want := map[string]int64{"Singers": 10, "Albums": 25, "Songs": 312}
+	if !testEqual(got, want) {
+		t.Errorf("r.ToTypedMap() = %v, want %v", got, want)
+	}
+
+	// NULL values can be decoded into NullXXX types.
+	withNull := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col1", Type: intType()},
+			{Name: "Col2", Type: intType()},
+		},
+		[]*proto3.Value{intProto(1), nullProto()},
+	}
+	var gotNull map[string]NullInt64
+	if err := withNull.ToTypedMap(&gotNull); err != nil {
+		t.Fatalf("withNull.ToTypedMap() returns error: %v", err)
+	}
+	wantNull := map[string]NullInt64{"Col1": {1, true}, "Col2": {}}
+	if !testEqual(gotNull, wantNull) {
+		t.Errorf("withNull.ToTypedMap() = %v, want %v", gotNull, wantNull)
+	}
+	// but not into plain Go types.
+	if err := withNull.ToTypedMap(&got); err == nil {
+		t.Error("withNull.ToTypedMap() with NULL value into int64 succeeds unexpectedly")
+	}
+
+	// Columns of other types cannot be decoded into the map.
+	mixed := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col1", Type: intType()},
+			{Name: "Col2", Type: stringType()},
+		},
+		[]*proto3.Value{intProto(1), stringProto("value")},
+	}
+	if err := mixed.ToTypedMap(&got); err == nil {
+		t.Error("mixed.ToTypedMap() succeeds unexpectedly")
+	}
+
+	// Duplicate column names cannot be mapped.
+	dup := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col", Type: intType()},
+			{Name: "Col", Type: intType()},
+		},
+		[]*proto3.Value{intProto(1), intProto(2)},
+	}
+	if err := dup.ToTypedMap(&got); !testEqual(err, errDupColName("Col")) {
+		t.Errorf("dup.ToTypedMap() returns error %v, want %v", err, errDupColName("Col"))
+	}
+
+	// The argument must be a pointer to a map with string keys.
+	for _, p := range []interface{}{nil, got, (*map[string]int64)(nil), &map[int64]int64{}, new(int64)} {
+		if err := r.ToTypedMap(p); !testEqual(err, errToTypedMapArgType(p)) {
+			t.Errorf("r.ToTypedMap(%T) returns error %v, want %v", p, err, errToTypedMapArgType(p))
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "Col1", Type: stringType()},
+			{Name: "Col2", Type: intType()},
+			{Name: "Col3", Type: stringType()},
+			{Name: "Col4", Type: listType(floatType())},
+		},
+		[]*proto3.Value{
+			stringProto("value"),
+			intProto(17),
+			nullProto(),
+			listProto(floatProto(1.5), nullProto()),
+		},
+	}
+	got, err := r.Values()
+	if err != nil {
+		t.Fatalf("r.Values() returns error: %v", err)
+	}
+	want := []interface{}{"value", int64(17), nil, []interface{}{1.5, nil}}
+	if !testEqual(got, want) {
+		t.Errorf("r.Values() = %v, want %v", got, want)
+	}
+
+	// The values must be in the same order as the columns and decode to the
+	// same values as ToMap.
+	vals, err := row.Values()
+	if err != nil {
+		t.Fatalf("row.Values() returns error: %v", err)
+	}
+	m, err := row.ToMap()
+	if err != nil {
+		t.Fatalf("row.ToMap() returns error: %v", err)
+	}
+	if g, w := len(vals), row.Size(); g != w {
+		t.Fatalf("Number of values mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	for i, v := range vals {
+		name := row.ColumnName(i)
+		if !testEqual(v, m[name]) {
+			t.Errorf("Value of column %s mismatch\nGot: %v\nWant: %v", name, v, m[name])
+		}
+	}
+}
+
+func TestStringValues(t *testing.T) {
+	r := Row{
+		[]*sppb.StructType_Field{
+			{Name: "STRING", Type: stringType()},
+			{Name: "BYTES", Type: bytesType()},
+			{Name: "INT64", Type: intType()},
+			{Name: "BOOL", Type: boolType()},
+			{Name: "FLOAT64", Type: floatType()},
+			{Name: "TIMESTAMP", Type: timeType()},
+			{Name: "DATE", Type: dateType()},
+			{Name: "NULL", Type: intType()},
+			{Name: "STRING_ARRAY", Type: listType(stringType())},
+			{Name: "STRUCT", Type: structType(mkField("Col1", intType()), mkField("Col2", stringType()))},
+		},
+		[]*proto3.Value{
+			stringProto("value"),
+			bytesProto([]byte{0xde, 0xad, 0xbe, 0xef}),
+			intProto(-17),
+			boolProto(true),
+			floatProto(1.5),
+			timeProto(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)),
+			dateProto(civil.Date{Year: 2020, Month: 1, Day: 2}),
+			nullProto(),
+			listProto(stringProto("a"), nullProto()),
+			listProto(intProto(1), stringProto("b")),
+		},
+	}
+	want := []string{
+		"value",
+		"deadbeef",
+		"-17",
+		"true",
+		"1.5",
+		"2020-01-02T03:04:05.000000006Z",
+		"2020-01-02",
+		"NULL",
+		`["a", NULL]`,
+		`{Col1: 1, Col2: "b"}`,
+	}
+	if got := r.StringValues(); !testEqual(got, want) {
+		t.Errorf("r.StringValues() mismatch\nGot: %v\nWant: %v", got, want)
+	}
+}
+
+func TestGetters(t *testing.T) {
+	getters := []struct {
+		name   string
+		column string
+		get    func(string) (interface{}, error)
+		want   interface{}
+	}{
+		{"GetString", "STRING", func(n string) (interface{}, error) { return row.GetString(n) }, "value"},
+		{"GetBytes", "BYTES", func(n string) (interface{}, error) { return row.GetBytes(n) }, []byte("value")},
+		{"GetInt64", "INT64", func(n string) (interface{}, error) { return row.GetInt64(n) }, int64(17)},
+		{"GetBool", "BOOL", func(n string) (interface{}, error) { return row.GetBool(n) }, true},
+		{"GetFloat64", "FLOAT64", func(n string) (interface{}, error) { return row.GetFloat64(n) }, 1.7},
+		{"GetTime", "TIMESTAMP", func(n string) (interface{}, error) { return row.GetTime(n) }, tm},
+		{"GetDate", "DATE", func(n string) (interface{}, error) { return row.GetDate(n) }, dt},
+	}
+	for _, test := range getters {
+		got, err := test.get(test.column)
+		if err != nil {
+			t.Errorf("%s(%q) returns error: %v", test.name, test.column, err)
+			continue
+		}
+		if !testEqual(got, test.want) {
+			t.Errorf("%s(%q) = %v, want %v", test.name, test.column, got, test.want)
+		}
+		// A missing column is reported as NotFound.
+		if _, err := test.get("NOT_A_COLUMN"); ErrCode(err) != codes.NotFound {
+			t.Errorf("%s(%q) returns error %v, want code %v", test.name, "NOT_A_COLUMN", err, codes.NotFound)
+		}
+		// A column of a different type is reported as InvalidArgument.
+		mismatch := "INT64"
+		if test.column == "INT64" {
+			mismatch = "STRING"
+		}
+		if _, err := test.get(mismatch); ErrCode(err) != codes.InvalidArgument {
+			t.Errorf("%s(%q) returns error %v, want code %v", test.name, mismatch, err, codes.InvalidArgument)
+		}
+	}
+	// NULL values can only be read into types that support NULL.
+	if _, err := row.GetInt64("NULL_INT64"); ErrCode(err) != codes.InvalidArgument {
+		t.Errorf("GetInt64(%q) returns error %v, want code %v", "NULL_INT64", err, codes.InvalidArgument)
+	}
+	if got, err := row.GetBytes("NULL_BYTES"); err != nil || got != nil {
+		t.Errorf("GetBytes(%q) = (%v, %v), want (nil, nil)", "NULL_BYTES", got, err)
+	}
+}
+
 func BenchmarkColumn(b *testing.B) {
 	var s string
 	for i := 0; i < b.N; i++ {
@@ -1764,3 +2209,221 @@ func BenchmarkColumn(b *testing.B) {
 		}
 	}
 }
+
+func TestColumnWithOptionsDateAsTime(t *testing.T) {
+	d := civil.Date{Year: 2020, Month: 2, Day: 29}
+	midnight := time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)
+	r := Row{
+		fields: []*sppb.StructType_Field{
+			{Name: "Date", Type: dateType()},
+			{Name: "NullDate", Type: dateType()},
+			{Name: "Dates", Type: listType(dateType())},
+		},
+		vals: []*proto3.Value{
+			dateProto(d),
+			nullProto(),
+			listProto(dateProto(d), nullProto()),
+		},
+	}
+
+	// civil.Date is still supported, with and without the option.
+	var gotDate civil.Date
+	if err := r.Column(0, &gotDate); err != nil {
+		t.Fatal(err)
+	}
+	if gotDate != d {
+		t.Fatalf("date mismatch\nGot: %v\nWant: %v", gotDate, d)
+	}
+	gotDate = civil.Date{}
+	if err := r.ColumnWithOptions(0, &gotDate, DateAsTime()); err != nil {
+		t.Fatal(err)
+	}
+	if gotDate != d {
+		t.Fatalf("date mismatch\nGot: %v\nWant: %v", gotDate, d)
+	}
+	// time.Time is only supported with the option.
+	var gotTime time.Time
+	if err := r.Column(0, &gotTime); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	if err := r.ColumnByNameWithOptions("Date", &gotTime, DateAsTime()); err != nil {
+		t.Fatal(err)
+	}
+	if !gotTime.Equal(midnight) || gotTime.Location() != time.UTC {
+		t.Fatalf("time mismatch\nGot: %v\nWant: %v", gotTime, midnight)
+	}
+	if err := r.ColumnWithOptions(1, &gotTime, DateAsTime()); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	var gotNullTime NullTime
+	if err := r.ColumnWithOptions(1, &gotNullTime, DateAsTime()); err != nil {
+		t.Fatal(err)
+	}
+	if gotNullTime.Valid {
+		t.Fatalf("NULL date should decode into an invalid NullTime, got %v", gotNullTime)
+	}
+	var gotNullTimes []NullTime
+	if err := r.ColumnWithOptions(2, &gotNullTimes, DateAsTime()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []NullTime{{Time: midnight, Valid: true}, {}}; !testEqual(gotNullTimes, want) {
+		t.Fatalf("times mismatch\nGot: %v\nWant: %v", gotNullTimes, want)
+	}
+	var gotTimes []time.Time
+	if err := r.ColumnWithOptions(2, &gotTimes, DateAsTime()); err == nil {
+		t.Fatal("missing error for NULL element in []time.Time")
+	}
+
+	type row struct {
+		Date     time.Time
+		NullDate NullTime
+		Dates    []NullTime
+	}
+	var s row
+	if err := r.ToStruct(&s); err == nil {
+		t.Fatal("missing error for decoding DATE into time.Time without DateAsTime")
+	}
+	if err := r.ToStructWithOptions(&s, ToStructOptions{DecodeOptions: []DecodeOption{DateAsTime()}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (row{Date: midnight, Dates: []NullTime{{Time: midnight, Valid: true}, {}}}); !testEqual(s, want) {
+		t.Fatalf("struct mismatch\nGot: %v\nWant: %v", s, want)
+	}
+}
+
+func TestColumnWithOptionsBytesAsBase64String(t *testing.T) {
+	b := []byte("value")
+	enc := base64.StdEncoding.EncodeToString(b)
+	r := Row{
+		fields: []*sppb.StructType_Field{
+			{Name: "Bytes", Type: bytesType()},
+			{Name: "NullBytes", Type: bytesType()},
+			{Name: "BytesArray", Type: listType(bytesType())},
+		},
+		vals: []*proto3.Value{
+			bytesProto(b),
+			nullProto(),
+			listProto(bytesProto(b), nullProto()),
+		},
+	}
+
+	// []byte is still the default, with and without the option.
+	var gotBytes []byte
+	if err := r.ColumnWithOptions(0, &gotBytes, BytesAsBase64String()); err != nil {
+		t.Fatal(err)
+	}
+	if !testEqual(gotBytes, b) {
+		t.Fatalf("bytes mismatch\nGot: %v\nWant: %v", gotBytes, b)
+	}
+	// string is only supported with the option.
+	var gotString string
+	if err := r.Column(0, &gotString); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	if err := r.ColumnByNameWithOptions("Bytes", &gotString, BytesAsBase64String()); err != nil {
+		t.Fatal(err)
+	}
+	if gotString != enc {
+		t.Fatalf("string mismatch\nGot: %v\nWant: %v", gotString, enc)
+	}
+	if err := r.ColumnWithOptions(1, &gotString, BytesAsBase64String()); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	var gotNullString NullString
+	if err := r.ColumnWithOptions(1, &gotNullString, BytesAsBase64String()); err != nil {
+		t.Fatal(err)
+	}
+	if gotNullString.Valid {
+		t.Fatalf("NULL bytes should decode into an invalid NullString, got %v", gotNullString)
+	}
+	var gotNullStrings []NullString
+	if err := r.ColumnWithOptions(2, &gotNullStrings, BytesAsBase64String()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []NullString{{StringVal: enc, Valid: true}, {}}; !testEqual(gotNullStrings, want) {
+		t.Fatalf("strings mismatch\nGot: %v\nWant: %v", gotNullStrings, want)
+	}
+	var gotStrings []string
+	if err := r.ColumnWithOptions(2, &gotStrings, BytesAsBase64String()); err == nil {
+		t.Fatal("missing error for NULL element in []string")
+	}
+
+	type row struct {
+		Bytes      string
+		NullBytes  NullString
+		BytesArray []NullString
+	}
+	var s row
+	if err := r.ToStruct(&s); err == nil {
+		t.Fatal("missing error for decoding BYTES into string without BytesAsBase64String")
+	}
+	if err := r.ToStructWithOptions(&s, ToStructOptions{DecodeOptions: []DecodeOption{BytesAsBase64String()}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (row{Bytes: enc, BytesArray: []NullString{{StringVal: enc, Valid: true}, {}}}); !testEqual(s, want) {
+		t.Fatalf("struct mismatch\nGot: %v\nWant: %v", s, want)
+	}
+}
+
+func TestColumnWithOptionsInt64AsFloat64(t *testing.T) {
+	// 2^53+1 is the smallest positive integer that cannot be represented
+	// exactly by a float64.
+	const inexact = 1<<53 + 1
+	r := Row{
+		fields: []*sppb.StructType_Field{
+			{Name: "Exact", Type: intType()},
+			{Name: "Inexact", Type: intType()},
+			{Name: "Array", Type: listType(intType())},
+		},
+		vals: []*proto3.Value{
+			intProto(1 << 53),
+			intProto(inexact),
+			listProto(intProto(-42), nullProto(), intProto(math.MaxInt64)),
+		},
+	}
+
+	// float64 is only supported with the option.
+	var f float64
+	if err := r.Column(0, &f); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	for _, opts := range [][]DecodeOption{
+		{Int64AsFloat64()},
+		{Int64AsFloat64(), StrictFloatPrecision()},
+	} {
+		if err := r.ColumnWithOptions(0, &f, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if g, w := f, float64(1<<53); g != w {
+			t.Fatalf("float64 mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	}
+
+	// Precision is only lost without StrictFloatPrecision.
+	if err := r.ColumnWithOptions(1, &f, Int64AsFloat64()); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := f, float64(1<<53); g != w {
+		t.Fatalf("float64 mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var nf NullFloat64
+	if err := r.ColumnWithOptions(1, &nf, Int64AsFloat64(), StrictFloatPrecision()); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+
+	var nfs []NullFloat64
+	if err := r.ColumnWithOptions(2, &nfs, Int64AsFloat64()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []NullFloat64{{Float64: -42, Valid: true}, {}, {Float64: math.MaxInt64, Valid: true}}; !testEqual(nfs, want) {
+		t.Fatalf("floats mismatch\nGot: %v\nWant: %v", nfs, want)
+	}
+	// math.MaxInt64 is rounded up to 2^63.
+	if err := r.ColumnWithOptions(2, &nfs, Int64AsFloat64(), StrictFloatPrecision()); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+	var fs []float64
+	if err := r.ColumnWithOptions(2, &fs, Int64AsFloat64()); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %v", err, codes.InvalidArgument)
+	}
+}
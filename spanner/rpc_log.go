@@ -0,0 +1,139 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RPCInfo contains diagnostic information about an RPC that was executed by a
+// Client. See ClientConfig.RecentRPCBufferSize.
+type RPCInfo struct {
+	// Method is the name of the RPC method, for example "ExecuteStreamingSql".
+	Method string
+	// Start is the time that the RPC was started.
+	Start time.Time
+	// Latency is the time between the start and the end of the RPC. For
+	// streaming RPCs, this includes the time that was needed to receive all
+	// results from the stream.
+	Latency time.Duration
+	// Code is the status code that the RPC returned.
+	Code codes.Code
+}
+
+// rpcLog is a fixed-size ring buffer of the most recent RPCs of a client.
+type rpcLog struct {
+	mu sync.Mutex
+	// entries contains the recorded RPCs. Once the buffer is full, next is the
+	// index of the oldest entry.
+	entries []RPCInfo
+	next    int
+	full    bool
+}
+
+func newRPCLog(size int) *rpcLog {
+	return &rpcLog{entries: make([]RPCInfo, size)}
+}
+
+// record adds an RPC to the log, overwriting the oldest entry if the log is
+// full.
+func (l *rpcLog) record(method string, start time.Time, err error) {
+	info := RPCInfo{
+		Method:  method[strings.LastIndex(method, "/")+1:],
+		Start:   start,
+		Latency: time.Since(start),
+		Code:    status.Code(err),
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = info
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// list returns the recorded RPCs ordered from the oldest to the most recent.
+func (l *rpcLog) list() []RPCInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]RPCInfo(nil), l.entries[:l.next]...)
+	}
+	res := make([]RPCInfo, 0, len(l.entries))
+	res = append(res, l.entries[l.next:]...)
+	return append(res, l.entries[:l.next]...)
+}
+
+// unaryInterceptor returns a unary client interceptor that records all unary
+// RPCs in the log.
+func (l *rpcLog) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		l.record(method, start, err)
+		return err
+	}
+}
+
+// streamInterceptor returns a stream client interceptor that records all
+// streaming RPCs in the log. A streaming RPC is recorded when the stream
+// returns an error or has been fully consumed.
+func (l *rpcLog) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			l.record(method, start, err)
+			return nil, err
+		}
+		return &loggedClientStream{ClientStream: stream, log: l, method: method, start: start}, nil
+	}
+}
+
+// loggedClientStream records the RPC of the stream in a rpcLog when the stream
+// has finished.
+type loggedClientStream struct {
+	grpc.ClientStream
+	log    *rpcLog
+	method string
+	start  time.Time
+	once   sync.Once
+}
+
+func (s *loggedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if err == io.EOF {
+				s.log.record(s.method, s.start, nil)
+			} else {
+				s.log.record(s.method, s.start, err)
+			}
+		})
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClient_RecentRPCs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		RecentRPCBufferSize: 100,
+		SessionPoolConfig:   SessionPoolConfig{MinOpened: 0},
+	})
+	defer teardown()
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		MinimumExecutionTime: 10 * time.Millisecond,
+	})
+
+	if err := client.Single().Query(ctx, NewStatement(SelectFooFromBar)).Do(func(*Row) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Apply(ctx, []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}); err != nil {
+		t.Fatal(err)
+	}
+
+	rpcs := client.RecentRPCs()
+	var got []string
+	found := make(map[string]RPCInfo)
+	for _, rpc := range rpcs {
+		got = append(got, rpc.Method)
+		found[rpc.Method] = rpc
+	}
+	for _, test := range []struct {
+		method     string
+		minLatency time.Duration
+	}{
+		{"ExecuteStreamingSql", 0},
+		{"BeginTransaction", 0},
+		{"Commit", 10 * time.Millisecond},
+	} {
+		rpc, ok := found[test.method]
+		if !ok {
+			t.Fatalf("missing RPC %v in %v", test.method, got)
+		}
+		if rpc.Code != codes.OK {
+			t.Fatalf("%v: code mismatch\nGot: %v\nWant: %v", test.method, rpc.Code, codes.OK)
+		}
+		if rpc.Start.IsZero() {
+			t.Fatalf("%v: missing start time", test.method)
+		}
+		if rpc.Latency < test.minLatency {
+			t.Fatalf("%v: latency mismatch\nGot: %v\nWant at least: %v", test.method, rpc.Latency, test.minLatency)
+		}
+	}
+	if g, w := rpcs[len(rpcs)-1].Method, "Commit"; g != w {
+		t.Fatalf("last RPC mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_RecentRPCs_Disabled(t *testing.T) {
+	t.Parallel()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+	if _, err := client.Apply(context.Background(), []*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})}); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.RecentRPCs(); got != nil {
+		t.Fatalf("RecentRPCs mismatch\nGot: %v\nWant: nil", got)
+	}
+}
+
+func TestRPCLog(t *testing.T) {
+	l := newRPCLog(3)
+	if got := l.list(); len(got) != 0 {
+		t.Fatalf("log should be empty, got %v", got)
+	}
+	methods := []string{"/google.spanner.v1.Spanner/A", "/google.spanner.v1.Spanner/B", "/google.spanner.v1.Spanner/C", "/google.spanner.v1.Spanner/D"}
+	for i, m := range methods {
+		var err error
+		if i%2 == 1 {
+			err = status.Error(codes.Aborted, "aborted")
+		}
+		l.record(m, time.Now(), err)
+	}
+	got := l.list()
+	want := []struct {
+		method string
+		code   codes.Code
+	}{{"B", codes.Aborted}, {"C", codes.OK}, {"D", codes.Aborted}}
+	if len(got) != len(want) {
+		t.Fatalf("length mismatch\nGot: %v\nWant: %v", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Method != w.method || got[i].Code != w.code {
+			t.Fatalf("%d: entry mismatch\nGot: %v %v\nWant: %v %v", i, got[i].Method, got[i].Code, w.method, w.code)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"container/list"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/rand"
@@ -32,8 +33,10 @@ import (
 	"cloud.google.com/go/internal/trace"
 	vkit "cloud.google.com/go/spanner/apiv1"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // sessionHandle is an interface for transactions to access Cloud Spanner
@@ -67,6 +70,9 @@ func (sh *sessionHandle) recycle() {
 	}
 	p := sh.session.pool
 	tracked := sh.trackedSessionHandle
+	if p != nil {
+		p.resetStaleSessionErrors()
+	}
 	sh.session.recycle()
 	sh.session = nil
 	sh.trackedSessionHandle = nil
@@ -126,7 +132,8 @@ func (sh *sessionHandle) getTransactionID() transactionID {
 
 // destroy destroys the inner session object. It is safe to call destroy
 // multiple times and only the first call would attempt to
-// destroy the inner session object.
+// destroy the inner session object. destroy is called when Cloud Spanner
+// returned `Session not found` for the session.
 func (sh *sessionHandle) destroy() {
 	sh.mu.Lock()
 	s := sh.session
@@ -147,6 +154,9 @@ func (sh *sessionHandle) destroy() {
 		return
 	}
 	s.destroy(false)
+	if p != nil {
+		p.recordStaleSessionError(s.generation)
+	}
 }
 
 // session wraps a Cloud Spanner session ID through which transactions are
@@ -164,9 +174,20 @@ type session struct {
 	// createTime is the timestamp of the session's creation. It is set only
 	// once during session's creation.
 	createTime time.Time
+	// generation is the generation of the session pool when the session was
+	// created. Sessions of an older generation are considered stale and are
+	// not returned to the pool. It is set only once during session's creation.
+	generation uint64
 	// logger is the logger configured for the Spanner client that created the
 	// session. If nil, logging will be directed to the standard logger.
 	logger *log.Logger
+	// isRetryableStreamError decides whether additional errors of streaming
+	// reads and queries on the session are retried. It is set only once
+	// during session's creation. See ClientConfig.IsRetryableStreamError.
+	isRetryableStreamError func(error) bool
+	// retryGate coordinates the server retry delays of streaming reads and
+	// queries on the session with those of the other sessions of the client.
+	retryGate *retryGate
 
 	// mu protects the following fields from concurrent access: both
 	// healthcheck workers and transactions can modify them.
@@ -297,9 +318,13 @@ func (s *session) getNextCheck() time.Time {
 	return s.nextCheck
 }
 
-// recycle turns the session back to its home session pool.
+// recycle turns the session back to its home session pool. It is a no-op for
+// sessions that do not belong to a session pool.
 func (s *session) recycle() {
 	s.setTransactionID(nil)
+	if s.pool == nil {
+		return
+	}
 	if !s.pool.recycle(s) {
 		// s is rejected by its home session pool because it expired and the
 		// session pool currently has enough open sessions.
@@ -308,8 +333,12 @@ func (s *session) recycle() {
 }
 
 // destroy removes the session from its home session pool, healthcheck queue
-// and Cloud Spanner service.
+// and Cloud Spanner service. Sessions that do not belong to a session pool are
+// not destroyed.
 func (s *session) destroy(isExpire bool) bool {
+	if s.pool == nil {
+		return false
+	}
 	// Remove s from session pool.
 	if !s.pool.remove(s, isExpire) {
 		return false
@@ -323,6 +352,24 @@ func (s *session) destroy(isExpire bool) bool {
 	return true
 }
 
+// destroyIfTooOld removes the session from its home session pool, healthcheck
+// queue and Cloud Spanner service if the session is idle and older than the
+// MaxSessionAge of the session pool. It returns true if the session was
+// destroyed.
+func (s *session) destroyIfTooOld() bool {
+	if s.pool == nil {
+		return false
+	}
+	if !s.pool.removeIfTooOld(s) {
+		return false
+	}
+	s.pool.hc.unregister(s)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	s.delete(ctx)
+	return true
+}
+
 func (s *session) delete(ctx context.Context) {
 	// Ignore the error because even if we fail to explicitly destroy the
 	// session, it will be eventually garbage collected by Cloud Spanner.
@@ -338,11 +385,12 @@ func (s *session) prepareForWrite(ctx context.Context) error {
 	if s.isWritePrepared() {
 		return nil
 	}
-	tx, err := beginTransaction(contextWithOutgoingMetadata(ctx, s.md), s.getID(), s.client)
+	tx, err := beginTransaction(contextWithOutgoingMetadata(ctx, s.md), s.getID(), s.client, nil)
 	// Session not found should cause the session to be removed from the pool.
 	if isSessionNotFoundError(err) {
 		s.pool.remove(s, false)
 		s.pool.hc.unregister(s)
+		s.pool.recordStaleSessionError(s.generation)
 		return err
 	}
 	// Enable/disable background preparing of write sessions depending on
@@ -407,6 +455,36 @@ type SessionPoolConfig struct {
 	// Defaults to 5m.
 	HealthCheckInterval time.Duration
 
+	// MaxSessionAge is the maximum age of a session in the pool. Idle sessions
+	// that are older than MaxSessionAge are deleted by the health checker and
+	// replaced by new sessions. This can be used to periodically rebalance the
+	// sessions across the gRPC channels of the client. Sessions are only
+	// recycled when they are checked by the health checker, which means that a
+	// session may live up to HealthCheckInterval longer than MaxSessionAge.
+	//
+	// Defaults to 0, which means that sessions are not recycled based on
+	// their age.
+	MaxSessionAge time.Duration
+
+	// StaleSessionThreshold is the number of consecutive `Session not found`
+	// and Unavailable errors after which the session pool considers all its
+	// sessions to be stale. This can for example happen after a failover to a
+	// different endpoint. When the threshold is reached, the session pool
+	// deletes all its idle sessions, removes sessions that are in use when
+	// they are returned to the pool, and creates new sessions up to
+	// MinOpened.
+	//
+	// Each attempt of an RPC that fails with Unavailable counts, also if the
+	// RPC is retried. Errors of sessions that were already replaced do not
+	// count. If ClientConfig.EndpointResolver is set, the endpoint is
+	// resolved again before the new sessions are created, and the client
+	// connects to the new endpoint if it has changed. Otherwise the new
+	// sessions use the existing gRPC connections of the client.
+	//
+	// Defaults to 0, which means that sessions are only removed one at a time
+	// when Cloud Spanner returns `Session not found` for them.
+	StaleSessionThreshold uint64
+
 	// TrackSessionHandles determines whether the session pool will keep track
 	// of the stacktrace of the goroutines that take sessions from the pool.
 	// This setting can be used to track down session leak problems.
@@ -414,12 +492,35 @@ type SessionPoolConfig struct {
 	// Defaults to false.
 	TrackSessionHandles bool
 
+	// DisableHealthCheck disables the health check workers that periodically
+	// ping the idle sessions of the pool and prepare sessions for read/write
+	// transactions in the background. This can be useful for short-lived
+	// processes, for which the background requests are wasted. HealthCheckWorkers,
+	// HealthCheckInterval, WriteSessions and MaxSessionAge have no effect if
+	// the health check is disabled.
+	//
+	// Sessions are still checked lazily: a session that has not been checked
+	// for more than two HealthCheckIntervals is pinged when it is taken from
+	// the pool, and sessions for which Cloud Spanner returns `Session not
+	// found` are removed from the pool. Read/write transactions begin their
+	// transaction when the session is taken from the pool. The number of
+	// sessions in the pool is still maintained.
+	//
+	// Defaults to false.
+	DisableHealthCheck bool
+
 	// healthCheckSampleInterval is how often the health checker samples live
 	// session (for use in maintaining session pool size).
 	//
 	// Defaults to 1m.
 	healthCheckSampleInterval time.Duration
 
+	// clock returns the current time that is used to determine the age of a
+	// session. It can be overridden in tests.
+	//
+	// Defaults to time.Now.
+	clock func() time.Time
+
 	// sessionLabels for the sessions created in the session pool.
 	sessionLabels map[string]string
 }
@@ -463,6 +564,13 @@ func errHealthCheckIntervalNegative(interval time.Duration) error {
 		"require SessionPoolConfig.HealthCheckInterval >= 0, got %v", interval)
 }
 
+// errMaxSessionAgeNegative returns error for
+// SessionPoolConfig.MaxSessionAge < 0
+func errMaxSessionAgeNegative(age time.Duration) error {
+	return spannerErrorf(codes.InvalidArgument,
+		"require SessionPoolConfig.MaxSessionAge >= 0, got %v", age)
+}
+
 // validate verifies that the SessionPoolConfig is good for use.
 func (spc *SessionPoolConfig) validate() error {
 	if spc.MinOpened > spc.MaxOpened && spc.MaxOpened > 0 {
@@ -477,6 +585,9 @@ func (spc *SessionPoolConfig) validate() error {
 	if spc.HealthCheckInterval < 0 {
 		return errHealthCheckIntervalNegative(spc.HealthCheckInterval)
 	}
+	if spc.MaxSessionAge < 0 {
+		return errMaxSessionAgeNegative(spc.MaxSessionAge)
+	}
 	return nil
 }
 
@@ -511,6 +622,21 @@ type sessionPool struct {
 	// PermissionDenied or `Database not found`. Further background calls to
 	// prepare sessions will be disabled.
 	disableBackgroundPrepareSessions bool
+	// staleSessionErrors is the number of consecutive `Session not found` and
+	// Unavailable errors that have been returned for sessions of the pool.
+	staleSessionErrors uint64
+	// generation is incremented each time the pool discards all its sessions
+	// because they have become stale.
+	generation uint64
+	// numWaiters is the number of callers that are waiting for a session to
+	// become available.
+	numWaiters uint64
+	// totalCreated is the total number of sessions that have been created by
+	// the pool.
+	totalCreated uint64
+	// totalDeleted is the total number of sessions that have been removed from
+	// the pool.
+	totalDeleted uint64
 	// configuration of the session pool.
 	SessionPoolConfig
 	// hc is the health checker
@@ -519,6 +645,9 @@ type sessionPool struct {
 	// mw is the maintenance window containing statistics for the max number of
 	// sessions checked out of the pool during the last 10 minutes.
 	mw *maintenanceWindow
+	// acquisitionLatency is the histogram of the time that was needed to take
+	// a session from the pool.
+	acquisitionLatency *latencyHistogram
 }
 
 // newSessionPool creates a new session pool.
@@ -526,13 +655,6 @@ func newSessionPool(sc *sessionClient, config SessionPoolConfig) (*sessionPool,
 	if err := config.validate(); err != nil {
 		return nil, err
 	}
-	pool := &sessionPool{
-		sc:                sc,
-		valid:             true,
-		mayGetSession:     make(chan struct{}),
-		SessionPoolConfig: config,
-		mw:                newMaintenanceWindow(config.MaxOpened),
-	}
 	if config.HealthCheckWorkers == 0 {
 		// With 10 workers and assuming average latency of 5ms for
 		// BeginTransaction, we will be able to prepare 2000 tx/sec in advance.
@@ -548,11 +670,19 @@ func newSessionPool(sc *sessionClient, config SessionPoolConfig) (*sessionPool,
 	if config.healthCheckSampleInterval == 0 {
 		config.healthCheckSampleInterval = time.Minute
 	}
+	pool := &sessionPool{
+		sc:                 sc,
+		valid:              true,
+		mayGetSession:      make(chan struct{}),
+		SessionPoolConfig:  config,
+		mw:                 newMaintenanceWindow(config.MaxOpened),
+		acquisitionLatency: newLatencyHistogram(sessionAcquisitionLatencyBounds),
+	}
 	// On GCE VM, within the same region an healthcheck ping takes on average
 	// 10ms to finish, given a 5 minutes interval and 10 healthcheck workers, a
 	// healthChecker can effectively mantain
 	// 100 checks_per_worker/sec * 10 workers * 300 seconds = 300K sessions.
-	pool.hc = newHealthChecker(config.HealthCheckInterval, config.HealthCheckWorkers, config.healthCheckSampleInterval, pool)
+	pool.hc = newHealthChecker(config.HealthCheckInterval, config.HealthCheckWorkers, config.healthCheckSampleInterval, config.DisableHealthCheck, pool)
 
 	// First initialize the pool before we indicate that the healthchecker is
 	// ready. This prevents the maintainer from starting before the pool has
@@ -588,8 +718,11 @@ func (p *sessionPool) sessionReady(s *session) {
 	// Set this pool as the home pool of the session and register it with the
 	// health checker.
 	s.pool = p
+	s.createTime = p.now()
+	s.generation = p.generation
 	p.hc.register(s)
 	p.createReqs--
+	p.totalCreated++
 	// Insert the session at a random position in the pool to prevent all
 	// sessions affiliated with a channel to be placed at sequentially in the
 	// pool.
@@ -663,6 +796,14 @@ var errInvalidSessionPool = spannerErrorf(codes.InvalidArgument, "invalid sessio
 // sessionPool.take().
 var errGetSessionTimeout = spannerErrorf(codes.Canceled, "timeout / context canceled during getting session")
 
+// recordAcquisitionLatency records the time that was needed to take a session
+// from the pool since start.
+func (p *sessionPool) recordAcquisitionLatency(ctx context.Context, start time.Time) {
+	d := time.Since(start)
+	p.acquisitionLatency.record(d)
+	recordLatencyStat(ctx, SessionAcquisitionLatency, d)
+}
+
 // newSessionHandle creates a new session handle for the given session for this
 // session pool. The session handle will also hold a copy of the current call
 // stack if the session pool has been configured to track the call stacks of
@@ -678,31 +819,75 @@ func (p *sessionPool) newSessionHandle(s *session) (sh *sessionHandle) {
 	return sh
 }
 
+// ErrSessionPoolExhausted is wrapped by the error that is returned if the
+// deadline of the context of a request is exceeded while the request is
+// waiting for a session, because the session pool has reached
+// SessionPoolConfig.MaxOpened or SessionPoolConfig.MaxBurst. A request whose
+// context is cancelled while waiting returns an error with code Canceled
+// instead. errors.Is(err, ErrSessionPoolExhausted) can be
+// used to distinguish such errors from slow requests, for example to alert on
+// a session pool that is too small. The error code of the error is
+// ResourceExhausted.
+var ErrSessionPoolExhausted error = &sessionPoolExhaustedError{}
+
+// sessionPoolExhaustedError is the type of ErrSessionPoolExhausted.
+type sessionPoolExhaustedError struct{}
+
+func (*sessionPoolExhaustedError) Error() string {
+	return "spanner: session pool exhausted"
+}
+
+// GRPCStatus returns a gRPC status with code ResourceExhausted.
+func (e *sessionPoolExhaustedError) GRPCStatus() *status.Status {
+	return status.New(codes.ResourceExhausted, e.Error())
+}
+
+// errSessionPoolExhausted returns an error that wraps ErrSessionPoolExhausted
+// with the given description.
+func errSessionPoolExhausted(desc string) *Error {
+	return &Error{
+		Code: codes.ResourceExhausted,
+		err:  ErrSessionPoolExhausted,
+		Desc: desc,
+	}
+}
+
 // errGetSessionTimeout returns error for context timeout during
-// sessionPool.take().
-func (p *sessionPool) errGetSessionTimeout() error {
+// sessionPool.take(). ctxErr is the error of the context that is done.
+func (p *sessionPool) errGetSessionTimeout(ctxErr error) error {
 	if p.TrackSessionHandles {
-		return p.errGetSessionTimeoutWithTrackedSessionHandles()
+		return p.errGetSessionTimeoutWithTrackedSessionHandles(ctxErr)
 	}
-	return p.errGetBasicSessionTimeout()
+	return p.errGetBasicSessionTimeout(ctxErr)
 }
 
 // errGetBasicSessionTimeout returns error for context timout during
 // sessionPool.take() without any tracked sessionHandles.
-func (p *sessionPool) errGetBasicSessionTimeout() error {
-	return spannerErrorf(codes.Canceled, "timeout / context canceled during getting session.\n"+
+func (p *sessionPool) errGetBasicSessionTimeout(ctxErr error) error {
+	return errGetSessionContextDone(ctxErr, "timeout / context canceled during getting session.\n"+
 		"Enable SessionPoolConfig.TrackSessionHandles if you suspect a session leak to get more information about the checked out sessions.")
 }
 
 // errGetSessionTimeoutWithTrackedSessionHandles returns error for context
 // timout during sessionPool.take() including a stacktrace of each checked out
 // session handle.
-func (p *sessionPool) errGetSessionTimeoutWithTrackedSessionHandles() error {
-	err := spannerErrorf(codes.Canceled, "timeout / context canceled during getting session.")
-	err.(*Error).additionalInformation = p.getTrackedSessionHandleStacksLocked()
+func (p *sessionPool) errGetSessionTimeoutWithTrackedSessionHandles(ctxErr error) error {
+	err := errGetSessionContextDone(ctxErr, "timeout / context canceled during getting session.")
+	err.additionalInformation = p.getTrackedSessionHandleStacksLocked()
 	return err
 }
 
+// errGetSessionContextDone returns error for a context that is done while
+// waiting for a session. Only an exceeded deadline is reported as an exhausted
+// session pool, as a cancelled context says nothing about the size of the
+// pool.
+func errGetSessionContextDone(ctxErr error, desc string) *Error {
+	if ctxErr == context.DeadlineExceeded {
+		return errSessionPoolExhausted(desc)
+	}
+	return spannerErrorf(codes.Canceled, "%s", desc).(*Error)
+}
+
 // getTrackedSessionHandleStacksLocked returns a string containing the
 // stacktrace of all currently checked out sessions of the pool. This method
 // requires the caller to have locked p.mu.
@@ -727,7 +912,7 @@ func (p *sessionPool) getTrackedSessionHandleStacksLocked() string {
 
 // shouldPrepareWriteLocked returns true if we should prepare more sessions for write.
 func (p *sessionPool) shouldPrepareWriteLocked() bool {
-	return !p.disableBackgroundPrepareSessions && float64(p.numOpened)*p.WriteSessions > float64(p.idleWriteList.Len()+int(p.prepareReqs))
+	return !p.DisableHealthCheck && !p.disableBackgroundPrepareSessions && float64(p.numOpened)*p.WriteSessions > float64(p.idleWriteList.Len()+int(p.prepareReqs))
 }
 
 func (p *sessionPool) createSession(ctx context.Context) (*session, error) {
@@ -756,6 +941,11 @@ func (p *sessionPool) createSession(ctx context.Context) (*session, error) {
 		return nil, err
 	}
 	s.pool = p
+	s.createTime = p.now()
+	p.mu.Lock()
+	s.generation = p.generation
+	p.totalCreated++
+	p.mu.Unlock()
 	p.hc.register(s)
 	doneCreate(true)
 	return s, nil
@@ -779,6 +969,7 @@ func (p *sessionPool) isHealthy(s *session) bool {
 // for read operations.
 func (p *sessionPool) take(ctx context.Context) (*sessionHandle, error) {
 	trace.TracePrintf(ctx, nil, "Acquiring a read-only session")
+	start := time.Now()
 	for {
 		var (
 			s   *session
@@ -814,6 +1005,7 @@ func (p *sessionPool) take(ctx context.Context) (*sessionHandle, error) {
 			if !p.isHealthy(s) {
 				continue
 			}
+			p.recordAcquisitionLatency(ctx, start)
 			return p.newSessionHandle(s), nil
 		}
 
@@ -821,13 +1013,12 @@ func (p *sessionPool) take(ctx context.Context) (*sessionHandle, error) {
 		// creation concurrency or max number of open sessions.
 		if (p.MaxOpened > 0 && p.numOpened >= p.MaxOpened) || (p.MaxBurst > 0 && p.createReqs >= p.MaxBurst) {
 			mayGetSession := p.mayGetSession
+			p.numWaiters++
 			p.mu.Unlock()
 			trace.TracePrintf(ctx, nil, "Waiting for read-only session to become available")
-			select {
-			case <-ctx.Done():
+			if err := p.waitForSession(ctx, mayGetSession); err != nil {
 				trace.TracePrintf(ctx, nil, "Context done waiting for session")
-				return nil, p.errGetSessionTimeout()
-			case <-mayGetSession:
+				return nil, err
 			}
 			continue
 		}
@@ -847,15 +1038,33 @@ func (p *sessionPool) take(ctx context.Context) (*sessionHandle, error) {
 		}
 		trace.TracePrintf(ctx, map[string]interface{}{"sessionID": s.getID()},
 			"Created session")
+		p.recordAcquisitionLatency(ctx, start)
 		return p.newSessionHandle(s), nil
 	}
 }
 
+// waitForSession waits until mayGetSession is closed or ctx is done. The
+// caller must have incremented p.numWaiters before releasing p.mu.
+func (p *sessionPool) waitForSession(ctx context.Context, mayGetSession chan struct{}) error {
+	defer func() {
+		p.mu.Lock()
+		p.numWaiters--
+		p.mu.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return p.errGetSessionTimeout(ctx.Err())
+	case <-mayGetSession:
+		return nil
+	}
+}
+
 // takeWriteSession returns a write prepared cached session if there are
 // available ones; if there isn't any, it tries to allocate a new one. Session
 // returned should be used for read write transactions.
 func (p *sessionPool) takeWriteSession(ctx context.Context) (*sessionHandle, error) {
 	trace.TracePrintf(ctx, nil, "Acquiring a read-write session")
+	start := time.Now()
 	for {
 		var (
 			s   *session
@@ -894,13 +1103,12 @@ func (p *sessionPool) takeWriteSession(ctx context.Context) (*sessionHandle, err
 			// creation concurrency or max number of open sessions.
 			if (p.MaxOpened > 0 && p.numOpened >= p.MaxOpened) || (p.MaxBurst > 0 && p.createReqs >= p.MaxBurst) {
 				mayGetSession := p.mayGetSession
+				p.numWaiters++
 				p.mu.Unlock()
 				trace.TracePrintf(ctx, nil, "Waiting for read-write session to become available")
-				select {
-				case <-ctx.Done():
+				if err := p.waitForSession(ctx, mayGetSession); err != nil {
 					trace.TracePrintf(ctx, nil, "Context done waiting for session")
-					return nil, p.errGetSessionTimeout()
-				case <-mayGetSession:
+					return nil, err
 				}
 				continue
 			}
@@ -929,6 +1137,7 @@ func (p *sessionPool) takeWriteSession(ctx context.Context) (*sessionHandle, err
 				return nil, toSpannerError(err)
 			}
 		}
+		p.recordAcquisitionLatency(ctx, start)
 		return p.newSessionHandle(s), nil
 	}
 }
@@ -938,8 +1147,9 @@ func (p *sessionPool) takeWriteSession(ctx context.Context) (*sessionHandle, err
 func (p *sessionPool) recycle(s *session) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if !s.isValid() || !p.valid {
-		// Reject the session if session is invalid or pool itself is invalid.
+	if !s.isValid() || !p.valid || s.generation != p.generation {
+		// Reject the session if session is invalid, stale or pool itself is
+		// invalid.
 		return false
 	}
 	// Put session at the top of the list to be handed out in LIFO order for load balancing
@@ -961,6 +1171,155 @@ func (p *sessionPool) recycle(s *session) bool {
 func (p *sessionPool) remove(s *session, isExpire bool) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.removeLocked(s, isExpire)
+}
+
+// removeIfTooOld atomically removes session s from the session pool if it is
+// idle and older than MaxSessionAge. It returns true if the session was
+// removed.
+func (p *sessionPool) removeIfTooOld(s *session) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.MaxSessionAge <= 0 || s.getIdleList() == nil {
+		return false
+	}
+	if p.now().Sub(s.createTime) < p.MaxSessionAge {
+		return false
+	}
+	return p.removeLocked(s, false)
+}
+
+// recordStaleSessionError records that Cloud Spanner returned `Session not
+// found` or Unavailable for a session of the pool that was created in the
+// given generation of the pool. If this happened StaleSessionThreshold times
+// in a row, all sessions of the pool are considered stale. The idle sessions
+// are then removed from the pool and replaced by new sessions. Errors of
+// sessions of an older generation are ignored, as these sessions have already
+// been replaced.
+func (p *sessionPool) recordStaleSessionError(generation uint64) {
+	p.mu.Lock()
+	if p.StaleSessionThreshold == 0 || !p.valid || generation != p.generation {
+		p.mu.Unlock()
+		return
+	}
+	p.staleSessionErrors++
+	if p.staleSessionErrors < p.StaleSessionThreshold {
+		p.mu.Unlock()
+		return
+	}
+	p.staleSessionErrors = 0
+	p.generation++
+	var stale []*session
+	for _, l := range []*list.List{&p.idleList, &p.idleWriteList} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			stale = append(stale, e.Value.(*session))
+		}
+	}
+	for _, s := range stale {
+		p.removeLocked(s, false)
+	}
+	minOpened := p.MinOpened
+	p.mu.Unlock()
+	logf(p.sc.logger, "Received %d consecutive `Session not found` or Unavailable errors, replacing all sessions in the pool", p.StaleSessionThreshold)
+	p.hc.replaceStaleSessions(stale, minOpened)
+}
+
+// recordUnavailable records an Unavailable error of an RPC on the given gRPC
+// connection as a stale session error of the current generation of the pool.
+// Errors on connections that are no longer used for new sessions are ignored.
+func (p *sessionPool) recordUnavailable(cc *grpc.ClientConn) {
+	if p.StaleSessionThreshold == 0 || !p.sc.usesConnection(cc) {
+		return
+	}
+	p.mu.Lock()
+	generation := p.generation
+	p.mu.Unlock()
+	p.recordStaleSessionError(generation)
+}
+
+// staleSessionDetector reports the Unavailable errors of the RPCs of a client
+// to its session pool, see SessionPoolConfig.StaleSessionThreshold. The pool
+// is set after the gRPC connections of the client have been dialed.
+type staleSessionDetector struct {
+	mu   sync.Mutex
+	pool *sessionPool
+}
+
+// setPool sets the session pool that the errors are reported to.
+func (d *staleSessionDetector) setPool(p *sessionPool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pool = p
+}
+
+// record reports err to the session pool if it is an Unavailable error.
+func (d *staleSessionDetector) record(cc *grpc.ClientConn, err error) {
+	if status.Code(err) != codes.Unavailable {
+		return
+	}
+	d.mu.Lock()
+	p := d.pool
+	d.mu.Unlock()
+	if p != nil {
+		p.recordUnavailable(cc)
+	}
+}
+
+// unaryInterceptor returns a gRPC unary interceptor that reports the
+// Unavailable errors of each attempt of an RPC.
+func (d *staleSessionDetector) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		d.record(cc, err)
+		return err
+	}
+}
+
+// streamInterceptor returns a gRPC stream interceptor that reports the
+// Unavailable errors of each attempt of a streaming RPC.
+func (d *staleSessionDetector) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			d.record(cc, err)
+			return nil, err
+		}
+		return &detectedClientStream{ClientStream: stream, detector: d, cc: cc}, nil
+	}
+}
+
+// detectedClientStream reports the error of a stream to a
+// staleSessionDetector.
+type detectedClientStream struct {
+	grpc.ClientStream
+	detector *staleSessionDetector
+	cc       *grpc.ClientConn
+}
+
+func (s *detectedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil && err != io.EOF {
+		s.detector.record(s.cc, err)
+	}
+	return err
+}
+
+// resetStaleSessionErrors resets the number of consecutive stale session
+// errors when a session is returned to the pool without such an error.
+func (p *sessionPool) resetStaleSessionErrors() {
+	// The errors are not counted if the threshold is not set, so there is
+	// no need to take the lock of the pool.
+	if p.StaleSessionThreshold == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.staleSessionErrors = 0
+}
+
+// removeLocked removes session s from the session pool. The caller must hold
+// p.mu.
+func (p *sessionPool) removeLocked(s *session, isExpire bool) bool {
 	if isExpire && (p.numOpened <= p.MinOpened || s.getIdleList() == nil) {
 		// Don't expire session if the session is not in idle list (in use), or
 		// if number of open sessions is going below p.MinOpened.
@@ -976,6 +1335,7 @@ func (p *sessionPool) remove(s *session, isExpire bool) bool {
 	if s.invalidate() {
 		// Decrease the number of opened sessions.
 		p.numOpened--
+		p.totalDeleted++
 		recordStat(context.Background(), OpenSessionCount, int64(p.numOpened))
 		// Broadcast that a session has been destroyed.
 		close(p.mayGetSession)
@@ -985,10 +1345,70 @@ func (p *sessionPool) remove(s *session, isExpire bool) bool {
 	return false
 }
 
+// now returns the current time according to the clock of the session pool.
+func (p *sessionPool) now() time.Time {
+	if p.clock != nil {
+		return p.clock()
+	}
+	return time.Now()
+}
+
 func (p *sessionPool) currSessionsCheckedOutLocked() uint64 {
 	return p.numOpened - uint64(p.idleList.Len()) - uint64(p.idleWriteList.Len())
 }
 
+// SessionPoolStats is a snapshot of the state of a session pool. See
+// Client.PoolStats.
+type SessionPoolStats struct {
+	// NumSessions is the number of sessions that are currently open in the
+	// pool, both idle and in use.
+	NumSessions uint64
+	// NumInUse is the number of sessions that are currently checked out of
+	// the pool.
+	NumInUse uint64
+	// NumIdle is the number of sessions that are available in the pool.
+	NumIdle uint64
+	// NumCreating is the number of sessions that are being created.
+	NumCreating uint64
+	// MaxSessions is the maximum number of sessions that the pool may open.
+	// It is 0 if the number of sessions is unlimited.
+	MaxSessions uint64
+	// NumWaiters is the number of callers that are waiting for a session to
+	// become available, because the pool has reached MaxSessions or the
+	// maximum number of concurrent session creations.
+	NumWaiters uint64
+	// TotalSessionsCreated is the total number of sessions that have been
+	// created by the pool since it was created.
+	TotalSessionsCreated uint64
+	// TotalSessionsDeleted is the total number of sessions that have been
+	// removed from the pool since it was created, for example because they
+	// were too old, were no longer found on the server or the pool shrank.
+	TotalSessionsDeleted uint64
+}
+
+// stats returns a consistent snapshot of the state of the pool.
+func (p *sessionPool) stats() SessionPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	idle := uint64(p.idleList.Len() + p.idleWriteList.Len())
+	// numOpened includes the sessions that are being created.
+	open := p.numOpened - p.createReqs
+	var inUse uint64
+	if open > idle {
+		inUse = open - idle
+	}
+	return SessionPoolStats{
+		NumSessions:          open,
+		NumInUse:             inUse,
+		NumIdle:              idle,
+		NumCreating:          p.createReqs,
+		MaxSessions:          p.MaxOpened,
+		NumWaiters:           p.numWaiters,
+		TotalSessionsCreated: p.totalCreated,
+		TotalSessionsDeleted: p.totalDeleted,
+	}
+}
+
 // hcHeap implements heap.Interface. It is used to create the priority queue for
 // session healthchecks.
 type hcHeap struct {
@@ -1128,10 +1548,14 @@ type healthChecker struct {
 }
 
 // newHealthChecker initializes new instance of healthChecker.
-func newHealthChecker(interval time.Duration, workers int, sampleInterval time.Duration, pool *sessionPool) *healthChecker {
+func newHealthChecker(interval time.Duration, workers int, sampleInterval time.Duration, disabled bool, pool *sessionPool) *healthChecker {
 	if workers <= 0 {
 		workers = 1
 	}
+	if disabled {
+		// No workers are started, and close only waits for the maintainer.
+		workers = 0
+	}
 	hc := &healthChecker{
 		interval:         interval,
 		workers:          workers,
@@ -1169,6 +1593,40 @@ func (hc *healthChecker) isClosing() bool {
 	}
 }
 
+// replaceStaleSessions reconnects the session client if its endpoint has
+// changed, deletes the given stale sessions and grows the pool to minOpened
+// sessions. The work is done in the background by a goroutine that is
+// tracked like the healthcheck workers, and that stops when the
+// healthChecker is closed.
+func (hc *healthChecker) replaceStaleSessions(stale []*session, minOpened uint64) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.isClosing() {
+		return
+	}
+	hc.waitWorkers.Add(1)
+	go func() {
+		defer hc.waitWorkers.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		go func() {
+			select {
+			case <-hc.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if err := hc.pool.sc.reconnect(ctx); err != nil {
+			logf(hc.pool.sc.logger, "Failed to reconnect to the endpoint of the database, error: %v", err)
+		}
+		for _, s := range stale {
+			hc.unregister(s)
+			s.delete(ctx)
+		}
+		hc.growPool(ctx, minOpened)
+	}()
+}
+
 // getInterval gets the healthcheck interval.
 func (hc *healthChecker) getInterval() time.Duration {
 	hc.mu.Lock()
@@ -1230,6 +1688,17 @@ func (hc *healthChecker) healthCheck(s *session) {
 		s.destroy(false)
 		return
 	}
+	if s.destroyIfTooOld() {
+		// The session exceeded the max session age. Replace it with a new
+		// session.
+		hc.pool.mu.Lock()
+		numOpened := hc.pool.numOpened
+		hc.pool.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		hc.growPool(ctx, numOpened+1)
+		return
+	}
 	if err := s.ping(); shouldDropSession(err) {
 		// Ping failed, destroy the session.
 		s.destroy(false)
@@ -1397,14 +1866,14 @@ func (hc *healthChecker) growPool(ctx context.Context, growToNumSessions uint64)
 	hc.pool.mu.Unlock()
 	var created int
 	for {
-		if ctx.Err() != nil {
+		if ctx.Err() != nil || hc.isClosing() {
 			return
 		}
 
 		p := hc.pool
 		p.mu.Lock()
 		// Take budget before the actual session creation.
-		if growToNumSessions <= p.numOpened || created >= maxSessionsToCreate {
+		if !p.valid || growToNumSessions <= p.numOpened || created >= maxSessionsToCreate {
 			p.mu.Unlock()
 			break
 		}
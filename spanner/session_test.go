@@ -19,6 +19,7 @@ package spanner
 import (
 	"bytes"
 	"container/heap"
+	"container/list"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -26,6 +27,7 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -77,6 +79,12 @@ func TestSessionPoolConfigValidation(t *testing.T) {
 			},
 			errHealthCheckIntervalNegative(-time.Second),
 		},
+		{
+			SessionPoolConfig{
+				MaxSessionAge: -time.Second,
+			},
+			errMaxSessionAgeNegative(-time.Second),
+		},
 	} {
 		if _, err := newSessionPool(client.sc, test.spc); !testEqual(err, test.err) {
 			t.Fatalf("want %v, got %v", test.err, err)
@@ -532,7 +540,7 @@ func TestSessionLeak(t *testing.T) {
 	single2 := client.Single()
 	iter2 := single2.Query(ctxWithTimeout, NewStatement(SelectFooFromBar))
 	_, gotErr := iter2.Next()
-	wantErr := client.idleSessions.errGetSessionTimeoutWithTrackedSessionHandles()
+	wantErr := client.idleSessions.errGetSessionTimeoutWithTrackedSessionHandles(context.DeadlineExceeded)
 	// The error should contain the stacktraces of all the checked out
 	// sessions.
 	if !testEqual(gotErr, wantErr) {
@@ -568,9 +576,16 @@ func TestMaxOpenedSessions(t *testing.T) {
 	ctx2, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
 	defer cancel()
 	_, gotErr := sp.take(ctx2)
-	if wantErr := sp.errGetBasicSessionTimeout(); !testEqual(gotErr, wantErr) {
+	if wantErr := sp.errGetBasicSessionTimeout(context.DeadlineExceeded); !testEqual(gotErr, wantErr) {
 		t.Fatalf("the second session retrival returns error %v, want %v", gotErr, wantErr)
 	}
+	if g, w := ErrCode(gotErr), codes.ResourceExhausted; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var exhausted *sessionPoolExhaustedError
+	if !errorAs(gotErr, &exhausted) || exhausted != ErrSessionPoolExhausted {
+		t.Fatalf("error does not wrap ErrSessionPoolExhausted: %v", gotErr)
+	}
 	doneWaiting := make(chan struct{})
 	go func() {
 		// Destroy the first session to allow the next session request to
@@ -597,6 +612,44 @@ func TestMaxOpenedSessions(t *testing.T) {
 	}
 }
 
+// TestMaxOpenedSessionsCancelled tests that a request that is cancelled while
+// waiting for a session is not reported as an exhausted session pool.
+func TestMaxOpenedSessionsCancelled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MaxOpened: 1,
+			},
+		})
+	defer teardown()
+	sp := client.idleSessions
+
+	sh1, err := sp.take(ctx)
+	if err != nil {
+		t.Fatalf("cannot take session from session pool: %v", err)
+	}
+	defer sh1.recycle()
+
+	ctx2, cancel := context.WithCancel(ctx)
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		cancel()
+	}()
+	_, gotErr := sp.take(ctx2)
+	if wantErr := sp.errGetBasicSessionTimeout(context.Canceled); !testEqual(gotErr, wantErr) {
+		t.Fatalf("the second session retrival returns error %v, want %v", gotErr, wantErr)
+	}
+	if g, w := ErrCode(gotErr), codes.Canceled; g != w {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var exhausted *sessionPoolExhaustedError
+	if errorAs(gotErr, &exhausted) {
+		t.Fatalf("error unexpectedly wraps ErrSessionPoolExhausted: %v", gotErr)
+	}
+}
+
 // TestMinOpenedSessions tests min open session constraint.
 func TestMinOpenedSessions(t *testing.T) {
 	t.Parallel()
@@ -701,7 +754,7 @@ func TestMaxBurst(t *testing.T) {
 	_, gotErr := sp.take(ctx2)
 
 	// Since MaxBurst == 1, the second session request should block.
-	if wantErr := sp.errGetBasicSessionTimeout(); !testEqual(gotErr, wantErr) {
+	if wantErr := sp.errGetBasicSessionTimeout(context.DeadlineExceeded); !testEqual(gotErr, wantErr) {
 		t.Fatalf("session retrival returns error %v, want %v", gotErr, wantErr)
 	}
 
@@ -1204,6 +1257,146 @@ waitForPrepare:
 	sh.recycle()
 }
 
+// TestSessionPoolStaleSessionThreshold verifies that the session pool replaces
+// all its sessions after StaleSessionThreshold consecutive `Session not found`
+// errors, instead of failing once for every stale session in the pool.
+func TestSessionPoolStaleSessionThreshold(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MinOpened:             10,
+				MaxOpened:             10,
+				StaleSessionThreshold: 3,
+			},
+			logger: log.New(ioutil.Discard, "", log.LstdFlags),
+		})
+	defer teardown()
+	sp := client.idleSessions
+	waitFor(t, func() error {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		if g, w := uint64(sp.idleList.Len()), sp.MinOpened; g != w {
+			return fmt.Errorf("num open sessions mismatch\nGot: %d\nWant: %d", g, w)
+		}
+		return nil
+	})
+	oldSessions := server.TestSpanner.DumpSessions()
+
+	// Simulate a switch to an endpoint that does not know the sessions.
+	server.TestSpanner.DropAllSessions()
+	var failures int
+	for ; failures < 10; failures++ {
+		err := client.Single().Query(ctx, NewStatement(SelectFooFromBar)).Do(func(*Row) error { return nil })
+		if err == nil {
+			break
+		}
+		if !isSessionNotFoundError(err) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if g, w := failures, 3; g != w {
+		t.Fatalf("number of failed queries mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The pool should be refilled with new sessions.
+	waitFor(t, func() error {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		if g, w := sp.numOpened, sp.MinOpened; g != w {
+			return fmt.Errorf("num open sessions mismatch\nGot: %d\nWant: %d", g, w)
+		}
+		for e := sp.idleList.Front(); e != nil; e = e.Next() {
+			if id := e.Value.(*session).getID(); oldSessions[id] {
+				return fmt.Errorf("stale session %v is still in the pool", id)
+			}
+		}
+		return nil
+	})
+}
+
+// TestSessionPoolStaleSessionThresholdEndpointSwitch verifies that the session
+// pool connects to the new endpoint that is returned by the EndpointResolver
+// after StaleSessionThreshold consecutive Unavailable errors, and that queries
+// succeed again after a bounded number of failures.
+func TestSessionPoolStaleSessionThresholdEndpointSwitch(t *testing.T) {
+	t.Parallel()
+	serverOld, optsOld, serverTeardownOld := NewMockedSpannerInMemTestServer(t)
+	defer serverTeardownOld()
+	serverNew, optsNew, serverTeardownNew := NewMockedSpannerInMemTestServer(t)
+	defer serverTeardownNew()
+	oldEndpoint := fmt.Sprintf("%s", optsOld[0])
+	newEndpoint := fmt.Sprintf("%s", optsNew[0])
+
+	var mu sync.Mutex
+	endpoint := oldEndpoint
+	var resolved []string
+	ctx := context.Background()
+	formattedDatabase := fmt.Sprintf("projects/%s/instances/%s/databases/%s", "some-project", "some-instance", "some-database")
+	client, err := NewClientWithConfig(ctx, formattedDatabase, ClientConfig{
+		SessionPoolConfig: SessionPoolConfig{
+			MinOpened:             2,
+			MaxOpened:             10,
+			StaleSessionThreshold: 3,
+		},
+		EndpointResolver: func(string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			resolved = append(resolved, endpoint)
+			return endpoint, nil
+		},
+		logger: log.New(ioutil.Discard, "", log.LstdFlags),
+	}, optsOld...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a failover: the old endpoint becomes unavailable, and the
+	// resolver returns the new endpoint.
+	serverOld.TestSpanner.PutExecutionTime(MethodExecuteStreamingSql, SimulatedExecutionTime{
+		Errors:    []error{status.Error(codes.Unavailable, "endpoint is gone")},
+		KeepError: true,
+	})
+	mu.Lock()
+	endpoint = newEndpoint
+	mu.Unlock()
+	drainRequestsFromServer(serverNew.TestSpanner)
+
+	var failures int
+	for ; failures < 10; failures++ {
+		queryCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		err := executeSingerQuery(queryCtx, client.Single())
+		cancel()
+		if err == nil {
+			break
+		}
+		if g, w := ErrCode(err), codes.DeadlineExceeded; g != w {
+			t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	}
+	if failures > 2 {
+		t.Fatalf("too many failed queries before recovery\nGot: %v\nWant: <= %v", failures, 2)
+	}
+	mu.Lock()
+	if g, w := resolved, []string{oldEndpoint, newEndpoint}; !testEqual(g, w) {
+		t.Errorf("resolved endpoints mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	mu.Unlock()
+	var executed bool
+	for _, req := range drainRequestsFromServer(serverNew.TestSpanner) {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			executed = true
+		}
+	}
+	if !executed {
+		t.Fatal("query was not executed on the new endpoint")
+	}
+}
+
 // TestSessionHealthCheck tests healthchecking cases.
 func TestSessionHealthCheck(t *testing.T) {
 	t.Parallel()
@@ -1269,6 +1462,166 @@ func TestSessionHealthCheck(t *testing.T) {
 	}
 }
 
+func TestSessionHealthCheckDisabled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MinOpened:                 5,
+				WriteSessions:             0.5,
+				HealthCheckInterval:       time.Nanosecond,
+				healthCheckSampleInterval: 10 * time.Millisecond,
+				DisableHealthCheck:        true,
+			},
+		})
+	sp := client.idleSessions
+	if g, w := sp.hc.workers, 0; g != w {
+		t.Fatalf("number of health check workers mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// The maintainer still creates MinOpened sessions.
+	waitFor(t, func() error {
+		if g, w := sp.stats().NumIdle, uint64(5); g != w {
+			return fmt.Errorf("number of idle sessions mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+	if pings := server.TestSpanner.DumpPings(); len(pings) != 0 {
+		t.Fatalf("got %v pings, want none", len(pings))
+	}
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if _, ok := req.(*sppb.BeginTransactionRequest); ok {
+			t.Fatal("session was prepared for write in the background")
+		}
+	}
+	// Sessions are checked lazily when they are taken from the pool.
+	sh, err := sp.take(ctx)
+	if err != nil {
+		t.Fatalf("cannot get session from session pool: %v", err)
+	}
+	if pings := server.TestSpanner.DumpPings(); len(pings) != 1 || pings[0] != sh.getID() {
+		t.Fatalf("pings mismatch\nGot: %v\nWant: [%v]", pings, sh.getID())
+	}
+	sh.recycle()
+
+	// Closing the client does not wait for health check workers.
+	closed := make(chan struct{})
+	go func() {
+		teardown()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("client was not closed within 10 seconds")
+	}
+}
+
+// fakeClock is a clock that only moves when it is advanced manually.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestSessionPoolMaxSessionAge tests that idle sessions that are older than
+// MaxSessionAge are replaced by new sessions.
+func TestSessionPoolMaxSessionAge(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Now()}
+	server, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MinOpened:                 3,
+				HealthCheckInterval:       time.Nanosecond,
+				MaxSessionAge:             time.Hour,
+				healthCheckSampleInterval: 10 * time.Millisecond,
+				clock:                     clock.Now,
+			},
+		})
+	defer teardown()
+	sp := client.idleSessions
+
+	idleSessions := func() map[*session]bool {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		sessions := make(map[*session]bool)
+		for _, l := range []*list.List{&sp.idleList, &sp.idleWriteList} {
+			for e := l.Front(); e != nil; e = e.Next() {
+				sessions[e.Value.(*session)] = true
+			}
+		}
+		return sessions
+	}
+	waitFor(t, func() error {
+		if g, w := len(idleSessions()), 3; g != w {
+			return fmt.Errorf("idle sessions mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
+	// Sessions that are in use should not be recycled.
+	sh, err := sp.take(ctx)
+	if err != nil {
+		t.Fatalf("cannot get session from session pool: %v", err)
+	}
+	defer sh.recycle()
+	var oldSessions map[*session]bool
+	waitFor(t, func() error {
+		oldSessions = idleSessions()
+		if g, w := len(oldSessions), 2; g != w {
+			return fmt.Errorf("idle sessions mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
+	// No sessions should be recycled before they reach the max age.
+	clock.advance(59 * time.Minute)
+	time.Sleep(50 * time.Millisecond)
+	for s := range oldSessions {
+		if !s.isValid() {
+			t.Fatalf("session %v was recycled before it reached the max age", s.getID())
+		}
+	}
+
+	clock.advance(2 * time.Minute)
+	waitFor(t, func() error {
+		for s := range oldSessions {
+			if s.isValid() {
+				return fmt.Errorf("session %v has not been recycled", s.getID())
+			}
+			if _, ok := server.TestSpanner.DumpSessions()[s.getID()]; ok {
+				return fmt.Errorf("session %v has not been deleted", s.getID())
+			}
+		}
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		if g, w := sp.numOpened, uint64(3); g != w {
+			return fmt.Errorf("open sessions mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
+	if !sh.session.isValid() {
+		t.Fatalf("session %v was recycled while it was in use", sh.getID())
+	}
+	for s := range idleSessions() {
+		if oldSessions[s] {
+			t.Fatalf("session %v is still in the pool", s.getID())
+		}
+	}
+}
+
 // TestStressSessionPool does stress test on session pool by the following concurrent operations:
 //	1) Test worker gets a session from the pool.
 //	2) Test worker turns a session back into the pool.
@@ -1785,3 +2138,122 @@ func TestMaintenanceWindow_CycleAndUpdateMaxCheckedOut(t *testing.T) {
 		t.Fatalf("Max sessions checked out during window mismatch.\nGot: %d\nWant: %d", g, w)
 	}
 }
+
+func TestSessionPoolAcquisitionLatency(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MinOpened: 1,
+				MaxOpened: 1,
+			},
+		})
+	defer teardown()
+	sp := client.idleSessions
+
+	sh, err := sp.take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pool is exhausted. The next take must wait until the session has
+	// been returned to the pool.
+	wait := 100 * time.Millisecond
+	go func() {
+		<-time.After(wait)
+		sh.recycle()
+	}()
+	sh, err = sp.take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh.recycle()
+
+	got := client.SessionAcquisitionLatency()
+	if g, w := got.Total(), uint64(2); g != w {
+		t.Fatalf("number of acquisitions mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := len(got.Counts), len(got.Bounds)+1; g != w {
+		t.Fatalf("number of buckets mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// Counts[i] contains the latencies that are at least Bounds[i-1].
+	var slow uint64
+	for i, c := range got.Counts {
+		if i > 0 && got.Bounds[i-1] >= wait/2 {
+			slow += c
+		}
+	}
+	if g, w := slow, uint64(1); g != w {
+		t.Fatalf("number of slow acquisitions mismatch\nGot: %v\nWant: %v\nHistogram: %v", g, w, got)
+	}
+}
+
+func TestSessionPoolStats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServerWithConfig(t,
+		ClientConfig{
+			SessionPoolConfig: SessionPoolConfig{
+				MinOpened: 2,
+				MaxOpened: 2,
+			},
+		})
+	defer teardown()
+	sp := client.idleSessions
+	checkStats := func(want SessionPoolStats) {
+		t.Helper()
+		waitFor(t, func() error {
+			if got := client.PoolStats(); !testEqual(got, want) {
+				return fmt.Errorf("pool stats mismatch\nGot: %+v\nWant: %+v", got, want)
+			}
+			return nil
+		})
+	}
+	checkStats(SessionPoolStats{NumSessions: 2, NumIdle: 2, MaxSessions: 2, TotalSessionsCreated: 2})
+
+	sh1, err := sp.take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh2, err := sp.take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(SessionPoolStats{NumSessions: 2, NumInUse: 2, MaxSessions: 2, TotalSessionsCreated: 2})
+
+	// The pool is exhausted, and the next caller has to wait.
+	taken := make(chan *sessionHandle)
+	go func() {
+		sh, err := sp.take(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		taken <- sh
+	}()
+	checkStats(SessionPoolStats{NumSessions: 2, NumInUse: 2, MaxSessions: 2, NumWaiters: 1, TotalSessionsCreated: 2})
+
+	// Destroying a session allows the waiter to create a new session.
+	sh1.destroy()
+	sh3 := <-taken
+	checkStats(SessionPoolStats{NumSessions: 2, NumInUse: 2, MaxSessions: 2, TotalSessionsCreated: 3, TotalSessionsDeleted: 1})
+
+	// Recycling sessions does not reset the counters.
+	sh2.recycle()
+	sh3.recycle()
+	checkStats(SessionPoolStats{NumSessions: 2, NumIdle: 2, MaxSessions: 2, TotalSessionsCreated: 3, TotalSessionsDeleted: 1})
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+	for _, d := range []time.Duration{0, time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, time.Second} {
+		h.record(d)
+	}
+	got := h.snapshot()
+	want := LatencyHistogram{
+		Bounds: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+		Counts: []uint64{1, 2, 2},
+	}
+	if !testEqual(got, want) {
+		t.Fatalf("histogram mismatch\nGot: %v\nWant: %v", got, want)
+	}
+}
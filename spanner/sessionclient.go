@@ -26,6 +26,7 @@ import (
 	"cloud.google.com/go/internal/trace"
 	vkit "cloud.google.com/go/spanner/apiv1"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
@@ -63,6 +64,24 @@ type sessionClient struct {
 	md            metadata.MD
 	batchTimeout  time.Duration
 	logger        *log.Logger
+
+	// isRetryableStreamError is copied to the sessions that are created by
+	// the session client. See ClientConfig.IsRetryableStreamError.
+	isRetryableStreamError func(error) bool
+	// retryGate is shared by the sessions that are created by the session
+	// client, and coordinates the server retry delays of their streaming
+	// reads and queries.
+	retryGate *retryGate
+
+	// redial resolves the endpoint of the client again, and dials new gapic
+	// clients if the endpoint has changed. It returns no clients if the
+	// endpoint has not changed. It is nil if the endpoint of the client is
+	// not resolved by a ClientConfig.EndpointResolver.
+	redial func(ctx context.Context) ([]*vkit.Client, error)
+	// retiredClients are the gapic clients that have been replaced by
+	// reconnect. They are closed when the session client is closed, as
+	// sessions that are still in use may still use them.
+	retiredClients []*vkit.Client
 }
 
 // newSessionClient creates a session client to use for a database.
@@ -74,6 +93,7 @@ func newSessionClient(gapicClients []*vkit.Client, database string, sessionLabel
 		md:            md,
 		batchTimeout:  time.Minute,
 		logger:        logger,
+		retryGate:     newRetryGate(),
 	}
 }
 
@@ -82,7 +102,7 @@ func (sc *sessionClient) close() error {
 	defer sc.mu.Unlock()
 	sc.closed = true
 	var errs []error
-	for _, gpc := range sc.gapicClients {
+	for _, gpc := range append(sc.gapicClients, sc.retiredClients...) {
 		if err := gpc.Close(); err != nil {
 			errs = append(errs, err)
 		}
@@ -97,6 +117,43 @@ func (sc *sessionClient) close() error {
 	}
 }
 
+// reconnect replaces the gapic clients of the session client with clients
+// that are connected to the current endpoint of the database, if the endpoint
+// has changed. Sessions that are created after reconnect use the new clients.
+func (sc *sessionClient) reconnect(ctx context.Context) error {
+	if sc.redial == nil {
+		return nil
+	}
+	clients, err := sc.redial(ctx)
+	if err != nil || len(clients) == 0 {
+		return err
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		for _, gpc := range clients {
+			gpc.Close()
+		}
+		return nil
+	}
+	sc.retiredClients = append(sc.retiredClients, sc.gapicClients...)
+	sc.gapicClients = clients
+	return nil
+}
+
+// usesConnection returns true if cc is the connection of one of the gapic
+// clients that are used for new sessions.
+func (sc *sessionClient) usesConnection(cc *grpc.ClientConn) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, gpc := range sc.gapicClients {
+		if gpc.Connection() == cc {
+			return true
+		}
+	}
+	return false
+}
+
 // createSession creates one session for the database of the sessionClient. The
 // session is created using one synchronous RPC.
 func (sc *sessionClient) createSession(ctx context.Context) (*session, error) {
@@ -114,7 +171,7 @@ func (sc *sessionClient) createSession(ctx context.Context) (*session, error) {
 	if err != nil {
 		return nil, toSpannerError(err)
 	}
-	return &session{valid: true, client: client, id: sid.Name, createTime: time.Now(), md: sc.md, logger: sc.logger}, nil
+	return &session{valid: true, client: client, id: sid.Name, createTime: time.Now(), md: sc.md, logger: sc.logger, isRetryableStreamError: sc.isRetryableStreamError, retryGate: sc.retryGate}, nil
 }
 
 // batchCreateSessions creates a batch of sessions for the database of the
@@ -131,16 +188,16 @@ func (sc *sessionClient) batchCreateSessions(createSessionCount int32, consumer
 	// The sessions that we create should be evenly distributed over all the
 	// channels (gapic clients) that are used by the client. Each gapic client
 	// will do a request for a fraction of the total.
-	sessionCountPerChannel := createSessionCount / int32(len(sc.gapicClients))
-	// The remainder of the calculation will be added to the number of sessions
-	// that will be created for the first channel, to ensure that we create the
-	// exact number of requested sessions.
-	remainder := createSessionCount % int32(len(sc.gapicClients))
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	if sc.closed {
 		return spannerErrorf(codes.FailedPrecondition, "SessionClient is closed")
 	}
+	sessionCountPerChannel := createSessionCount / int32(len(sc.gapicClients))
+	// The remainder of the calculation will be added to the number of sessions
+	// that will be created for the first channel, to ensure that we create the
+	// exact number of requested sessions.
+	remainder := createSessionCount % int32(len(sc.gapicClients))
 	// Spread the session creation over all available gRPC channels. Spanner
 	// will maintain server side caches for a session on the gRPC channel that
 	// is used by the session. A session should therefore always use the same
@@ -175,7 +232,7 @@ func (sc *sessionClient) executeBatchCreateSessions(client *vkit.Client, createC
 	defer cancel()
 	ctx = contextWithOutgoingMetadata(ctx, sc.md)
 
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.BatchCreateSessions")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.BatchCreateSessions")
 	defer func() { trace.EndSpan(ctx, nil) }()
 	trace.TracePrintf(ctx, nil, "Creating a batch of %d sessions", createCount)
 	remainingCreateCount := createCount
@@ -207,7 +264,7 @@ func (sc *sessionClient) executeBatchCreateSessions(client *vkit.Client, createC
 		actuallyCreated := int32(len(response.Session))
 		trace.TracePrintf(ctx, nil, "Received a batch of %d sessions", actuallyCreated)
 		for _, s := range response.Session {
-			consumer.sessionReady(&session{valid: true, client: client, id: s.Name, createTime: time.Now(), md: md, logger: sc.logger})
+			consumer.sessionReady(&session{valid: true, client: client, id: s.Name, createTime: time.Now(), md: md, logger: sc.logger, isRetryableStreamError: sc.isRetryableStreamError, retryGate: sc.retryGate})
 		}
 		if actuallyCreated < remainingCreateCount {
 			// Spanner could return less sessions than requested. In that case, we
@@ -223,7 +280,7 @@ func (sc *sessionClient) executeBatchCreateSessions(client *vkit.Client, createC
 func (sc *sessionClient) sessionWithID(id string) *session {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
-	return &session{valid: true, client: sc.rrNextGapicClientLocked(), id: id, createTime: time.Now(), md: sc.md, logger: sc.logger}
+	return &session{valid: true, client: sc.rrNextGapicClientLocked(), id: id, createTime: time.Now(), md: sc.md, logger: sc.logger, isRetryableStreamError: sc.isRetryableStreamError, retryGate: sc.retryGate}
 }
 
 // rrNextGapicClientLocked returns the next gRPC client to use for session creation. The
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// SessionLease holds a session from the session pool of a client for a
+// sequence of single-use reads and queries or read-write transactions. This
+// avoids taking a session from the pool and returning it for each read or
+// transaction, which can be noticeable in tight loops of small reads and
+// transactions. The session is returned to the pool by Release.
+//
+// A session can only execute one read, query or transaction at a time, so
+// the reads and transactions that use a lease must be executed sequentially:
+// a RowIterator must be stopped before the next read or query is started, and
+// ReadWriteTransaction must not be called concurrently on the same lease.
+//
+// Release should be deferred directly after the lease has been taken, so that
+// the session is returned to the pool even if the code that uses the lease
+// panics. A session that is never released cannot be used by other
+// transactions of the client, and counts towards the maximum number of
+// sessions of the session pool.
+type SessionLease struct {
+	mu       sync.Mutex
+	c        *Client
+	sp       *sessionPool
+	sh       *sessionHandle
+	tb       TimestampBound
+	released bool
+}
+
+// errSessionLeaseReleased returns error for using a session lease that has
+// been released.
+func errSessionLeaseReleased() error {
+	return spannerErrorf(codes.FailedPrecondition, "session lease has been released")
+}
+
+// LeaseSession takes a session from the session pool of the client and holds
+// it until Release is called on the returned lease. The caller must call
+// Release when the lease is no longer needed.
+func (c *Client) LeaseSession(ctx context.Context) (*SessionLease, error) {
+	sh, err := c.idleSessions.take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionLease{c: c, sp: c.idleSessions, sh: sh, tb: c.defaultSingleBound}, nil
+}
+
+// Single returns a single-use read-only transaction that executes its read or
+// query on the leased session. See Client.Single for more details.
+func (l *SessionLease) Single() *ReadOnlyTransaction {
+	t := &ReadOnlyTransaction{singleUse: true, sp: l.sp, tb: l.tb, lease: l}
+	t.txReadOnly.txReadEnv = t
+	return t
+}
+
+// ReadWriteTransaction executes a read-write transaction on the leased
+// session, with retries as necessary. See Client.ReadWriteTransaction for more
+// details.
+//
+// The session is not returned to the session pool at the end of the
+// transaction, so the lease can be used for multiple transactions in a row.
+// The transactions of a lease always begin with a BeginTransaction RPC, as the
+// leased session has not been prepared for a read-write transaction by the
+// session pool.
+func (l *SessionLease) ReadWriteTransaction(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error) (commitTimestamp time.Time, err error) {
+	return l.c.readWriteTransaction(ctx, f, ReadWriteTransactionOptions{}, l)
+}
+
+// take returns the session handle of the lease. If the leased session has
+// been destroyed, for example because Cloud Spanner returned Session not
+// found, a new session is taken from the session pool.
+func (l *SessionLease) take(ctx context.Context) (*sessionHandle, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil, errSessionLeaseReleased()
+	}
+	if l.sh.getID() == "" {
+		sh, err := l.sp.take(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l.sh = sh
+	}
+	return l.sh, nil
+}
+
+// Release returns the leased session to the session pool. Transactions that
+// are returned by Single and calls to ReadWriteTransaction fail after the
+// lease has been released. It is safe to call Release multiple times.
+func (l *SessionLease) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return
+	}
+	l.released = true
+	// If the session handle is already destroyed, this becomes a noop.
+	l.sh.recycle()
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"context"
+	"testing"
+
+	. "cloud.google.com/go/spanner/internal/testutil"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClient_LeaseSession(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	lease, err := client.LeaseSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkouts := client.SessionAcquisitionLatency().Total()
+	const numReads = 10
+	for i := 0; i < numReads; i++ {
+		if err := executeSingerQuery(ctx, lease.Single()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The reads use the leased session and do not take a session from the
+	// pool.
+	if g, w := client.SessionAcquisitionLatency().Total(), checkouts; g != w {
+		t.Fatalf("session checkouts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	// Reads without the lease take a session from the pool.
+	if err := executeSingerQuery(ctx, client.Single()); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := client.SessionAcquisitionLatency().Total(), checkouts+1; g != w {
+		t.Fatalf("session checkouts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	lease.Release()
+	err = executeSingerQuery(ctx, lease.Single())
+	if g, w := ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestClient_LeaseSession_ReadWriteTransaction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	lease, err := client.LeaseSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkouts := client.SessionAcquisitionLatency().Total()
+	const numTransactions = 5
+	for i := 0; i < numTransactions; i++ {
+		if _, err := lease.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+			if _, err := tx.Update(ctx, NewStatement(UpdateBarSetFoo)); err != nil {
+				return err
+			}
+			return tx.BufferWrite([]*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(i)})})
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The transactions use the leased session and do not take a session from
+	// the pool.
+	if g, w := client.SessionAcquisitionLatency().Total(), checkouts; g != w {
+		t.Fatalf("session checkouts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	var commits int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if commit, ok := req.(*sppb.CommitRequest); ok {
+			commits++
+			if g, w := commit.Session, lease.sh.getID(); g != w {
+				t.Fatalf("commit session mismatch\nGot: %v\nWant: %v", g, w)
+			}
+		}
+	}
+	if g, w := commits, numTransactions; g != w {
+		t.Fatalf("commit count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// A deferred Release returns the session even if the transaction panics.
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("missing panic")
+			}
+		}()
+		defer lease.Release()
+		lease.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+			panic("test panic")
+		})
+	}()
+	_, err = lease.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		return nil
+	})
+	if g, w := ErrCode(err), codes.FailedPrecondition; g != w {
+		t.Fatalf("error code mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
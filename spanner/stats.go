@@ -16,6 +16,9 @@ package spanner
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"time"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
@@ -27,6 +30,23 @@ func recordStat(ctx context.Context, m *stats.Int64Measure, n int64) {
 	stats.Record(ctx, m.M(n))
 }
 
+func recordLatencyStat(ctx context.Context, m *stats.Float64Measure, d time.Duration) {
+	stats.Record(ctx, m.M(float64(d)/float64(time.Millisecond)))
+}
+
+// sessionAcquisitionLatencyBounds are the upper bounds of the buckets of the
+// session acquisition latency histogram.
+var sessionAcquisitionLatencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
 var (
 	// OpenSessionCount is a measure of the number of sessions currently opened.
 	// It is EXPERIMENTAL and subject to change or removal without notice.
@@ -41,4 +61,82 @@ var (
 		Measure:     OpenSessionCount,
 		Aggregation: view.LastValue(),
 	}
+
+	// SessionAcquisitionLatency is a measure of the time in milliseconds that
+	// was needed to take a session from the session pool, including the time
+	// that was spent waiting for a session to become available.
+	// It is EXPERIMENTAL and subject to change or removal without notice.
+	SessionAcquisitionLatency = stats.Float64(statsPrefix+"session_acquisition_latency", "Time needed to take a session from the session pool",
+		stats.UnitMilliseconds)
+
+	// SessionAcquisitionLatencyView is a view of the distribution of
+	// SessionAcquisitionLatency.
+	// It is EXPERIMENTAL and subject to change or removal without notice.
+	SessionAcquisitionLatencyView = &view.View{
+		Name:        SessionAcquisitionLatency.Name(),
+		Description: SessionAcquisitionLatency.Description(),
+		Measure:     SessionAcquisitionLatency,
+		Aggregation: view.Distribution(millis(sessionAcquisitionLatencyBounds)...),
+	}
 )
+
+// millis converts durations into milliseconds.
+func millis(ds []time.Duration) []float64 {
+	res := make([]float64, len(ds))
+	for i, d := range ds {
+		res[i] = float64(d) / float64(time.Millisecond)
+	}
+	return res
+}
+
+// LatencyHistogram is a snapshot of a histogram of latencies.
+type LatencyHistogram struct {
+	// Bounds are the upper bounds of the buckets of the histogram in
+	// ascending order.
+	Bounds []time.Duration
+	// Counts contains the number of latencies in each bucket. Counts[i] is the
+	// number of latencies that were less than Bounds[i] and not less than
+	// Bounds[i-1]. Counts has one more element than Bounds, which contains
+	// the number of latencies that were not less than the last bound.
+	Counts []uint64
+}
+
+// Total returns the total number of latencies in the histogram.
+func (h LatencyHistogram) Total() uint64 {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	return total
+}
+
+// latencyHistogram is a histogram of latencies that is safe for concurrent
+// use.
+type latencyHistogram struct {
+	bounds []time.Duration
+
+	mu     sync.Mutex
+	counts []uint64
+}
+
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	return &latencyHistogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// record adds a latency to the histogram.
+func (h *latencyHistogram) record(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d < h.bounds[i] })
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+}
+
+// snapshot returns a copy of the current state of the histogram.
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return LatencyHistogram{
+		Bounds: append([]time.Duration(nil), h.bounds...),
+		Counts: append([]uint64(nil), h.counts...),
+	}
+}
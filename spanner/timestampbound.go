@@ -153,6 +153,20 @@ func MaxStaleness(d time.Duration) TimestampBound {
 	}
 }
 
+// ReadFreshWithin returns a TimestampBound for reads and queries that should
+// return data that is as fresh as possible, but that may be at most "d" stale.
+// Cloud Spanner chooses the newest timestamp within the bound that allows the
+// read to execute at the closest available replica without blocking, which
+// minimizes the latency of the read while meeting the freshness requirement.
+//
+// ReadFreshWithin(d) is equivalent to MaxStaleness(d), and can only be used
+// with single-use reads and queries, for example:
+//
+//	iter := client.Single().WithTimestampBound(spanner.ReadFreshWithin(10*time.Second)).Query(ctx, stmt)
+func ReadFreshWithin(d time.Duration) TimestampBound {
+	return MaxStaleness(d)
+}
+
 // MinReadTimestamp returns a TimestampBound that bound that will perform reads
 // and queries at a time chosen to be at least "t".
 func MinReadTimestamp(t time.Time) TimestampBound {
@@ -52,6 +52,24 @@ func TestMaxStaleness(t *testing.T) {
 	}
 }
 
+// Test generating TimestampBound for reads with a freshness requirement.
+func TestReadFreshWithin(t *testing.T) {
+	got := ReadFreshWithin(10 * time.Second)
+	want := TimestampBound{mode: maxStaleness, d: 10 * time.Second}
+	if !testEqual(got, want) {
+		t.Errorf("ReadFreshWithin(10*time.Second) = %v; want %v", got, want)
+	}
+	opts := buildTransactionOptionsReadOnly(got, true)
+	wantOpts := &sppb.TransactionOptions_ReadOnly{
+		TimestampBound: &sppb.TransactionOptions_ReadOnly_MaxStaleness{
+			MaxStaleness: &pbd.Duration{Seconds: 10}},
+		ReturnReadTimestamp: true,
+	}
+	if !testEqual(opts, wantOpts) {
+		t.Errorf("buildTransactionOptionsReadOnly(%v,true) = %v; want %v", got, opts, wantOpts)
+	}
+}
+
 // Test generating TimestampBound for reads with minimum freshness requirement.
 func TestMinReadTimestamp(t *testing.T) {
 	ts := time.Now()
@@ -17,13 +17,19 @@ limitations under the License.
 package spanner
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
+	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/internal/trace"
 	vkit "cloud.google.com/go/spanner/apiv1"
+	"github.com/golang/protobuf/proto"
+	proto3 "github.com/golang/protobuf/ptypes/struct"
 	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/iterator"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
@@ -90,13 +96,16 @@ type ReadOptions struct {
 // ReadWithOptions returns a RowIterator for reading multiple rows from the
 // database. Pass a ReadOptions to modify the read operation.
 func (t *txReadOnly) ReadWithOptions(ctx context.Context, table string, keys KeySet, columns []string, opts *ReadOptions) (ri *RowIterator) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Read")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.Read")
 	defer func() { trace.EndSpan(ctx, ri.err) }()
 	var (
 		sh  *sessionHandle
 		ts  *sppb.TransactionSelector
 		err error
 	)
+	if err := checkContextDone(ctx); err != nil {
+		return &RowIterator{err: err}
+	}
 	kset, err := keys.keySetProto()
 	if err != nil {
 		return &RowIterator{err: err}
@@ -118,7 +127,7 @@ func (t *txReadOnly) ReadWithOptions(ctx context.Context, table string, keys Key
 			limit = opts.Limit
 		}
 	}
-	return stream(
+	iter := stream(
 		contextWithOutgoingMetadata(ctx, sh.getMetadata()),
 		sh.session.logger,
 		func(ctx context.Context, resumeToken []byte) (streamingReceiver, error) {
@@ -137,6 +146,9 @@ func (t *txReadOnly) ReadWithOptions(ctx context.Context, table string, keys Key
 		t.setTimestamp,
 		t.release,
 	)
+	iter.streamd.isRetryable = sh.session.isRetryableStreamError
+	iter.streamd.retryGate = sh.session.retryGate
+	return iter
 }
 
 // errRowNotFound returns error for not being able to read the row identified by
@@ -150,11 +162,33 @@ func errRowNotFound(table string, key Key) error {
 // If no row is present with the given key, then ReadRow returns an error where
 // spanner.ErrCode(err) is codes.NotFound.
 func (t *txReadOnly) ReadRow(ctx context.Context, table string, key Key, columns []string) (*Row, error) {
+	return t.ReadRowWithOptions(ctx, table, key, columns, nil)
+}
+
+// ReadRowOptions provides options for reading a single row from a database.
+type ReadRowOptions struct {
+	// AllowMissing determines whether reading a row that does not exist is
+	// an error. If true, ReadRowWithOptions returns a nil row and a nil error
+	// for a row that does not exist. Otherwise, it returns an error with code
+	// NotFound.
+	AllowMissing bool
+}
+
+// ReadRowWithOptions reads a single row from the database. Pass a
+// ReadRowOptions to modify the read operation.
+//
+// If no row is present with the given key, then ReadRowWithOptions returns an
+// error where spanner.ErrCode(err) is codes.NotFound, unless
+// opts.AllowMissing is true.
+func (t *txReadOnly) ReadRowWithOptions(ctx context.Context, table string, key Key, columns []string, opts *ReadRowOptions) (*Row, error) {
 	iter := t.Read(ctx, table, key, columns)
 	defer iter.Stop()
 	row, err := iter.Next()
 	switch err {
 	case iterator.Done:
+		if opts != nil && opts.AllowMissing {
+			return nil, nil
+		}
 		return nil, errRowNotFound(table, key)
 	case nil:
 		return row, nil
@@ -163,6 +197,86 @@ func (t *txReadOnly) ReadRow(ctx context.Context, table string, key Key, columns
 	}
 }
 
+// ReadRows reads the rows with the given keys from the database with a single
+// read, and returns the rows that were found keyed by the String
+// representation of their primary key. Keys that do not exist in the table are
+// absent from the returned map. ReadRows can for example be used to warm up a
+// cache:
+//
+//	rows, err := client.Single().ReadRows(ctx, "Singers", []spanner.Key{{1}, {2}, {3}}, []string{"SingerId", "Name"})
+//	...
+//	if row, ok := rows[spanner.Key{2}.String()]; ok {
+//		...
+//	}
+//
+// The rows are matched with the keys by the values of their key columns, and
+// the columns must therefore start with the primary key columns of the table in
+// the order of the primary key.
+func (t *txReadOnly) ReadRows(ctx context.Context, table string, keys []Key, columns []string) (map[string]*Row, error) {
+	// byKey maps the encoded values of each key to the String representation
+	// of the key. lengths contains the distinct lengths of the keys.
+	byKey := make(map[string]string, len(keys))
+	lengths := make(map[int]bool)
+	keySets := make([]KeySet, 0, len(keys))
+	for _, key := range keys {
+		if len(key) > len(columns) {
+			return nil, errReadRowsKeyColumns(key, columns)
+		}
+		lv, err := key.proto()
+		if err != nil {
+			return nil, err
+		}
+		enc, err := encodeKeyValues(lv.Values)
+		if err != nil {
+			return nil, err
+		}
+		byKey[enc] = key.String()
+		lengths[len(key)] = true
+		keySets = append(keySets, key)
+	}
+	rows := make(map[string]*Row, len(keys))
+	if len(keys) == 0 {
+		return rows, nil
+	}
+	iter := t.Read(ctx, table, KeySets(keySets...), columns)
+	err := iter.Do(func(row *Row) error {
+		for n := range lengths {
+			if n > len(row.vals) {
+				continue
+			}
+			enc, err := encodeKeyValues(row.vals[:n])
+			if err != nil {
+				return err
+			}
+			if k, ok := byKey[enc]; ok {
+				rows[k] = row
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// encodeKeyValues returns a deterministic encoding of the given key values
+// that is used to match rows with keys.
+func encodeKeyValues(vals []*proto3.Value) (string, error) {
+	var b proto.Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(&proto3.ListValue{Values: vals}); err != nil {
+		return "", toSpannerError(err)
+	}
+	return string(b.Bytes()), nil
+}
+
+// errReadRowsKeyColumns returns error for a key that has more parts than the
+// number of columns that are read by ReadRows.
+func errReadRowsKeyColumns(key Key, columns []string) error {
+	return spannerErrorf(codes.InvalidArgument, "key %v has more parts than the columns %v; the columns must start with the primary key columns", key, columns)
+}
+
 // Query executes a query against the database. It returns a RowIterator for
 // retrieving the resulting rows.
 //
@@ -170,19 +284,149 @@ func (t *txReadOnly) ReadRow(ctx context.Context, table string, key Key, columns
 // Use QueryWithStats to get rows along with the plan and statistics. Use
 // AnalyzeQuery to get just the plan.
 func (t *txReadOnly) Query(ctx context.Context, statement Statement) *RowIterator {
-	return t.query(ctx, statement, sppb.ExecuteSqlRequest_NORMAL)
+	return t.query(ctx, statement, sppb.ExecuteSqlRequest_NORMAL, QueryOptions{})
+}
+
+// QueryOptions provides options for executing a query.
+type QueryOptions struct {
+	// AttemptTimeout is the maximum duration of a single attempt to execute
+	// the query. The timeout covers the entire stream of the attempt, including
+	// receiving all results, and an attempt that exceeds it fails with
+	// DeadlineExceeded. The default is no timeout per attempt.
+	AttemptTimeout time.Duration
+
+	// RetryDeadlineExceeded indicates that an attempt that fails with
+	// DeadlineExceeded should be retried as long as the context of the query
+	// has not expired. This is normally used together with AttemptTimeout.
+	RetryDeadlineExceeded bool
+
+	// RetryResourceExhausted indicates that an attempt that fails with
+	// ResourceExhausted should be retried as long as the context of the query
+	// has not expired. If the error contains a RetryInfo with a retry delay,
+	// the attempt is retried after that delay instead of the default backoff.
+	RetryResourceExhausted bool
+
+	// PrefetchDepth is the number of PartialResultSets that are fetched from
+	// the stream in the background while the caller is processing the rows
+	// that have already been returned. This overlaps receiving results from
+	// the network with processing them. The default is 0, which means that
+	// results are only fetched when the caller asks for the next row.
+	PrefetchDepth int
+
+	// TypeCoercions contains functions that convert the values of columns
+	// with the given type codes before the rows are returned by the
+	// RowIterator. The column of the returned row contains the value that is
+	// returned by the function, and has the type of that value. The function
+	// can return any value that can be used as a query parameter. A NULL
+	// value must be returned as a typed null of the coerced type, such as
+	// NullInt64{}, as an untyped nil keeps the original type of the column.
+	// For example, the following decodes all TIMESTAMP columns as Unix
+	// milliseconds:
+	//
+	// 	opts := spanner.QueryOptions{
+	// 		TypeCoercions: map[sppb.TypeCode]spanner.TypeCoercion{
+	// 			sppb.TypeCode_TIMESTAMP: func(v spanner.GenericColumnValue) (interface{}, error) {
+	// 				var t spanner.NullTime
+	// 				if err := v.Decode(&t); err != nil {
+	// 					return nil, err
+	// 				}
+	// 				if !t.Valid {
+	// 					return spanner.NullInt64{}, nil
+	// 				}
+	// 				return t.Time.UnixNano() / int64(time.Millisecond), nil
+	// 			},
+	// 		},
+	// 	}
+	//
+	// Coercions are only applied to the top-level columns of a row, and not to
+	// the elements of arrays or the fields of structs.
+	TypeCoercions map[sppb.TypeCode]TypeCoercion
+
+	// DuplicateColumnNames determines how columns with the same name in the
+	// result of the query are handled, for example the columns of a query
+	// that selects all columns of two joined tables. The default is
+	// DuplicateColumnNamesKeep.
+	DuplicateColumnNames DuplicateColumnNames
+
+	// MaxBytes is the maximum approximate number of bytes of the rows that
+	// the RowIterator returns. The size of a row is the size of the encoded
+	// values of its columns. If returning the next row would exceed the
+	// budget, the stream is cancelled and RowIterator.Next returns a
+	// *ByteBudgetExceededError. The rows that were returned before the error
+	// stay valid. The default is 0, which means that there is no budget.
+	MaxBytes int64
+
+	// OnDecodeError is called with the row and the error if a row of the
+	// result cannot be decoded, and decides whether the row is skipped or
+	// the query is aborted with the error. A row cannot be decoded if one of
+	// its values is malformed or does not match the type of its column, or if
+	// one of the TypeCoercions of the query returns an error for it. The
+	// values of a row are only checked if OnDecodeError is set, which
+	// requires decoding each value an extra time. The default is nil, which
+	// aborts the query if a TypeCoercion fails.
+	OnDecodeError func(row *Row, err error) DecodeErrorAction
+}
+
+// TypeCoercion converts the value of a column. See QueryOptions.TypeCoercions.
+type TypeCoercion func(GenericColumnValue) (interface{}, error)
+
+// DecodeErrorAction determines how a row of a query result that cannot be
+// decoded is handled. See QueryOptions.OnDecodeError.
+type DecodeErrorAction int
+
+const (
+	// DecodeErrorAbort aborts the query, and RowIterator.Next returns the
+	// error of the row.
+	DecodeErrorAbort DecodeErrorAction = iota
+
+	// DecodeErrorSkipRow skips the row and continues with the next row of
+	// the result.
+	DecodeErrorSkipRow
+)
+
+// DuplicateColumnNames determines how columns with the same name in the
+// result of a query are handled. See QueryOptions.DuplicateColumnNames.
+type DuplicateColumnNames int
+
+const (
+	// DuplicateColumnNamesKeep returns the columns with the names that were
+	// returned by Cloud Spanner. The columns can be accessed by index, but
+	// the methods of Row that access columns by name, such as ColumnByName,
+	// ToMap and ToStruct, return an error for a name that is used by more
+	// than one column. This is the default.
+	DuplicateColumnNamesKeep DuplicateColumnNames = iota
+
+	// DuplicateColumnNamesSuffix renames all but the first column with a
+	// given name by appending an underscore and a sequence number to the
+	// name, starting at 1. A query that returns the columns Id, Name, Id
+	// and Id returns rows with the columns Id, Name, Id_1 and Id_2. A
+	// number is skipped if the resulting name is already used by another
+	// column. Columns without a name are not renamed.
+	DuplicateColumnNamesSuffix
+
+	// DuplicateColumnNamesError fails the query with an InvalidArgument
+	// error if the result contains more than one column with the same
+	// name. Columns without a name are ignored.
+	DuplicateColumnNamesError
+)
+
+// QueryWithOptions executes a SQL statement against the database using the
+// given QueryOptions. It returns a RowIterator for retrieving the resulting
+// rows.
+func (t *txReadOnly) QueryWithOptions(ctx context.Context, statement Statement, opts QueryOptions) *RowIterator {
+	return t.query(ctx, statement, sppb.ExecuteSqlRequest_NORMAL, opts)
 }
 
 // Query executes a SQL statement against the database. It returns a RowIterator
 // for retrieving the resulting rows. The RowIterator will also be populated
 // with a query plan and execution statistics.
 func (t *txReadOnly) QueryWithStats(ctx context.Context, statement Statement) *RowIterator {
-	return t.query(ctx, statement, sppb.ExecuteSqlRequest_PROFILE)
+	return t.query(ctx, statement, sppb.ExecuteSqlRequest_PROFILE, QueryOptions{})
 }
 
 // AnalyzeQuery returns the query plan for statement.
 func (t *txReadOnly) AnalyzeQuery(ctx context.Context, statement Statement) (*sppb.QueryPlan, error) {
-	iter := t.query(ctx, statement, sppb.ExecuteSqlRequest_PLAN)
+	iter := t.query(ctx, statement, sppb.ExecuteSqlRequest_PLAN, QueryOptions{})
 	defer iter.Stop()
 	for {
 		_, err := iter.Next()
@@ -199,15 +443,18 @@ func (t *txReadOnly) AnalyzeQuery(ctx context.Context, statement Statement) (*sp
 	return iter.QueryPlan, nil
 }
 
-func (t *txReadOnly) query(ctx context.Context, statement Statement, mode sppb.ExecuteSqlRequest_QueryMode) (ri *RowIterator) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Query")
+func (t *txReadOnly) query(ctx context.Context, statement Statement, mode sppb.ExecuteSqlRequest_QueryMode, opts QueryOptions) (ri *RowIterator) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.Query")
 	defer func() { trace.EndSpan(ctx, ri.err) }()
+	if err := checkContextDone(ctx); err != nil {
+		return &RowIterator{err: err}
+	}
 	req, sh, err := t.prepareExecuteSQL(ctx, statement, mode)
 	if err != nil {
 		return &RowIterator{err: err}
 	}
 	client := sh.getClient()
-	return stream(
+	iter := streamWithOptions(
 		contextWithOutgoingMetadata(ctx, sh.getMetadata()),
 		sh.session.logger,
 		func(ctx context.Context, resumeToken []byte) (streamingReceiver, error) {
@@ -215,7 +462,11 @@ func (t *txReadOnly) query(ctx context.Context, statement Statement, mode sppb.E
 			return client.ExecuteStreamingSql(ctx, req)
 		},
 		t.setTimestamp,
-		t.release)
+		t.release,
+		opts)
+	iter.streamd.isRetryable = sh.session.isRetryableStreamError
+	iter.streamd.retryGate = sh.session.retryGate
+	return iter
 }
 
 func (t *txReadOnly) prepareExecuteSQL(ctx context.Context, stmt Statement, mode sppb.ExecuteSqlRequest_QueryMode) (*sppb.ExecuteSqlRequest, *sessionHandle, error) {
@@ -312,6 +563,10 @@ type ReadOnlyTransaction struct {
 	state txState
 	// sh is the sessionHandle allocated from sp.
 	sh *sessionHandle
+	// lease is the session lease that provides the session of a single-use
+	// transaction instead of sp, if the transaction was created by
+	// SessionLease.Single.
+	lease *SessionLease
 	// rts is the read timestamp returned by transactional reads.
 	rts time.Time
 	// tb is the read staleness bound specification for transactional reads.
@@ -436,7 +691,15 @@ func (t *ReadOnlyTransaction) acquireSingleUse(ctx context.Context) (*sessionHan
 				},
 			},
 		}
-		sh, err := t.sp.take(ctx)
+		var (
+			sh  *sessionHandle
+			err error
+		)
+		if t.lease != nil {
+			sh, err = t.lease.take(ctx)
+		} else {
+			sh, err = t.sp.take(ctx)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
@@ -530,13 +793,86 @@ func (t *ReadOnlyTransaction) release(err error) {
 		if shouldDropSession(err) {
 			sh.destroy()
 		}
-		if t.singleUse {
+		if t.singleUse && t.lease == nil {
 			// If session handle is already destroyed, this becomes a noop.
+			// A leased session is recycled when the lease is released.
 			sh.recycle()
 		}
 	}
 }
 
+// TableRead describes a single read that is executed by
+// ReadOnlyTransaction.ReadMulti.
+type TableRead struct {
+	// Table is the name of the table to read from.
+	Table string
+	// Keys is the set of keys to read.
+	Keys KeySet
+	// Columns are the columns to read.
+	Columns []string
+	// Options are the options for the read. It may be nil.
+	Options *ReadOptions
+}
+
+// errMultiReadSingleUse returns error for executing more than one read on a
+// single-use transaction.
+func errMultiReadSingleUse() error {
+	return spannerErrorf(codes.InvalidArgument, "a single-use transaction can only execute one read")
+}
+
+// ReadMulti executes the given reads concurrently in the transaction and
+// returns the rows of each read, in the same order as the reads. All reads
+// are executed on the same session and see the same snapshot of the database.
+// The transaction is started before the reads are executed, so the reads do
+// not each need to wait for the transaction to be started.
+//
+// If any of the reads fails, the other reads are cancelled and the error of
+// the first failed read is returned.
+func (t *ReadOnlyTransaction) ReadMulti(ctx context.Context, reads []TableRead) ([][]*Row, error) {
+	if t.singleUse {
+		if len(reads) > 1 {
+			return nil, errMultiReadSingleUse()
+		}
+	} else if _, _, err := t.acquire(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	results := make([][]*Row, len(reads))
+	for i, r := range reads {
+		wg.Add(1)
+		go func(i int, r TableRead) {
+			defer wg.Done()
+			iter := t.ReadWithOptions(ctx, r.Table, r.Keys, r.Columns, r.Options)
+			var rows []*Row
+			err := iter.Do(func(row *Row) error {
+				rows = append(rows, row)
+				return nil
+			})
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = rows
+		}(i, r)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
 // Close closes a ReadOnlyTransaction, the transaction cannot perform any reads
 // after being closed.
 func (t *ReadOnlyTransaction) Close() {
@@ -574,6 +910,92 @@ func (t *ReadOnlyTransaction) Timestamp() (time.Time, error) {
 	return t.rts, nil
 }
 
+// errTxNotActive returns error for marshaling a ReadOnlyTransaction that has
+// not been started.
+func errTxNotActive() error {
+	return spannerErrorf(codes.FailedPrecondition, "only an active multi-use read-only transaction can be marshaled, execute a read or query on the transaction first")
+}
+
+// errTxNotNew returns error for unmarshaling into a ReadOnlyTransaction that
+// has already been used.
+func errTxNotNew() error {
+	return spannerErrorf(codes.FailedPrecondition, "a read-only transaction can only be unmarshaled into a new transaction")
+}
+
+// MarshalBinary implements BinaryMarshaler. It serializes the session,
+// transaction ID and read timestamp of the transaction, so that the
+// transaction can be reconstructed with UnmarshalBinary in a different
+// process to execute more reads and queries at the same snapshot.
+//
+// The transaction must be a multi-use transaction on which at least one read
+// or query has been executed. The transaction must not be closed before all
+// reconstructed copies have finished using it, as closing the transaction
+// returns its session to the session pool.
+func (t *ReadOnlyTransaction) MarshalBinary() (data []byte, err error) {
+	t.mu.Lock()
+	if t.singleUse || t.state != txActive || t.sh == nil {
+		t.mu.Unlock()
+		return nil, errTxNotActive()
+	}
+	tx, sid, rts := t.tx, t.sh.getID(), t.rts
+	t.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(tx); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(sid); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(rts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements BinaryUnmarshaler. It reconstructs a transaction
+// that was serialized with MarshalBinary into t, which must be a new
+// transaction that was returned by Client.ReadOnlyTransaction. Reads and
+// queries on t will use the same session and snapshot as the original
+// transaction.
+//
+// Closing t does not close the original transaction, and the session is not
+// returned to the session pool of the client of t.
+func (t *ReadOnlyTransaction) UnmarshalBinary(data []byte) error {
+	var (
+		tx  transactionID
+		sid string
+		rts time.Time
+	)
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&tx); err != nil {
+		return err
+	}
+	if err := dec.Decode(&sid); err != nil {
+		return err
+	}
+	if err := dec.Decode(&rts); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.singleUse || t.state != txNew || t.sp == nil {
+		return errTxNotNew()
+	}
+	if !strings.HasPrefix(sid, t.sp.sc.database+"/sessions/") {
+		return errSessionNotInDatabase(sid, t.sp.sc.database)
+	}
+	// The session is owned by the original transaction, so it does not belong
+	// to the session pool of this transaction.
+	t.sh = &sessionHandle{session: t.sp.sc.sessionWithID(sid)}
+	t.tx = tx
+	t.rts = rts
+	t.state = txActive
+	return nil
+}
+
 // WithTimestampBound specifies the TimestampBound to use for read or query.
 // This can only be used before the first read or query is invoked. Note:
 // bounded staleness is not available with general ReadOnlyTransactions; use a
@@ -671,6 +1093,33 @@ type ReadWriteTransaction struct {
 	state txState
 	// wb is the set of buffered mutations waiting to be committed.
 	wb []*Mutation
+	// wbSize is the approximate size in bytes of the buffered mutations.
+	wbSize int
+	// txOpts are the options that are used to begin the transaction. The
+	// default read-write options are used if txOpts is nil.
+	txOpts *sppb.TransactionOptions
+	// commitRetry determines which Internal errors of the Commit RPC are
+	// retried.
+	commitRetry CommitRetryConfig
+	// onCommit are the hooks that are called after the transaction has been
+	// committed successfully.
+	onCommit []func(commitTime time.Time)
+}
+
+// OnCommit registers a hook that is called with the commit timestamp after
+// the transaction has been committed successfully. The hooks are called in
+// the order in which they were registered, before ReadWriteTransaction
+// returns.
+//
+// Each attempt of a transaction uses a new ReadWriteTransaction, so hooks
+// that are registered during an attempt that is aborted and retried are
+// never called. A hook that is registered in the transaction function is
+// therefore called exactly once if the transaction succeeds, and never if it
+// fails. Hooks must not use the transaction.
+func (t *ReadWriteTransaction) OnCommit(f func(commitTime time.Time)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onCommit = append(t.onCommit, f)
 }
 
 // BufferWrite adds a list of mutations to the set of updates that will be
@@ -690,16 +1139,75 @@ func (t *ReadWriteTransaction) BufferWrite(ms []*Mutation) error {
 		return errUnexpectedTxState(t.state)
 	}
 	t.wb = append(t.wb, ms...)
+	for _, m := range ms {
+		// Mutations that cannot be encoded will fail the commit, and do not
+		// count towards the size.
+		if pb, err := m.proto(); err == nil {
+			t.wbSize += proto.Size(pb)
+		}
+	}
 	return nil
 }
 
+// BufferedMutationCount returns the number of mutations that have been
+// buffered in the transaction with BufferWrite.
+func (t *ReadWriteTransaction) BufferedMutationCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.wb)
+}
+
+// BufferedByteSize returns the approximate size in bytes of the mutations
+// that have been buffered in the transaction with BufferWrite. It can be used
+// to split a large set of changes over multiple transactions before the
+// commit exceeds the limits of Cloud Spanner.
+func (t *ReadWriteTransaction) BufferedByteSize() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.wbSize
+}
+
 // Update executes a DML statement against the database. It returns the number
 // of affected rows. Update returns an error if the statement is a query.
 // However, the query is executed, and any data read will be validated upon
 // commit.
 func (t *ReadWriteTransaction) Update(ctx context.Context, stmt Statement) (rowCount int64, err error) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Update")
+	return t.UpdateWithOptions(ctx, stmt, UpdateOptions{})
+}
+
+// UpdateOptions provides options for executing a DML statement.
+type UpdateOptions struct {
+	// RequireRowsAffected indicates that a statement that does not affect any
+	// rows is an error. If true, UpdateWithOptions returns a
+	// *NoRowsAffectedError if the statement affected zero rows. The statement
+	// has still been executed in the transaction, and the transaction is
+	// rolled back if the error is returned by the transaction function.
+	//
+	// Defaults to false, which means that a statement that does not affect
+	// any rows returns a row count of zero without an error.
+	RequireRowsAffected bool
+}
+
+// NoRowsAffectedError is returned by UpdateWithOptions for a statement that
+// did not affect any rows if UpdateOptions.RequireRowsAffected is set.
+type NoRowsAffectedError struct {
+	// SQL is the SQL string of the statement.
+	SQL string
+}
+
+func (e *NoRowsAffectedError) Error() string {
+	return fmt.Sprintf("spanner: statement did not affect any rows: %q", e.SQL)
+}
+
+// UpdateWithOptions executes a DML statement against the database using the
+// given UpdateOptions. It returns the number of affected rows. See Update for
+// more details.
+func (t *ReadWriteTransaction) UpdateWithOptions(ctx context.Context, stmt Statement, opts UpdateOptions) (rowCount int64, err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	if err := checkContextDone(ctx); err != nil {
+		return 0, err
+	}
 	req, sh, err := t.prepareExecuteSQL(ctx, stmt, sppb.ExecuteSqlRequest_NORMAL)
 	if err != nil {
 		return 0, err
@@ -711,7 +1219,14 @@ func (t *ReadWriteTransaction) Update(ctx context.Context, stmt Statement) (rowC
 	if resultSet.Stats == nil {
 		return 0, spannerErrorf(codes.InvalidArgument, "query passed to Update: %q", stmt.SQL)
 	}
-	return extractRowCount(resultSet.Stats)
+	rowCount, err = extractRowCount(resultSet.Stats)
+	if err != nil {
+		return 0, err
+	}
+	if opts.RequireRowsAffected && rowCount == 0 {
+		return 0, &NoRowsAffectedError{SQL: stmt.SQL}
+	}
+	return rowCount, nil
 }
 
 // BatchUpdate groups one or more DML statements and sends them to Spanner in a
@@ -721,8 +1236,11 @@ func (t *ReadWriteTransaction) Update(ctx context.Context, stmt Statement) (rowC
 // affected rows for the given query at the same index. If an error occurs,
 // counts will be returned up to the query that encountered the error.
 func (t *ReadWriteTransaction) BatchUpdate(ctx context.Context, stmts []Statement) (_ []int64, err error) {
-	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.BatchUpdate")
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.BatchUpdate")
 	defer func() { trace.EndSpan(ctx, err) }()
+	if err := checkContextDone(ctx); err != nil {
+		return nil, err
+	}
 
 	sh, ts, err := t.acquire(ctx)
 	if err != nil {
@@ -772,6 +1290,73 @@ func (t *ReadWriteTransaction) BatchUpdate(ctx context.Context, stmts []Statemen
 	return counts, nil
 }
 
+// QueryAndUpdate executes a query and a DML statement in the transaction and
+// returns all rows of the query together with the number of rows that were
+// affected by the DML statement.
+//
+// The two statements are pipelined: the DML statement is sent to Cloud Spanner
+// as soon as the query has started to return results, and the remaining rows
+// of the query are read while the DML statement is being executed. The query
+// is executed before the DML statement, which means that the query does not
+// see the changes of the DML statement. QueryAndUpdate should only be used if
+// the DML statement does not depend on the results of the query.
+func (t *ReadWriteTransaction) QueryAndUpdate(ctx context.Context, query, dml Statement) (rows []*Row, rowCount int64, err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.QueryAndUpdate")
+	defer func() { trace.EndSpan(ctx, err) }()
+	iter := t.Query(ctx, query)
+	defer iter.Stop()
+	// Wait for the first result of the query before sending the DML
+	// statement, so that Cloud Spanner receives the statements in the order
+	// of their sequence numbers.
+	row, err := iter.Next()
+	if err != nil && err != iterator.Done {
+		return nil, 0, err
+	}
+	type updateResult struct {
+		rowCount int64
+		err      error
+	}
+	updateCh := make(chan updateResult, 1)
+	go func() {
+		rowCount, err := t.Update(ctx, dml)
+		updateCh <- updateResult{rowCount, err}
+	}()
+	var queryErr error
+	if err != iterator.Done {
+		rows = append(rows, row)
+		queryErr = iter.Do(func(r *Row) error {
+			rows = append(rows, r)
+			return nil
+		})
+	}
+	res := <-updateCh
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+	if res.err != nil {
+		return nil, 0, res.err
+	}
+	return rows, res.rowCount, nil
+}
+
+// keepAliveSQL is the statement that is executed by KeepAlive.
+const keepAliveSQL = "SELECT 1"
+
+// KeepAlive pings the session of the transaction by executing a trivial query
+// in the transaction. This prevents both the session and the transaction from
+// being garbage collected by Cloud Spanner while the transaction function is
+// waiting for something else, such as a long call to an external system.
+//
+// Cloud Spanner aborts transactions that have been idle for more than 10
+// seconds, so KeepAlive should be called at a shorter interval.
+func (t *ReadWriteTransaction) KeepAlive(ctx context.Context) (err error) {
+	ctx = startSpan(ctx, "cloud.google.com/go/spanner.KeepAlive")
+	defer func() { trace.EndSpan(ctx, err) }()
+	iter := t.Query(ctx, NewStatement(keepAliveSQL))
+	defer iter.Stop()
+	return iter.Do(func(*Row) error { return nil })
+}
+
 // acquire implements txReadEnv.acquire.
 func (t *ReadWriteTransaction) acquire(ctx context.Context) (*sessionHandle, *sppb.TransactionSelector, error) {
 	ts := &sppb.TransactionSelector{
@@ -800,14 +1385,17 @@ func (t *ReadWriteTransaction) release(err error) {
 	}
 }
 
-func beginTransaction(ctx context.Context, sid string, client *vkit.Client) (transactionID, error) {
-	res, err := client.BeginTransaction(ctx, &sppb.BeginTransactionRequest{
-		Session: sid,
-		Options: &sppb.TransactionOptions{
+func beginTransaction(ctx context.Context, sid string, client *vkit.Client, opts *sppb.TransactionOptions) (transactionID, error) {
+	if opts == nil {
+		opts = &sppb.TransactionOptions{
 			Mode: &sppb.TransactionOptions_ReadWrite_{
 				ReadWrite: &sppb.TransactionOptions_ReadWrite{},
 			},
-		},
+		}
+	}
+	res, err := client.BeginTransaction(ctx, &sppb.BeginTransactionRequest{
+		Session: sid,
+		Options: opts,
 	})
 	if err != nil {
 		return nil, err
@@ -825,7 +1413,7 @@ func (t *ReadWriteTransaction) begin(ctx context.Context) error {
 		t.state = txActive
 		return nil
 	}
-	tx, err := beginTransaction(contextWithOutgoingMetadata(ctx, t.sh.getMetadata()), t.sh.getID(), t.sh.getClient())
+	tx, err := beginTransaction(contextWithOutgoingMetadata(ctx, t.sh.getMetadata()), t.sh.getID(), t.sh.getClient(), t.txOpts)
 	if err == nil {
 		t.tx = tx
 		t.state = txActive
@@ -855,16 +1443,23 @@ func (t *ReadWriteTransaction) commit(ctx context.Context) (time.Time, error) {
 		return ts, errSessionClosed(t.sh)
 	}
 
-	var trailer metadata.MD
-	res, e := client.Commit(contextWithOutgoingMetadata(ctx, t.sh.getMetadata()), &sppb.CommitRequest{
-		Session: sid,
-		Transaction: &sppb.CommitRequest_TransactionId{
-			TransactionId: t.tx,
-		},
-		Mutations: mPb,
-	}, gax.WithGRPCOptions(grpc.Trailer(&trailer)))
-	if e != nil {
-		return ts, toSpannerErrorWithMetadata(e, trailer)
+	var res *sppb.CommitResponse
+	if e := t.commitRetry.run(ctx, func() error {
+		var trailer metadata.MD
+		var e error
+		res, e = client.Commit(contextWithOutgoingMetadata(ctx, t.sh.getMetadata()), &sppb.CommitRequest{
+			Session: sid,
+			Transaction: &sppb.CommitRequest_TransactionId{
+				TransactionId: t.tx,
+			},
+			Mutations: mPb,
+		}, gax.WithGRPCOptions(grpc.Trailer(&trailer)))
+		if e != nil {
+			return toSpannerErrorWithMetadata(e, trailer)
+		}
+		return nil
+	}); e != nil {
+		return ts, e
 	}
 	if tstamp := res.GetCommitTimestamp(); tstamp != nil {
 		ts = time.Unix(tstamp.Seconds, int64(tstamp.Nanos))
@@ -919,6 +1514,12 @@ func (t *ReadWriteTransaction) runInTransaction(ctx context.Context, f func(cont
 		t.rollback(ctx)
 		return ts, err
 	}
+	t.mu.Lock()
+	hooks := t.onCommit
+	t.mu.Unlock()
+	for _, f := range hooks {
+		f(ts)
+	}
 	// err == nil, return commit timestamp.
 	return ts, nil
 }
@@ -929,6 +1530,9 @@ type writeOnlyTransaction struct {
 	// sp is the session pool which writeOnlyTransaction uses to get Cloud
 	// Spanner sessions for blind writes.
 	sp *sessionPool
+	// commitRetry determines which Internal errors of the Commit RPC are
+	// retried.
+	commitRetry CommitRetryConfig
 }
 
 // applyAtLeastOnce commits a list of mutations to Cloud Spanner at least once,
@@ -961,17 +1565,21 @@ func (t *writeOnlyTransaction) applyAtLeastOnce(ctx context.Context, ms ...*Muta
 				return ts, err
 			}
 		}
-		res, err := sh.getClient().Commit(contextWithOutgoingMetadata(ctx, sh.getMetadata()), &sppb.CommitRequest{
-			Session: sh.getID(),
-			Transaction: &sppb.CommitRequest_SingleUseTransaction{
-				SingleUseTransaction: &sppb.TransactionOptions{
-					Mode: &sppb.TransactionOptions_ReadWrite_{
-						ReadWrite: &sppb.TransactionOptions_ReadWrite{},
+		var res *sppb.CommitResponse
+		err := t.commitRetry.run(ctx, func() (err error) {
+			res, err = sh.getClient().Commit(contextWithOutgoingMetadata(ctx, sh.getMetadata()), &sppb.CommitRequest{
+				Session: sh.getID(),
+				Transaction: &sppb.CommitRequest_SingleUseTransaction{
+					SingleUseTransaction: &sppb.TransactionOptions{
+						Mode: &sppb.TransactionOptions_ReadWrite_{
+							ReadWrite: &sppb.TransactionOptions_ReadWrite{},
+						},
 					},
 				},
-			},
-			Mutations: mPb,
-		}, gax.WithGRPCOptions(grpc.Trailer(&trailers)))
+				Mutations: mPb,
+			}, gax.WithGRPCOptions(grpc.Trailer(&trailers)))
+			return err
+		})
 		if err != nil && !isAbortErr(err) {
 			if shouldDropSession(err) {
 				// Discard the bad session.
@@ -26,6 +26,8 @@ import (
 	"time"
 
 	. "cloud.google.com/go/spanner/internal/testutil"
+	"github.com/golang/protobuf/proto"
+	proto3 "github.com/golang/protobuf/ptypes/struct"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc/codes"
 	gstatus "google.golang.org/grpc/status"
@@ -140,6 +142,456 @@ func TestReadOnlyTransaction_Concurrent(t *testing.T) {
 	}
 }
 
+// ReadOnlyTransaction: begins the transaction only once for multiple reads.
+func TestReadOnlyTransaction_MultipleReadsBeginOnce(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	txn := client.ReadOnlyTransaction()
+	defer txn.Close()
+	for i := 0; i < 3; i++ {
+		if err := executeSingerQuery(ctx, txn); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+
+	gotReqs, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.BeginTransactionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.ExecuteSqlRequest{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txID []byte
+	for i, req := range gotReqs[2:] {
+		sel, ok := req.(*sppb.ExecuteSqlRequest).Transaction.Selector.(*sppb.TransactionSelector_Id)
+		if !ok {
+			t.Fatalf("query %d: got transaction selector %v, want a transaction id", i, req.(*sppb.ExecuteSqlRequest).Transaction)
+		}
+		if txID == nil {
+			txID = sel.Id
+		}
+		if !testEqual(sel.Id, txID) {
+			t.Fatalf("query %d: got transaction id %v, want %v", i, sel.Id, txID)
+		}
+	}
+}
+
+func TestReadOnlyTransaction_MarshalUnmarshal(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	txn := client.ReadOnlyTransaction()
+	defer txn.Close()
+	if _, err := txn.MarshalBinary(); ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("got error %v when marshaling a new transaction, want error with code %v", err, codes.FailedPrecondition)
+	}
+	if err := executeSingerQuery(ctx, txn); err != nil {
+		t.Fatal(err)
+	}
+	data, err := txn.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTs, err := txn.Timestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+
+	copied := client.ReadOnlyTransaction()
+	defer copied.Close()
+	if err := copied.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := copied.UnmarshalBinary(data); ErrCode(err) != codes.FailedPrecondition {
+		t.Fatalf("got error %v when unmarshaling into an active transaction, want error with code %v", err, codes.FailedPrecondition)
+	}
+	gotTs, err := copied.Timestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotTs.Equal(wantTs) {
+		t.Fatalf("read timestamp mismatch\nGot: %v\nWant: %v", gotTs, wantTs)
+	}
+	if err := executeSingerQuery(ctx, copied); err != nil {
+		t.Fatal(err)
+	}
+	// The reconstructed transaction should not begin a new transaction, and
+	// should use the same session and transaction as the original.
+	gotReqs, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.ExecuteSqlRequest{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := gotReqs[0].(*sppb.ExecuteSqlRequest)
+	if g, w := req.Session, txn.sh.getID(); g != w {
+		t.Fatalf("session mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	sel, ok := req.Transaction.Selector.(*sppb.TransactionSelector_Id)
+	if !ok {
+		t.Fatalf("got transaction selector %v, want a transaction id", req.Transaction)
+	}
+	if g, w := transactionID(sel.Id), txn.tx; !testEqual(g, w) {
+		t.Fatalf("transaction id mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestReadWriteTransaction_BufferedMutations(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		if g, w := tx.BufferedMutationCount(), 0; g != w {
+			t.Fatalf("buffered mutation count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		if g, w := tx.BufferedByteSize(), 0; g != w {
+			t.Fatalf("buffered byte size mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		var wantSize int
+		for i := 1; i <= 3; i++ {
+			m := Insert("Accounts", []string{"AccountId", "Nickname"}, []interface{}{int64(i), "Foo"})
+			if err := tx.BufferWrite([]*Mutation{m}); err != nil {
+				return err
+			}
+			pb, err := m.proto()
+			if err != nil {
+				return err
+			}
+			wantSize += proto.Size(pb)
+			if g, w := tx.BufferedMutationCount(), i; g != w {
+				t.Fatalf("buffered mutation count mismatch\nGot: %v\nWant: %v", g, w)
+			}
+			if g, w := tx.BufferedByteSize(), wantSize; g != w {
+				t.Fatalf("buffered byte size mismatch\nGot: %v\nWant: %v", g, w)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadWriteTransaction_KeepAlive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	server.TestSpanner.PutStatementResult(keepAliveSQL, &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{
+				RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{
+						{Name: "", Type: &sppb.Type{Code: sppb.TypeCode_INT64}},
+					},
+				},
+			},
+			Rows: []*proto3.ListValue{
+				{Values: []*proto3.Value{intProto(1)}},
+			},
+		},
+	})
+	var txID []byte
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		if err := tx.KeepAlive(ctx); err != nil {
+			return err
+		}
+		txID = tx.tx
+		return tx.BufferWrite([]*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pings int
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		sqlReq, ok := req.(*sppb.ExecuteSqlRequest)
+		if !ok || sqlReq.Sql != keepAliveSQL {
+			continue
+		}
+		pings++
+		if g, w := sqlReq.GetTransaction().GetId(), txID; !testEqual(g, w) {
+			t.Fatalf("transaction id mismatch\nGot: %v\nWant: %v", g, w)
+		}
+	}
+	if g, w := pings, 1; g != w {
+		t.Fatalf("ping count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+}
+
+func TestReadWriteTransaction_QueryAndUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// Delay the second row of the query and the execution of the DML
+	// statement. The total time should be close to one of the delays, as the
+	// DML statement is executed while the rest of the query is read.
+	const delay = 300 * time.Millisecond
+	server.TestSpanner.AddPartialResultSetError(SelectSingerIDAlbumIDAlbumTitleFromAlbums, PartialResultSetExecutionTime{
+		ResumeToken:   EncodeResumeToken(2),
+		ExecutionTime: delay,
+	})
+	server.TestSpanner.PutExecutionTime(MethodExecuteSql, SimulatedExecutionTime{
+		MinimumExecutionTime: delay,
+	})
+	var (
+		rows     []*Row
+		rowCount int64
+		elapsed  time.Duration
+	)
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		start := time.Now()
+		var err error
+		rows, rowCount, err = tx.QueryAndUpdate(ctx, NewStatement(SelectSingerIDAlbumIDAlbumTitleFromAlbums), NewStatement(UpdateBarSetFoo))
+		elapsed = time.Since(start)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := int64(len(rows)), SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount; g != w {
+		t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := rowCount, int64(UpdateBarSetFooRowCount); g != w {
+		t.Fatalf("update count mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if elapsed >= 2*delay {
+		t.Fatalf("statements were not pipelined, elapsed time %v, want less than %v", elapsed, 2*delay)
+	}
+	// The query should have been sent before the DML statement.
+	var sqls []string
+	var seqnos []int64
+	for _, req := range drainRequestsFromServer(server.TestSpanner) {
+		if sqlReq, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			sqls = append(sqls, sqlReq.Sql)
+			seqnos = append(seqnos, sqlReq.Seqno)
+		}
+	}
+	if g, w := sqls, []string{SelectSingerIDAlbumIDAlbumTitleFromAlbums, UpdateBarSetFoo}; !testEqual(g, w) {
+		t.Fatalf("statement order mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if seqnos[0] >= seqnos[1] {
+		t.Fatalf("sequence numbers are not increasing: %v", seqnos)
+	}
+}
+
+func TestReadWriteTransaction_UpdateWithOptions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	const updateNothing = "UPDATE FOO SET BAR=1 WHERE FALSE"
+	server.TestSpanner.PutStatementResult(updateNothing, &StatementResult{
+		Type:        StatementResultUpdateCount,
+		UpdateCount: 0,
+	})
+
+	// A statement that affects zero rows is not an error by default.
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		rowCount, err := tx.UpdateWithOptions(ctx, NewStatement(updateNothing), UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		if g, w := rowCount, int64(0); g != w {
+			t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		rowCount, err = tx.UpdateWithOptions(ctx, NewStatement(UpdateBarSetFoo), UpdateOptions{RequireRowsAffected: true})
+		if err != nil {
+			return err
+		}
+		if g, w := rowCount, int64(UpdateBarSetFooRowCount); g != w {
+			t.Fatalf("row count mismatch\nGot: %v\nWant: %v", g, w)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainRequestsFromServer(server.TestSpanner)
+
+	// With RequireRowsAffected it is an error, which rolls back the
+	// transaction if it is returned by the transaction function.
+	_, err = client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		_, err := tx.UpdateWithOptions(ctx, NewStatement(updateNothing), UpdateOptions{RequireRowsAffected: true})
+		return err
+	})
+	noRows, ok := err.(*NoRowsAffectedError)
+	if !ok {
+		t.Fatalf("error mismatch\nGot: %v\nWant: %T", err, noRows)
+	}
+	if g, w := noRows.SQL, updateNothing; g != w {
+		t.Fatalf("SQL mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if _, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.BeginTransactionRequest{},
+		&sppb.ExecuteSqlRequest{},
+		&sppb.RollbackRequest{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadOnlyTransaction_ReadMulti(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	tables := []string{"Singers", "Albums", "Songs"}
+	for i, table := range tables {
+		server.TestSpanner.PutReadResult(table, &StatementResult{
+			Type: StatementResultResultSet,
+			ResultSet: &sppb.ResultSet{
+				Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{
+					Fields: []*sppb.StructType_Field{{Name: "Id", Type: intType()}},
+				}},
+				// Table i contains i+1 rows.
+				Rows: func() []*proto3.ListValue {
+					var rows []*proto3.ListValue
+					for j := 0; j <= i; j++ {
+						rows = append(rows, &proto3.ListValue{Values: []*proto3.Value{intProto(int64(j))}})
+					}
+					return rows
+				}(),
+			},
+		})
+	}
+	var reads []TableRead
+	for _, table := range tables {
+		reads = append(reads, TableRead{Table: table, Keys: AllKeys(), Columns: []string{"Id"}})
+	}
+
+	txn := client.ReadOnlyTransaction()
+	defer txn.Close()
+	results, err := txn.ReadMulti(ctx, reads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(results), len(tables); g != w {
+		t.Fatalf("number of results mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	for i, rows := range results {
+		if g, w := len(rows), i+1; g != w {
+			t.Fatalf("number of rows of %s mismatch\nGot: %v\nWant: %v", tables[i], g, w)
+		}
+		for j, row := range rows {
+			var id int64
+			if err := row.Columns(&id); err != nil {
+				t.Fatal(err)
+			}
+			if g, w := id, int64(j); g != w {
+				t.Fatalf("row %d of %s mismatch\nGot: %v\nWant: %v", j, tables[i], g, w)
+			}
+		}
+	}
+
+	// All reads must use the same transaction, which is started once.
+	gotReqs, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.BeginTransactionRequest{},
+		&sppb.ReadRequest{},
+		&sppb.ReadRequest{},
+		&sppb.ReadRequest{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, req := range gotReqs[2:] {
+		sel, ok := req.(*sppb.ReadRequest).Transaction.Selector.(*sppb.TransactionSelector_Id)
+		if !ok {
+			t.Fatalf("read %d: got transaction selector %v, want a transaction id", i, req.(*sppb.ReadRequest).Transaction)
+		}
+		if !testEqual(transactionID(sel.Id), txn.tx) {
+			t.Fatalf("read %d: got transaction id %v, want %v", i, sel.Id, txn.tx)
+		}
+		if g, w := req.(*sppb.ReadRequest).Session, txn.sh.getID(); g != w {
+			t.Fatalf("read %d: got session %v, want %v", i, g, w)
+		}
+	}
+
+	// A failed read fails the whole ReadMulti.
+	reads = append(reads, TableRead{Table: "Unknown", Keys: AllKeys(), Columns: []string{"Id"}})
+	if _, err := txn.ReadMulti(ctx, reads); ErrCode(err) != codes.Unimplemented {
+		t.Fatalf("got error %v, want error with code %v", err, codes.Unimplemented)
+	}
+}
+
+func TestReadOnlyTransaction_ReadRows(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// The mock server returns the same rows regardless of the requested keys,
+	// which means that the result also contains a row that was not
+	// requested.
+	server.TestSpanner.PutReadResult("Singers", &StatementResult{
+		Type: StatementResultResultSet,
+		ResultSet: &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{
+				Fields: []*sppb.StructType_Field{
+					{Name: "SingerId", Type: intType()},
+					{Name: "Name", Type: stringType()},
+				},
+			}},
+			Rows: []*proto3.ListValue{
+				listValueProto(intProto(1), stringProto("Alice")),
+				listValueProto(intProto(2), stringProto("Bob")),
+				listValueProto(intProto(3), stringProto("Carol")),
+			},
+		},
+	})
+	keys := []Key{{1}, {3}, {4}}
+	rows, err := client.Single().ReadRows(ctx, "Singers", keys, []string{"SingerId", "Name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for k, row := range rows {
+		var name string
+		if err := row.ColumnByName("Name", &name); err != nil {
+			t.Fatal(err)
+		}
+		got[k] = name
+	}
+	want := map[string]string{Key{1}.String(): "Alice", Key{3}.String(): "Carol"}
+	if !testEqual(got, want) {
+		t.Fatalf("rows mismatch\nGot: %v\nWant: %v", got, want)
+	}
+
+	// All keys must be read with a single read.
+	gotReqs, err := shouldHaveReceived(server.TestSpanner, []interface{}{
+		&sppb.CreateSessionRequest{},
+		&sppb.ReadRequest{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(gotReqs[1].(*sppb.ReadRequest).KeySet.Keys), len(keys); g != w {
+		t.Fatalf("number of keys mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// The columns must include all key columns.
+	if _, err := client.Single().ReadRows(ctx, "Singers", []Key{{1, 2}}, []string{"SingerId"}); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("got error %v, want error with code %v", err, codes.InvalidArgument)
+	}
+}
+
 func TestApply_Single(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -195,6 +647,52 @@ func TestApply_RetryOnAbort(t *testing.T) {
 	}
 }
 
+func TestReadWriteTransaction_OnCommit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	// The first attempt is aborted and retried.
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction,
+		SimulatedExecutionTime{
+			Errors: []error{gstatus.Errorf(codes.Aborted, "")},
+		})
+	var attempts int
+	var hookTimes []time.Time
+	commitTimestamp, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		attempts++
+		tx.OnCommit(func(commitTime time.Time) {
+			hookTimes = append(hookTimes, commitTime)
+		})
+		return tx.BufferWrite([]*Mutation{Insert("Accounts", []string{"AccountId"}, []interface{}{int64(1)})})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := attempts, 2; g != w {
+		t.Fatalf("attempts mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := hookTimes, []time.Time{commitTimestamp}; !testEqual(g, w) {
+		t.Fatalf("hook commit timestamps mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// The hooks are not called if the transaction fails.
+	hookTimes = nil
+	_, err = client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *ReadWriteTransaction) error {
+		tx.OnCommit(func(commitTime time.Time) {
+			hookTimes = append(hookTimes, commitTime)
+		})
+		return errors.New("an error")
+	})
+	if err == nil {
+		t.Fatal("missing error for failed transaction")
+	}
+	if len(hookTimes) != 0 {
+		t.Fatalf("hook called for failed transaction with %v", hookTimes)
+	}
+}
+
 // Tests that NotFound errors cause failures, and aren't retried.
 func TestTransaction_NotFound(t *testing.T) {
 	t.Parallel()
@@ -17,11 +17,13 @@ limitations under the License.
 package spanner
 
 import (
+	"encoding"
 	"encoding/base64"
 	"fmt"
 	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -193,6 +195,80 @@ func (v GenericColumnValue) Decode(ptr interface{}) error {
 	return decodeValue(v.Value, v.Type, ptr)
 }
 
+// DecodeArray decodes a GenericColumnValue that contains an ARRAY into ptr,
+// which must be a non-nil pointer to a slice. Each element of the array is
+// decoded into the corresponding element of the slice in the same way as
+// Decode would decode a single value of the array element type, so the slice
+// can have any element type that Decode accepts for that type, including
+// GenericColumnValue. A NULL array is decoded into a nil slice.
+//
+// DecodeArray can be used to decode the elements of an array that was read
+// into a GenericColumnValue once the element type is known, for example:
+//
+//	var v GenericColumnValue
+//	if err := row.Column(0, &v); err != nil {
+//		return err
+//	}
+//	var ids []NullInt64
+//	if err := v.DecodeArray(&ids); err != nil {
+//		return err
+//	}
+func (v GenericColumnValue) DecodeArray(ptr interface{}) error {
+	if v.Type == nil {
+		return errNilSpannerType()
+	}
+	if v.Value == nil {
+		return errNilSrc()
+	}
+	rv := reflect.ValueOf(ptr)
+	if !rv.IsValid() || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errNilDst(ptr)
+	}
+	if v.Type.Code != sppb.TypeCode_ARRAY || rv.Elem().Kind() != reflect.Slice {
+		return errTypeMismatch(v.Type.Code, v.Type.GetArrayElementType().GetCode(), ptr)
+	}
+	elemType := v.Type.ArrayElementType
+	if elemType == nil {
+		return errNilArrElemType(v.Type)
+	}
+	if _, isNull := v.Value.Kind.(*proto3.Value_NullValue); isNull {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		return nil
+	}
+	lv, err := getListValue(v.Value)
+	if err != nil {
+		return err
+	}
+	s := reflect.MakeSlice(rv.Elem().Type(), len(lv.Values), len(lv.Values))
+	for i, e := range lv.Values {
+		if err := decodeValue(e, elemType, s.Index(i).Addr().Interface()); err != nil {
+			return errDecodeArrayElement(i, e, elemType.Code.String(), err)
+		}
+	}
+	rv.Elem().Set(s)
+	return nil
+}
+
+// EncodeValue encodes a Go value into the Cloud Spanner value and type that
+// the client library would send for it, for example as a query parameter. The
+// value can be any type that is supported as a query parameter. Together with
+// DecodeValue it can be used to verify that custom types are encoded and
+// decoded as expected.
+func EncodeValue(v interface{}) (GenericColumnValue, error) {
+	value, typ, err := encodeValue(v)
+	if err != nil {
+		return GenericColumnValue{}, err
+	}
+	return GenericColumnValue{Value: value, Type: typ}, nil
+}
+
+// DecodeValue decodes a Cloud Spanner value into ptr in the same way as
+// Row.Column would decode it. The ptr argument should be a pointer to a Go
+// value that can accept v.
+func DecodeValue(v GenericColumnValue, ptr interface{}) error {
+	return v.Decode(ptr)
+}
+
 // NewGenericColumnValue creates a GenericColumnValue from Go value that is
 // valid for Cloud Spanner.
 func newGenericColumnValue(v interface{}) (*GenericColumnValue, error) {
@@ -251,6 +327,12 @@ func errBadEncoding(v *proto3.Value, err error) error {
 	return spannerErrorf(codes.FailedPrecondition, "%v wasn't correctly encoded: <%v>", v, err)
 }
 
+// errUnmarshalText returns error for a destination that implements
+// encoding.TextUnmarshaler failing to decode a STRING value.
+func errUnmarshalText(dst interface{}, err error) error {
+	return spannerErrorf(codes.InvalidArgument, "failed to decode STRING value into %T: %v", dst, err)
+}
+
 func parseNullTime(v *proto3.Value, p *NullTime, code sppb.TypeCode, isNull bool) error {
 	if p == nil {
 		return errNilDst(p)
@@ -275,15 +357,104 @@ func parseNullTime(v *proto3.Value, p *NullTime, code sppb.TypeCode, isNull bool
 	return nil
 }
 
+// DecodeOption is an option that changes how column values are decoded into
+// Go values. See Row.ColumnWithOptions and ToStructOptions.DecodeOptions.
+type DecodeOption func(*decodeSetting)
+
+// decodeSetting contains the settings that are used to decode values.
+type decodeSetting struct {
+	// dateAsTime allows DATE values to be decoded into time.Time based types.
+	dateAsTime bool
+	// bytesAsBase64String allows BYTES values to be decoded into string based
+	// types.
+	bytesAsBase64String bool
+	// int64AsFloat64 allows INT64 values to be decoded into float64 based
+	// types.
+	int64AsFloat64 bool
+	// strictFloatPrecision makes decoding fail if a value cannot be
+	// represented exactly by a float64.
+	strictFloatPrecision bool
+}
+
+// newDecodeSetting returns the decode settings for the given options.
+func newDecodeSetting(opts []DecodeOption) decodeSetting {
+	var s decodeSetting
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// DateAsTime returns a DecodeOption that allows DATE values to be decoded into
+// *time.Time, *NullTime, *[]time.Time and *[]NullTime in addition to the
+// civil.Date based types. A date is decoded as midnight UTC of that date.
+func DateAsTime() DecodeOption {
+	return func(s *decodeSetting) {
+		s.dateAsTime = true
+	}
+}
+
+// BytesAsBase64String returns a DecodeOption that allows BYTES values to be
+// decoded into *string, *NullString, *[]string and *[]NullString in addition
+// to the []byte based types. The bytes are encoded as a base64 string with
+// the standard encoding of package encoding/base64.
+func BytesAsBase64String() DecodeOption {
+	return func(s *decodeSetting) {
+		s.bytesAsBase64String = true
+	}
+}
+
+// Int64AsFloat64 returns a DecodeOption that allows INT64 values to be decoded
+// into *float64, *NullFloat64, *[]float64 and *[]NullFloat64 in addition to the
+// int64 based types. Integers with an absolute value larger than 2^53 are
+// rounded to the nearest float64, unless the StrictFloatPrecision option is
+// also used.
+func Int64AsFloat64() DecodeOption {
+	return func(s *decodeSetting) {
+		s.int64AsFloat64 = true
+	}
+}
+
+// StrictFloatPrecision returns a DecodeOption that makes decoding fail with an
+// error instead of losing precision if a value that is decoded into a float64
+// cannot be represented exactly by a float64, for example an INT64 value that
+// is decoded with the Int64AsFloat64 option.
+func StrictFloatPrecision() DecodeOption {
+	return func(s *decodeSetting) {
+		s.strictFloatPrecision = true
+	}
+}
+
 // decodeValue decodes a protobuf Value into a pointer to a Go value, as
 // specified by sppb.Type.
 func decodeValue(v *proto3.Value, t *sppb.Type, ptr interface{}) error {
+	return decodeValueWithSetting(v, t, ptr, decodeSetting{})
+}
+
+// decodeValueWithSetting is the same as decodeValue, but uses the given
+// settings to decode the value.
+func decodeValueWithSetting(v *proto3.Value, t *sppb.Type, ptr interface{}, s decodeSetting) error {
 	if v == nil {
 		return errNilSrc()
 	}
 	if t == nil {
 		return errNilSpannerType()
 	}
+	if s.dateAsTime {
+		if ok, err := decodeDateAsTime(v, t, ptr); ok {
+			return err
+		}
+	}
+	if s.bytesAsBase64String {
+		if ok, err := decodeBytesAsBase64String(v, t, ptr); ok {
+			return err
+		}
+	}
+	if s.int64AsFloat64 {
+		if ok, err := decodeInt64AsFloat64(v, t, ptr, s.strictFloatPrecision); ok {
+			return err
+		}
+	}
 	code := t.Code
 	acode := sppb.TypeCode_TYPE_CODE_UNSPECIFIED
 	if code == sppb.TypeCode_ARRAY {
@@ -792,6 +963,21 @@ func decodeValue(v *proto3.Value, t *sppb.Type, ptr interface{}) error {
 	case *GenericColumnValue:
 		*p = GenericColumnValue{Type: t, Value: v}
 	default:
+		// Check if the pointer can decode itself from a STRING value.
+		if u, ok := ptr.(encoding.TextUnmarshaler); ok && code == sppb.TypeCode_STRING {
+			if isNull {
+				return errDstNotForNull(ptr)
+			}
+			x, err := getStringValue(v)
+			if err != nil {
+				return err
+			}
+			if err := u.UnmarshalText([]byte(x)); err != nil {
+				return errUnmarshalText(ptr, err)
+			}
+			break
+		}
+
 		// Check if the pointer is a variant of a base type.
 		decodableType := getDecodableSpannerType(ptr)
 		if decodableType != spannerTypeUnknown {
@@ -809,8 +995,9 @@ func decodeValue(v *proto3.Value, t *sppb.Type, ptr interface{}) error {
 		if !vp.IsValid() {
 			return errNilDst(p)
 		}
-		if !isPtrStructPtrSlice(vp.Type()) {
-			// The container is not a pointer to a struct pointer slice.
+		if !isPtrStructPtrSlice(vp.Type()) && !isPtrStructSlice(vp.Type()) {
+			// The container is not a pointer to a struct or struct pointer
+			// slice.
 			return errTypeMismatch(code, acode, ptr)
 		}
 		// Only use reflection for nil detection on slow path.
@@ -828,13 +1015,312 @@ func decodeValue(v *proto3.Value, t *sppb.Type, ptr interface{}) error {
 		if err != nil {
 			return err
 		}
-		if err = decodeStructArray(t.ArrayElementType.StructType, x, p); err != nil {
+		if err = decodeStructArray(t.ArrayElementType.StructType, x, p, s); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// decodeDateAsTime decodes a DATE or ARRAY<DATE> value into a pointer to a
+// time.Time based type. It returns false if ptr is not a time.Time based
+// pointer or if the value is not a DATE value.
+func decodeDateAsTime(v *proto3.Value, t *sppb.Type, ptr interface{}) (bool, error) {
+	isDate := t.Code == sppb.TypeCode_DATE
+	isDateArray := t.Code == sppb.TypeCode_ARRAY && t.ArrayElementType != nil && t.ArrayElementType.Code == sppb.TypeCode_DATE
+	switch p := ptr.(type) {
+	case *time.Time:
+		if !isDate {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var d NullDate
+		if err := decodeValue(v, t, &d); err != nil {
+			return true, err
+		}
+		if !d.Valid {
+			return true, errDstNotForNull(ptr)
+		}
+		*p = d.Date.In(time.UTC)
+	case *NullTime:
+		if !isDate {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var d NullDate
+		if err := decodeValue(v, t, &d); err != nil {
+			return true, err
+		}
+		*p = NullTime{Valid: d.Valid}
+		if d.Valid {
+			p.Time = d.Date.In(time.UTC)
+		}
+	case *[]time.Time:
+		if !isDateArray {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var ds []civil.Date
+		if err := decodeValue(v, t, &ds); err != nil {
+			return true, err
+		}
+		if ds == nil {
+			*p = nil
+			break
+		}
+		y := make([]time.Time, len(ds))
+		for i, d := range ds {
+			y[i] = d.In(time.UTC)
+		}
+		*p = y
+	case *[]NullTime:
+		if !isDateArray {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var ds []NullDate
+		if err := decodeValue(v, t, &ds); err != nil {
+			return true, err
+		}
+		if ds == nil {
+			*p = nil
+			break
+		}
+		y := make([]NullTime, len(ds))
+		for i, d := range ds {
+			y[i].Valid = d.Valid
+			if d.Valid {
+				y[i].Time = d.Date.In(time.UTC)
+			}
+		}
+		*p = y
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// decodeBytesAsBase64String decodes a BYTES or ARRAY<BYTES> value into a
+// pointer to a string based type. It returns false if ptr is not a string
+// based pointer or if the value is not a BYTES value.
+func decodeBytesAsBase64String(v *proto3.Value, t *sppb.Type, ptr interface{}) (bool, error) {
+	isBytes := t.Code == sppb.TypeCode_BYTES
+	isBytesArray := t.Code == sppb.TypeCode_ARRAY && t.ArrayElementType != nil && t.ArrayElementType.Code == sppb.TypeCode_BYTES
+	switch p := ptr.(type) {
+	case *string:
+		if !isBytes {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var b []byte
+		if err := decodeValue(v, t, &b); err != nil {
+			return true, err
+		}
+		if b == nil {
+			return true, errDstNotForNull(ptr)
+		}
+		*p = base64.StdEncoding.EncodeToString(b)
+	case *NullString:
+		if !isBytes {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var b []byte
+		if err := decodeValue(v, t, &b); err != nil {
+			return true, err
+		}
+		*p = NullString{Valid: b != nil}
+		if b != nil {
+			p.StringVal = base64.StdEncoding.EncodeToString(b)
+		}
+	case *[]string:
+		if !isBytesArray {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var bs [][]byte
+		if err := decodeValue(v, t, &bs); err != nil {
+			return true, err
+		}
+		if bs == nil {
+			*p = nil
+			break
+		}
+		y := make([]string, len(bs))
+		for i, b := range bs {
+			if b == nil {
+				return true, errDstNotForNull(ptr)
+			}
+			y[i] = base64.StdEncoding.EncodeToString(b)
+		}
+		*p = y
+	case *[]NullString:
+		if !isBytesArray {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var bs [][]byte
+		if err := decodeValue(v, t, &bs); err != nil {
+			return true, err
+		}
+		if bs == nil {
+			*p = nil
+			break
+		}
+		y := make([]NullString, len(bs))
+		for i, b := range bs {
+			y[i].Valid = b != nil
+			if b != nil {
+				y[i].StringVal = base64.StdEncoding.EncodeToString(b)
+			}
+		}
+		*p = y
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// errFloatPrecisionLoss returns error for decoding an INT64 value into a
+// float64 that cannot represent the value exactly.
+func errFloatPrecisionLoss(x int64, dst interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "INT64 value %v cannot be decoded into %T without loss of precision", x, dst)
+}
+
+// int64ToFloat64 converts x to a float64. If strict is true, it returns an
+// error if x cannot be represented exactly by a float64.
+func int64ToFloat64(x int64, strict bool, dst interface{}) (float64, error) {
+	f := float64(x)
+	// Values that are rounded up to 2^63 cannot be converted back to an
+	// int64.
+	if strict && (f >= 1<<63 || int64(f) != x) {
+		return 0, errFloatPrecisionLoss(x, dst)
+	}
+	return f, nil
+}
+
+// decodeInt64AsFloat64 decodes an INT64 or ARRAY<INT64> value into a pointer
+// to a float64 based type. It returns false if ptr is not a float64 based
+// pointer or if the value is not an INT64 value.
+func decodeInt64AsFloat64(v *proto3.Value, t *sppb.Type, ptr interface{}, strict bool) (bool, error) {
+	isInt64 := t.Code == sppb.TypeCode_INT64
+	isInt64Array := t.Code == sppb.TypeCode_ARRAY && t.ArrayElementType != nil && t.ArrayElementType.Code == sppb.TypeCode_INT64
+	switch p := ptr.(type) {
+	case *float64:
+		if !isInt64 {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var x NullInt64
+		if err := decodeValue(v, t, &x); err != nil {
+			return true, err
+		}
+		if !x.Valid {
+			return true, errDstNotForNull(ptr)
+		}
+		f, err := int64ToFloat64(x.Int64, strict, ptr)
+		if err != nil {
+			return true, err
+		}
+		*p = f
+	case *NullFloat64:
+		if !isInt64 {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var x NullInt64
+		if err := decodeValue(v, t, &x); err != nil {
+			return true, err
+		}
+		if !x.Valid {
+			*p = NullFloat64{}
+			break
+		}
+		f, err := int64ToFloat64(x.Int64, strict, ptr)
+		if err != nil {
+			return true, err
+		}
+		*p = NullFloat64{Float64: f, Valid: true}
+	case *[]float64:
+		if !isInt64Array {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var xs []NullInt64
+		if err := decodeValue(v, t, &xs); err != nil {
+			return true, err
+		}
+		if xs == nil {
+			*p = nil
+			break
+		}
+		y := make([]float64, len(xs))
+		for i, x := range xs {
+			if !x.Valid {
+				return true, errDstNotForNull(ptr)
+			}
+			f, err := int64ToFloat64(x.Int64, strict, ptr)
+			if err != nil {
+				return true, err
+			}
+			y[i] = f
+		}
+		*p = y
+	case *[]NullFloat64:
+		if !isInt64Array {
+			return false, nil
+		}
+		if p == nil {
+			return true, errNilDst(p)
+		}
+		var xs []NullInt64
+		if err := decodeValue(v, t, &xs); err != nil {
+			return true, err
+		}
+		if xs == nil {
+			*p = nil
+			break
+		}
+		y := make([]NullFloat64, len(xs))
+		for i, x := range xs {
+			if !x.Valid {
+				continue
+			}
+			f, err := int64ToFloat64(x.Int64, strict, ptr)
+			if err != nil {
+				return true, err
+			}
+			y[i] = NullFloat64{Float64: f, Valid: true}
+		}
+		*p = y
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
 // decodableSpannerType represents the Go types that a value from a Spanner
 // database can be converted to.
 type decodableSpannerType uint
@@ -1593,6 +2079,13 @@ func errNoOrDupGoField(s interface{}, f string) error {
 	return spannerErrorf(codes.InvalidArgument, "Go struct %+v(type %T) has no or duplicate fields for Cloud Spanner STRUCT field %v", s, s, f)
 }
 
+// errUnexportedEmbeddedStructPtr returns error for decoding a Cloud Spanner
+// STRUCT field into a field of a nil pointer to an unexported embedded struct,
+// which cannot be allocated.
+func errUnexportedEmbeddedStructPtr(s interface{}, f string) error {
+	return spannerErrorf(codes.InvalidArgument, "Go struct %+v(type %T) has a nil pointer to an unexported embedded struct for Cloud Spanner STRUCT field %v", s, s, f)
+}
+
 // errDupColNames returns error for duplicated Cloud Spanner STRUCT field names
 // found in decoding a Cloud Spanner STRUCT into a Go struct.
 func errDupSpannerField(f string, ty *sppb.StructType) error {
@@ -1615,6 +2108,15 @@ func errDecodeStructField(ty *sppb.StructType, f string, err error) error {
 // ptr, according to
 // the structural information given in sppb.StructType ty.
 func decodeStruct(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}) error {
+	return decodeStructWithUnknownFields(ty, pb, ptr, nil, decodeSetting{})
+}
+
+// decodeStructWithUnknownFields is the same as decodeStruct, but calls
+// unknown for each field in ty that has no corresponding field in the Go
+// struct instead of returning an error. If unknown is nil, such fields are
+// ignored if the Go struct is tagged with the ignoreunknown option, and an
+// error is returned otherwise. The fields are decoded with the given settings.
+func decodeStructWithUnknownFields(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}, unknown func(f *sppb.StructType_Field, v *proto3.Value) error, s decodeSetting) error {
 	if reflect.ValueOf(ptr).IsNil() {
 		return errNilDst(ptr)
 	}
@@ -1630,6 +2132,11 @@ func decodeStruct(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}) er
 	if err != nil {
 		return toSpannerError(err)
 	}
+	if unknown == nil && ignoresUnknownFields(t) {
+		unknown = func(f *sppb.StructType_Field, v *proto3.Value) error {
+			return nil
+		}
+	}
 	seen := map[string]bool{}
 	for i, f := range ty.Fields {
 		if f.Name == "" {
@@ -1637,14 +2144,24 @@ func decodeStruct(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}) er
 		}
 		sf := fields.Match(f.Name)
 		if sf == nil {
-			return errNoOrDupGoField(ptr, f.Name)
+			if unknown == nil {
+				return errNoOrDupGoField(ptr, f.Name)
+			}
+			if err := unknown(f, pb.Values[i]); err != nil {
+				return err
+			}
+			continue
 		}
 		if seen[f.Name] {
 			// We don't allow duplicated field name.
 			return errDupSpannerField(f.Name, ty)
 		}
+		fv, ok := fieldByIndexAlloc(v, sf.Index)
+		if !ok {
+			return errUnexportedEmbeddedStructPtr(ptr, f.Name)
+		}
 		// Try to decode a single field.
-		if err := decodeValue(pb.Values[i], f.Type, v.FieldByIndex(sf.Index).Addr().Interface()); err != nil {
+		if err := decodeValueWithSetting(pb.Values[i], f.Type, fv.Addr().Interface(), s); err != nil {
 			return errDecodeStructField(ty, f.Name, err)
 		}
 		// Mark field f.Name as processed.
@@ -1653,6 +2170,27 @@ func decodeStruct(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}) er
 	return nil
 }
 
+// fieldByIndexAlloc returns the nested field of the struct v with the given
+// index, like v.FieldByIndex. Nil pointers to embedded structs on the way to
+// the field are set to a new zero value of the struct. It returns false if a
+// nil pointer to an embedded struct cannot be set, because the embedded
+// struct is unexported.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
 // isPtrStructPtrSlice returns true if ptr is a pointer to a slice of struct pointers.
 func isPtrStructPtrSlice(t reflect.Type) bool {
 	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
@@ -1666,15 +2204,38 @@ func isPtrStructPtrSlice(t reflect.Type) bool {
 	return true
 }
 
+// isPtrStructSlice returns true if ptr is a pointer to a slice of structs.
+func isPtrStructSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		// t is not a pointer to a slice.
+		return false
+	}
+	if t.Elem().Elem().Kind() != reflect.Struct {
+		// the slice that t points to is not a slice of structs.
+		return false
+	}
+	return true
+}
+
+// errNullStructArrayElement returns error for decoding a NULL element of a
+// Cloud Spanner ARRAY<STRUCT> into a slice of structs, which cannot
+// represent NULL.
+func errNullStructArrayElement(i int, ptr interface{}) error {
+	return spannerErrorf(codes.InvalidArgument, "cannot decode NULL element %v of Cloud Spanner ARRAY<STRUCT> into %T, use a slice of struct pointers instead", i, ptr)
+}
+
 // decodeStructArray decodes proto3.ListValue pb into struct slice referenced by
 // pointer ptr, according to the
-// structural information given in a sppb.StructType.
-func decodeStructArray(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}) error {
+// structural information given in a sppb.StructType. The slice can either be a
+// slice of struct pointers or a slice of structs.
+func decodeStructArray(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{}, s decodeSetting) error {
 	if pb == nil {
 		return errNilListValue("STRUCT")
 	}
-	// Type of the struct pointers stored in the slice that ptr points to.
+	// Type of the struct pointers or structs stored in the slice that ptr
+	// points to.
 	ts := reflect.TypeOf(ptr).Elem().Elem()
+	isPtr := ts.Kind() == reflect.Ptr
 	// The slice that ptr points to, might be nil at this point.
 	v := reflect.ValueOf(ptr).Elem()
 	// Allocate empty slice.
@@ -1683,27 +2244,134 @@ func decodeStructArray(ty *sppb.StructType, pb *proto3.ListValue, ptr interface{
 	for i, pv := range pb.Values {
 		// Check if pv is a NULL value.
 		if _, isNull := pv.Kind.(*proto3.Value_NullValue); isNull {
+			if !isPtr {
+				return errNullStructArrayElement(i, ptr)
+			}
 			// Append a nil pointer to the slice.
 			v.Set(reflect.Append(v, reflect.New(ts).Elem()))
 			continue
 		}
 		// Allocate empty struct.
-		s := reflect.New(ts.Elem())
+		var sv reflect.Value
+		if isPtr {
+			sv = reflect.New(ts.Elem())
+		} else {
+			sv = reflect.New(ts)
+		}
 		// Get proto3.ListValue l from proto3.Value pv.
 		l, err := getListValue(pv)
 		if err != nil {
 			return errDecodeArrayElement(i, pv, "STRUCT", err)
 		}
 		// Decode proto3.ListValue l into struct referenced by s.Interface().
-		if err = decodeStruct(ty, l, s.Interface()); err != nil {
+		if err = decodeStructWithUnknownFields(ty, l, sv.Interface(), nil, s); err != nil {
 			return errDecodeArrayElement(i, pv, "STRUCT", err)
 		}
 		// Append the decoded struct back into the slice.
-		v.Set(reflect.Append(v, s))
+		if !isPtr {
+			sv = sv.Elem()
+		}
+		v.Set(reflect.Append(v, sv))
 	}
 	return nil
 }
 
+// errUnsupportedSpannerType returns error for decoding a Cloud Spanner type
+// that has no native Go representation.
+func errUnsupportedSpannerType(t *sppb.Type) error {
+	return spannerErrorf(codes.InvalidArgument, "unsupported Cloud Spanner type %v", t)
+}
+
+// decodeNativeValue decodes a protobuf Value into the native Go type that
+// corresponds to the Cloud Spanner type t:
+//
+//	STRING - string
+//	BYTES - []byte
+//	INT64 - int64
+//	BOOL - bool
+//	FLOAT64 - float64
+//	TIMESTAMP - time.Time
+//	DATE - civil.Date
+//	ARRAY - []interface{} holding the native values of the elements
+//	STRUCT - map[string]interface{} from field name to native field value
+//
+// A NULL value of any type is decoded as nil.
+func decodeNativeValue(v *proto3.Value, t *sppb.Type) (interface{}, error) {
+	if v == nil {
+		return nil, errNilSrc()
+	}
+	if t == nil {
+		return nil, errNilSpannerType()
+	}
+	if _, isNull := v.Kind.(*proto3.Value_NullValue); isNull {
+		return nil, nil
+	}
+	switch t.Code {
+	case sppb.TypeCode_STRING:
+		var x string
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_BYTES:
+		var x []byte
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_INT64:
+		var x int64
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_BOOL:
+		var x bool
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_FLOAT64:
+		var x float64
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_TIMESTAMP:
+		var x time.Time
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_DATE:
+		var x civil.Date
+		err := decodeValue(v, t, &x)
+		return x, err
+	case sppb.TypeCode_ARRAY:
+		if t.ArrayElementType == nil {
+			return nil, errNilArrElemType(t)
+		}
+		x, err := getListValue(v)
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, len(x.Values))
+		for i, ev := range x.Values {
+			if a[i], err = decodeNativeValue(ev, t.ArrayElementType); err != nil {
+				return nil, errDecodeArrayElement(i, ev, t.ArrayElementType.Code.String(), err)
+			}
+		}
+		return a, nil
+	case sppb.TypeCode_STRUCT:
+		if t.StructType == nil {
+			return nil, errNilSpannerStructType()
+		}
+		x, err := getListValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(x.Values) != len(t.StructType.Fields) {
+			return nil, errFieldsMismatchVals(&Row{fields: t.StructType.Fields, vals: x.Values})
+		}
+		m := make(map[string]interface{}, len(x.Values))
+		for i, f := range t.StructType.Fields {
+			if m[f.Name], err = decodeNativeValue(x.Values[i], f.Type); err != nil {
+				return nil, errDecodeStructField(t.StructType, f.Name, err)
+			}
+		}
+		return m, nil
+	}
+	return nil, errUnsupportedSpannerType(t)
+}
+
 // errEncoderUnsupportedType returns error for not being able to encode a value
 // of certain type.
 func errEncoderUnsupportedType(v interface{}) error {
@@ -1900,6 +2568,12 @@ func encodeValue(v interface{}) (*proto3.Value, *sppb.Type, error) {
 		return nil, nil, errEncoderUnsupportedType(v)
 	default:
 		if !isStructOrArrayOfStructValue(v) {
+			// Values of other types that can encode themselves as text are
+			// sent as STRING. Structs are always sent as STRUCT, also if they
+			// get a MarshalText method from an embedded field.
+			if tm, ok := v.(encoding.TextMarshaler); ok {
+				return encodeTextMarshaler(tm)
+			}
 			return nil, nil, errEncoderUnsupportedType(v)
 		}
 		typ := reflect.TypeOf(v)
@@ -1918,6 +2592,25 @@ func encodeValue(v interface{}) (*proto3.Value, *sppb.Type, error) {
 	return pb, pt, nil
 }
 
+// errMarshalText returns error for a value that implements
+// encoding.TextMarshaler failing to encode itself.
+func errMarshalText(v interface{}, err error) error {
+	return spannerErrorf(codes.InvalidArgument, "failed to encode %T as STRING value: %v", v, err)
+}
+
+// encodeTextMarshaler encodes a value that can encode itself as text to a
+// STRING value. A nil pointer is encoded as a NULL STRING.
+func encodeTextMarshaler(v encoding.TextMarshaler) (*proto3.Value, *sppb.Type, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nullProto(), stringType(), nil
+	}
+	b, err := v.MarshalText()
+	if err != nil {
+		return nil, nil, errMarshalText(v, err)
+	}
+	return stringProto(string(b)), stringType(), nil
+}
+
 // Encodes a Go struct value/ptr in v to the spanner Value and Type protos. v
 // itself must be non-nil.
 func encodeStruct(v interface{}) (*proto3.Value, *sppb.Type, error) {
@@ -2071,14 +2764,53 @@ func encodeArray(len int, at func(int) interface{}) (*proto3.Value, error) {
 	return listProto(vs...), nil
 }
 
+// spannerTagOptions contains the options that can be specified after the
+// field name in a spanner struct tag, for example `spanner:"SingerId,key"`.
+type spannerTagOptions struct {
+	// key indicates that the field is part of the primary key of the row.
+	key bool
+	// ignoreUnknown indicates that fields of a Cloud Spanner STRUCT that have
+	// no corresponding field in the Go struct are ignored in decoding. It is
+	// only used in the tag of a blank (_) field.
+	ignoreUnknown bool
+}
+
 func spannerTagParser(t reflect.StructTag) (name string, keep bool, other interface{}, err error) {
 	if s := t.Get("spanner"); s != "" {
 		if s == "-" {
 			return "", false, nil, nil
 		}
-		return s, true, nil, nil
+		parts := strings.Split(s, ",")
+		var opts spannerTagOptions
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "key":
+				opts.key = true
+			case "ignoreunknown":
+				opts.ignoreUnknown = true
+			}
+		}
+		return parts[0], true, opts, nil
 	}
-	return "", true, nil, nil
+	return "", true, spannerTagOptions{}, nil
 }
 
 var fieldCache = fields.NewCache(spannerTagParser, nil, nil)
+
+// ignoresUnknownFields returns true if the struct type t has a blank (_) field
+// with the ignoreunknown option in its spanner tag, for example
+// `_ struct{} spanner:",ignoreunknown"`.
+func ignoresUnknownFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name != "_" {
+			continue
+		}
+		if _, _, other, err := spannerTagParser(f.Tag); err == nil {
+			if opts, ok := other.(spannerTagOptions); ok && opts.ignoreUnknown {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package spanner
 
 import (
+	"encoding/hex"
+	"fmt"
 	"math"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -28,6 +32,7 @@ import (
 	proto3 "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/go-cmp/cmp"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -937,6 +942,67 @@ func TestDecodeValue(t *testing.T) {
 				nil,
 			},
 		},
+		{
+			desc: "decode ARRAY<STRUCT> to []struct",
+			proto: listProto(
+				listProto(
+					intProto(3),
+					listProto(
+						listProto(floatProto(3.14), stringProto("this")),
+						listProto(floatProto(0.57), stringProto("siht")),
+					),
+				),
+				listProto(
+					nullProto(),
+					nullProto(),
+				),
+			),
+			protoType: listType(
+				structType(
+					mkField("Col1", intType()),
+					mkField(
+						"Col2",
+						listType(
+							structType(
+								mkField("SubCol1", floatType()),
+								mkField("SubCol2", stringType()),
+							),
+						),
+					),
+				),
+			),
+			want: []struct {
+				Col1      NullInt64
+				StructCol []struct {
+					SubCol1 NullFloat64
+					SubCol2 string
+				} `spanner:"Col2"`
+			}{
+				{
+					Col1: NullInt64{3, true},
+					StructCol: []struct {
+						SubCol1 NullFloat64
+						SubCol2 string
+					}{
+						{
+							SubCol1: NullFloat64{3.14, true},
+							SubCol2: "this",
+						},
+						{
+							SubCol1: NullFloat64{0.57, true},
+							SubCol2: "siht",
+						},
+					},
+				},
+				{
+					Col1: NullInt64{},
+					StructCol: []struct {
+						SubCol1 NullFloat64
+						SubCol2 string
+					}(nil),
+				},
+			},
+		},
 		// GenericColumnValue
 		{desc: "decode STRING to GenericColumnValue", proto: stringProto("abc"), protoType: stringType(), want: GenericColumnValue{stringType(), stringProto("abc")}},
 		{desc: "decode NULL to GenericColumnValue", proto: nullProto(), protoType: stringType(), want: GenericColumnValue{stringType(), nullProto()}},
@@ -1034,6 +1100,87 @@ func TestDecodeValue(t *testing.T) {
 }
 
 // Test error cases for decodeValue.
+// roundTrip encodes in with EncodeValue and decodes the result into a new
+// value of the same type with DecodeValue.
+func roundTrip(in interface{}) (interface{}, error) {
+	v, err := EncodeValue(in)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.New(reflect.TypeOf(in))
+	if err := DecodeValue(v, out.Interface()); err != nil {
+		return nil, err
+	}
+	return out.Elem().Interface(), nil
+}
+
+func TestEncodeDecodeValueRoundTrip(t *testing.T) {
+	check := func(name string, f interface{}) {
+		if err := quick.Check(f, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	equal := func(in interface{}) bool {
+		out, err := roundTrip(in)
+		if err != nil {
+			t.Logf("round-trip of %#v failed: %v", in, err)
+			return false
+		}
+		return testEqual(in, out)
+	}
+	toTime := func(ns int64) time.Time {
+		return time.Unix(0, ns).UTC()
+	}
+
+	// Scalar types.
+	check("string", func(v string) bool { return equal(v) })
+	check("int64", func(v int64) bool { return equal(v) })
+	check("bool", func(v bool) bool { return equal(v) })
+	check("float64", func(v float64) bool { return equal(v) })
+	check("bytes", func(v []byte) bool { return equal(v) })
+	check("time", func(ns int64) bool { return equal(toTime(ns)) })
+	check("date", func(ns int64) bool { return equal(civil.DateOf(toTime(ns))) })
+	check("NullString", func(v string, valid bool) bool {
+		if !valid {
+			v = ""
+		}
+		return equal(NullString{v, valid})
+	})
+	check("NullInt64", func(v int64, valid bool) bool {
+		if !valid {
+			v = 0
+		}
+		return equal(NullInt64{v, valid})
+	})
+	check("NullFloat64", func(v float64, valid bool) bool {
+		if !valid {
+			v = 0
+		}
+		return equal(NullFloat64{v, valid})
+	})
+
+	// Array types.
+	check("[]string", func(v []string) bool { return equal(v) })
+	check("[]int64", func(v []int64) bool { return equal(v) })
+	check("[]bool", func(v []bool) bool { return equal(v) })
+	check("[]float64", func(v []float64) bool { return equal(v) })
+	check("[][]byte", func(v [][]byte) bool { return equal(v) })
+	check("[]time.Time", func(v []int64) bool {
+		ts := make([]time.Time, len(v))
+		for i, ns := range v {
+			ts[i] = toTime(ns)
+		}
+		return equal(ts)
+	})
+	check("[]civil.Date", func(v []int64) bool {
+		ds := make([]civil.Date, len(v))
+		for i, ns := range v {
+			ds[i] = civil.DateOf(toTime(ns))
+		}
+		return equal(ds)
+	})
+}
+
 func TestDecodeValueErrors(t *testing.T) {
 	var s string
 	for i, test := range []struct {
@@ -1052,6 +1199,129 @@ func TestDecodeValueErrors(t *testing.T) {
 	}
 }
 
+// testUUID is a custom type that can be encoded to and decoded from a STRING
+// value through encoding.TextMarshaler and encoding.TextUnmarshaler.
+type testUUID [16]byte
+
+func (u testUUID) MarshalText() ([]byte, error) {
+	h := hex.EncodeToString(u[:])
+	return []byte(h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]), nil
+}
+
+func (u *testUUID) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.Replace(string(text), "-", "", -1))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(u) {
+		return fmt.Errorf("invalid UUID length: %d", len(b))
+	}
+	copy(u[:], b)
+	return nil
+}
+
+// failingTextMarshaler is a custom type that fails to encode itself.
+type failingTextMarshaler int
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, fmt.Errorf("cannot marshal")
+}
+
+func TestEncodeValueTextMarshaler(t *testing.T) {
+	u := testUUID{15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+	for _, test := range []struct {
+		desc     string
+		in       interface{}
+		want     *proto3.Value
+		wantType *sppb.Type
+	}{
+		{"encode value", u, stringProto("0f0e0d0c-0b0a-0908-0706-050403020100"), stringType()},
+		{"encode pointer", &u, stringProto("0f0e0d0c-0b0a-0908-0706-050403020100"), stringType()},
+		{"encode nil pointer", (*testUUID)(nil), nullProto(), stringType()},
+	} {
+		got, gotType, err := encodeValue(test.in)
+		if err != nil {
+			t.Fatalf("%s: got err = %v, want nil", test.desc, err)
+		}
+		if !testEqual(got, test.want) {
+			t.Errorf("%s: got encode result %v, want %v", test.desc, got, test.want)
+		}
+		if !testEqual(gotType, test.wantType) {
+			t.Errorf("%s: got encode type %v, want %v", test.desc, gotType, test.wantType)
+		}
+	}
+	if _, _, err := encodeValue(failingTextMarshaler(0)); ErrCode(err) != codes.InvalidArgument {
+		t.Errorf("got error %v, want error with code %v", err, codes.InvalidArgument)
+	}
+
+	// Structs that get MarshalText from an embedded field, and pointers to
+	// structs with their own encoding, are not encoded as STRING.
+	type event struct {
+		time.Time
+		Name string
+	}
+	for _, v := range []interface{}{event{Name: "foo"}, &event{Name: "foo"}, &time.Time{}, &civil.Date{}} {
+		if _, gotType, err := encodeValue(v); err == nil && gotType.Code == sppb.TypeCode_STRING {
+			t.Errorf("%T: unexpected STRING encoding", v)
+		}
+	}
+
+	// The encoded value must round-trip through the decoder.
+	pb, pt, err := encodeValue(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got testUUID
+	if err := decodeValue(pb, pt, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != u {
+		t.Fatalf("round-trip mismatch\nGot: %v\nWant: %v", got, u)
+	}
+}
+
+func TestDecodeValueTextUnmarshaler(t *testing.T) {
+	const s = "0f0e0d0c-0b0a-0908-0706-050403020100"
+	want := testUUID{15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+
+	var got testUUID
+	if err := decodeValue(stringProto(s), stringType(), &got); err != nil {
+		t.Fatalf("failed to decode STRING into testUUID: %v", err)
+	}
+	if got != want {
+		t.Fatalf("decoded value mismatch\nGot: %v\nWant: %v", got, want)
+	}
+	for _, test := range []struct {
+		desc      string
+		proto     *proto3.Value
+		protoType *sppb.Type
+	}{
+		{desc: "decode invalid STRING", proto: stringProto("not-a-uuid"), protoType: stringType()},
+		{desc: "decode NULL", proto: nullProto(), protoType: stringType()},
+		{desc: "decode INT64", proto: intProto(1), protoType: intType()},
+	} {
+		var u testUUID
+		if err := decodeValue(test.proto, test.protoType, &u); err == nil {
+			t.Errorf("%s: missing expected error", test.desc)
+		}
+	}
+
+	// TextUnmarshaler fields are also supported by ToStruct.
+	var st struct {
+		ID testUUID
+	}
+	r := Row{
+		[]*sppb.StructType_Field{{Name: "ID", Type: stringType()}},
+		[]*proto3.Value{stringProto(s)},
+	}
+	if err := r.ToStruct(&st); err != nil {
+		t.Fatalf("failed to decode row into struct: %v", err)
+	}
+	if st.ID != want {
+		t.Fatalf("decoded struct field mismatch\nGot: %v\nWant: %v", st.ID, want)
+	}
+}
+
 func TestGetDecodableSpannerType(t *testing.T) {
 	type CustomString string
 	type CustomInt64 int64
@@ -1227,6 +1497,55 @@ func TestGenericColumnValue(t *testing.T) {
 	}
 }
 
+func TestGenericColumnValueDecodeArray(t *testing.T) {
+	for _, test := range []struct {
+		in   GenericColumnValue
+		want interface{}
+		fail bool
+	}{
+		{GenericColumnValue{listType(intType()), listProto(intProto(91), nullProto(), intProto(87))}, []NullInt64{{91, true}, {}, {87, true}}, false},
+		{GenericColumnValue{listType(intType()), listProto(intProto(91), intProto(87))}, []int64{91, 87}, false},
+		{GenericColumnValue{listType(intType()), listProto(intProto(91), nullProto())}, []int64{}, true},
+		{GenericColumnValue{listType(stringType()), listProto(stringProto("foo"), nullProto())}, []NullString{{"foo", true}, {}}, false},
+		{GenericColumnValue{listType(stringType()), listProto(stringProto("foo"), stringProto("bar"))}, []string{"foo", "bar"}, false},
+		{GenericColumnValue{listType(stringType()), listProto(stringProto("foo"))}, []int64{}, true},
+		{GenericColumnValue{listType(boolType()), listProto(boolProto(true), nullProto())}, []NullBool{{true, true}, {}}, false},
+		{GenericColumnValue{listType(floatType()), listProto(floatProto(1.5), nullProto())}, []NullFloat64{{1.5, true}, {}}, false},
+		{GenericColumnValue{listType(intType()), listProto(intProto(1), nullProto())}, []GenericColumnValue{{intType(), intProto(1)}, {intType(), nullProto()}}, false},
+		{GenericColumnValue{listType(intType()), listProto()}, []int64{}, false},
+		{GenericColumnValue{listType(intType()), nullProto()}, []NullInt64(nil), false},
+		{GenericColumnValue{intType(), intProto(42)}, []int64{}, true},
+		{GenericColumnValue{listType(intType()), listProto(intProto(42))}, int64(0), true},
+	} {
+		gotp := reflect.New(reflect.TypeOf(test.want))
+		err := test.in.DecodeArray(gotp.Interface())
+		if err != nil {
+			if !test.fail {
+				t.Errorf("cannot decode %v to %T: %v", test.in, test.want, err)
+			}
+			continue
+		}
+		if test.fail {
+			t.Errorf("decoding %v to %T succeeds unexpectedly", test.in, test.want)
+			continue
+		}
+		if got := gotp.Elem().Interface(); !testEqual(got, test.want) {
+			t.Errorf("DecodeArray result mismatch\nGot: %v\nWant: %v", got, test.want)
+		}
+	}
+}
+
+func TestGenericColumnValueDecodeArrayNilPointer(t *testing.T) {
+	v := GenericColumnValue{listType(intType()), listProto(intProto(1))}
+	var p *[]int64
+	if err := v.DecodeArray(p); err == nil {
+		t.Fatal("missing error for decoding into nil pointer")
+	}
+	if err := v.DecodeArray(nil); err == nil {
+		t.Fatal("missing error for decoding into nil")
+	}
+}
+
 func TestDecodeStruct(t *testing.T) {
 	type CustomString string
 	type CustomTime time.Time
@@ -1310,6 +1629,60 @@ func TestDecodeStruct(t *testing.T) {
 	}
 }
 
+func TestDecodeStructArray(t *testing.T) {
+	ty := listType(
+		structType(
+			mkField("Title", stringType()),
+			mkField("Year", intType()),
+		),
+	)
+	v := listProto(
+		listProto(stringProto("Go, Go, Go"), intProto(2019)),
+		listProto(stringProto("Total Junk"), intProto(2020)),
+	)
+
+	type Album struct {
+		Title string
+		Year  int64
+	}
+	var albums []Album
+	if err := decodeValue(v, ty, &albums); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := albums, []Album{{"Go, Go, Go", 2019}, {"Total Junk", 2020}}; !testEqual(g, w) {
+		t.Fatalf("decoded value mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// A STRUCT field without a corresponding Go field fails the decoding,
+	// unless the Go struct is tagged to ignore unknown fields.
+	type Title struct {
+		Title string
+	}
+	var titles []Title
+	if err := decodeValue(v, ty, &titles); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", ErrCode(err), codes.InvalidArgument)
+	}
+	type LenientTitle struct {
+		_     struct{} `spanner:",ignoreunknown"`
+		Title string
+	}
+	var lenientTitles []LenientTitle
+	if err := decodeValue(v, ty, &lenientTitles); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(lenientTitles), 2; g != w {
+		t.Fatalf("length mismatch\nGot: %v\nWant: %v", g, w)
+	}
+	if g, w := lenientTitles[1].Title, "Total Junk"; g != w {
+		t.Fatalf("title mismatch\nGot: %v\nWant: %v", g, w)
+	}
+
+	// A slice of structs cannot hold NULL elements.
+	if err := decodeValue(listProto(nullProto()), ty, &albums); ErrCode(err) != codes.InvalidArgument {
+		t.Fatalf("Error code mismatch\nGot: %v\nWant: %v", ErrCode(err), codes.InvalidArgument)
+	}
+}
+
 func TestEncodeStructValueDynamicStructs(t *testing.T) {
 	dynStructType := reflect.StructOf([]reflect.StructField{
 		{Name: "A", Type: reflect.TypeOf(0), Tag: `spanner:"a"`},